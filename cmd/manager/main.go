@@ -19,17 +19,22 @@ package main
 import (
 	"flag"
 	"os"
+	"strings"
 
 	"github.com/kubesphere/s2ioperator/pkg/apis"
 	"github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
 	s2iconfig "github.com/kubesphere/s2ioperator/pkg/config"
 	"github.com/kubesphere/s2ioperator/pkg/controller"
 	"github.com/kubesphere/s2ioperator/pkg/handler"
-	"github.com/kubesphere/s2ioperator/pkg/metrics"
+	loghandler "github.com/kubesphere/s2ioperator/pkg/handler/log"
+	"github.com/kubesphere/s2ioperator/pkg/scope"
+	"k8s.io/client-go/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	"k8s.io/klog/klogr"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/manager/signals"
 )
@@ -40,16 +45,104 @@ func init() {
 	ctrl.SetLogger(klogr.New())
 }
 
+// bindFlags binds the flags to the config, the defaults of the flags are the values in the config
+func bindFlags(fs *flag.FlagSet, c *s2iconfig.Config) {
+	fs.StringVar(&c.MetricsAddress, "metrics-addr", c.MetricsAddress, "The address the metric endpoint binds to.")
+	fs.StringVar(&c.HealthProbeAddress, "health-probe-addr", c.HealthProbeAddress, "the address the healthz and readyz endpoints bind to")
+	fs.IntVar(&c.WebhookPort, "webhook-port", c.WebhookPort, "the port the admission webhooks are served on")
+	fs.BoolVar(&c.LeaderElection.Enabled, "leader-elect", c.LeaderElection.Enabled, "enable the leader election, which is required to run more than one replica. The controllers run only in the leader, the trigger server and the webhooks run in all the replicas")
+	fs.StringVar(&c.LeaderElection.Namespace, "leader-elect-namespace", c.LeaderElection.Namespace, "the namespace of the leader election lock, defaults to the namespace of the operator")
+	fs.StringVar(&c.LeaderElection.ID, "leader-elect-id", c.LeaderElection.ID, "the name of the leader election lock")
+	fs.DurationVar(&c.LeaderElection.LeaseDuration, "leader-elect-lease-duration", c.LeaderElection.LeaseDuration, "how long the non-leaders wait before taking over the leadership")
+	fs.DurationVar(&c.LeaderElection.RenewDeadline, "leader-elect-renew-deadline", c.LeaderElection.RenewDeadline, "how long the leader retries renewing the leadership before giving it up")
+	fs.DurationVar(&c.LeaderElection.RetryPeriod, "leader-elect-retry-period", c.LeaderElection.RetryPeriod, "how long the clients wait between the tries of the leader election")
+	fs.Var((*stringSlice)(&c.Scope.Namespaces), "namespaces", "the comma separated namespaces the operator serves, all the namespaces are served by default")
+	fs.StringVar(&c.Scope.NamespaceSelector, "namespace-selector", c.Scope.NamespaceSelector, "the label selector of the namespaces the operator serves, the operator is restarted when the namespaces which match it are changed")
+	fs.StringVar(&c.S2IRunJobTemplate, "s2irun-job-template", c.S2IRunJobTemplate, "the s2irun job template file path")
+	fs.StringVar(&c.S2IRunImage, "s2irun-image", c.S2IRunImage, "the image which builds the image in the jobs, it is overridden by the env S2IIMAGENAME")
+	fs.StringVar(&c.ManifestToolImage, "manifest-tool-image", c.ManifestToolImage, "the image used to push manifest lists of multi-platform builds")
+	fs.StringVar(&c.GitCloneImage, "git-clone-image", c.GitCloneImage, "the image used to clone the source for pre-build hooks")
+	fs.StringVar(&c.LogURL.Backend, "log-backend", c.LogURL.Backend, "the backend which the log url of build pods points to, one of none, kubesphere, loki, elasticsearch and template")
+	fs.StringVar(&c.LogURL.URL, "log-url", c.LogURL.URL, "the url of KubeSphere api gateway, Grafana or Kibana")
+	fs.StringVar(&c.LogURL.Template, "log-template", c.LogURL.Template, "the LogQL for loki, KQL for elasticsearch or the url for template, in Go text/template with .Namespace, .Pod, .Container, .Job and .S2iRun")
+	fs.StringVar(&c.LogURL.Datasource, "log-datasource", c.LogURL.Datasource, "the Loki datasource in Grafana, or the index pattern id in Kibana")
+	fs.StringVar(&c.LogArchive.Sink, "log-archive-sink", c.LogArchive.Sink, "where the log of build pods is archived after the job is finished, one of none, ConfigMap, Secret, PVC and S3")
+	fs.IntVar(&c.LogArchive.MaxBytes, "log-archive-max-bytes", c.LogArchive.MaxBytes, "the size limit of the log archived in a ConfigMap or Secret, only the tail of the log is kept")
+	fs.StringVar(&c.LogArchive.Directory, "log-archive-dir", c.LogArchive.Directory, "the directory of the PVC mounted in the operator for the PVC sink")
+	fs.StringVar(&c.LogArchive.S3.Endpoint, "log-archive-s3-endpoint", c.LogArchive.S3.Endpoint, "the endpoint of the S3 compatible object store, the credential is read from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	fs.StringVar(&c.LogArchive.S3.Bucket, "log-archive-s3-bucket", c.LogArchive.S3.Bucket, "the bucket of the S3 sink")
+	fs.StringVar(&c.LogArchive.S3.Region, "log-archive-s3-region", c.LogArchive.S3.Region, "the region of the S3 sink")
+	fs.StringVar(&c.LogArchive.S3.Prefix, "log-archive-s3-prefix", c.LogArchive.S3.Prefix, "the prefix of the object keys of the S3 sink")
+	fs.StringVar(&c.Trigger.Address, "trigger-addr", c.Trigger.Address, "the address the trigger server binds to, which also serves the api and the log of s2iruns")
+	fs.StringVar(&c.Trigger.CertFile, "trigger-cert-file", c.Trigger.CertFile, "serve the triggers in TLS with the certificate, which is reloaded after it is changed")
+	fs.StringVar(&c.Trigger.KeyFile, "trigger-key-file", c.Trigger.KeyFile, "the private key of the certificate of the trigger server")
+	fs.StringVar(&c.Trigger.ClientCAFile, "trigger-client-ca-file", c.Trigger.ClientCAFile, "require the clients of the trigger server to present a certificate signed by the CA")
+	fs.Int64Var(&c.Trigger.MaxRequestBytes, "trigger-max-request-bytes", c.Trigger.MaxRequestBytes, "the size limit of the request body of the trigger server")
+	fs.DurationVar(&c.Trigger.ReadTimeout, "trigger-read-timeout", c.Trigger.ReadTimeout, "the timeout of reading the requests of the trigger server")
+	fs.DurationVar(&c.Trigger.WriteTimeout, "trigger-write-timeout", c.Trigger.WriteTimeout, "the timeout of writing the responses of the trigger server, 0 means no timeout which is required by following the log of long builds")
+	fs.DurationVar(&c.Trigger.IdleTimeout, "trigger-idle-timeout", c.Trigger.IdleTimeout, "the timeout of the idle keep-alive connections of the trigger server")
+	fs.DurationVar(&c.Trigger.ShutdownTimeout, "trigger-shutdown-timeout", c.Trigger.ShutdownTimeout, "how long the in-flight requests are waited when the trigger server is shutting down")
+	fs.Float64Var(&c.Trigger.RateLimit.BuilderQPS, "trigger-builder-qps", c.Trigger.RateLimit.BuilderQPS, "the rate of the triggers of a s2ibuilder, 0 means no limit, it is overridden by the annotation devops.kubesphere.io/trigger-rate-limit of the s2ibuilder")
+	fs.IntVar(&c.Trigger.RateLimit.BuilderBurst, "trigger-builder-burst", c.Trigger.RateLimit.BuilderBurst, "the burst of the triggers of a s2ibuilder")
+	fs.Float64Var(&c.Trigger.RateLimit.IPQPS, "trigger-ip-qps", c.Trigger.RateLimit.IPQPS, "the rate of the triggers from a source ip, 0 means no limit")
+	fs.IntVar(&c.Trigger.RateLimit.IPBurst, "trigger-ip-burst", c.Trigger.RateLimit.IPBurst, "the burst of the triggers from a source ip")
+	fs.BoolVar(&c.Trigger.RateLimit.TrustForwardedFor, "trigger-trust-forwarded-for", c.Trigger.RateLimit.TrustForwardedFor, "take the source ip of the triggers from X-Forwarded-For, if the trigger server is behind a proxy")
+}
+
+// stringSlice is the flag of comma separated strings
+type stringSlice []string
+
+func (s *stringSlice) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringSlice) Set(value string) error {
+	*s = nil
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*s = append(*s, item)
+		}
+	}
+	return nil
+}
+
 func main() {
-	var metricsAddr string
-	var s2iRunJobTemplatePath string // checkout config/templates/s2irun-template.yaml for example
-	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
-	flag.StringVar(&s2iRunJobTemplatePath, "s2irun-job-template", "/etc/template/job.yaml", "the s2irun job template file path")
+	var configFile string
+	// the flags are parsed into their own config, so that they could be applied over the config file
+	flags := s2iconfig.NewDefaultConfig()
+	bindFlags(flag.CommandLine, flags)
+	flag.StringVar(&configFile, "config", "", "the config file of the operator, checkout config/manager/s2ioperator-config.yaml for example. The flags and env override it")
 	flag.Parse()
 	log := ctrl.Log.WithName("entrypoint")
-	
-	s2iConfig := &s2iconfig.Config{
-		S2IRunJobTemplate: s2iRunJobTemplatePath,
+
+	// load loads the config file into c, then applies the env and the flags set in the command line
+	load := func(c *s2iconfig.Config) error {
+		if configFile != "" {
+			if err := s2iconfig.LoadFile(configFile, c); err != nil {
+				return err
+			}
+		}
+		if image := os.Getenv("S2IIMAGENAME"); image != "" {
+			c.S2IRunImage = image
+		}
+		overrides := flag.NewFlagSet("overrides", flag.ContinueOnError)
+		bindFlags(overrides, c)
+		var err error
+		flag.Visit(func(f *flag.Flag) {
+			if overrides.Lookup(f.Name) != nil && err == nil {
+				err = overrides.Set(f.Name, f.Value.String())
+			}
+		})
+		return err
+	}
+	s2iConfig := s2iconfig.NewDefaultConfig()
+	if err := load(s2iConfig); err != nil {
+		log.Error(err, "unable to load the config")
+		os.Exit(1)
+	}
+	if err := s2iConfig.Validate(); err != nil {
+		log.Error(err, "invalid config")
+		os.Exit(1)
 	}
 
 	// Get a config to talk to the apiserver
@@ -60,14 +153,43 @@ func main() {
 		os.Exit(1)
 	}
 
+	// The objects out of the scope are not cached, so that they are ignored by the controllers
+	kubeClient := kubernetes.NewForConfigOrDie(cfg)
+	ctx := signals.SetupSignalHandler()
+	s2iScope, err := scope.New(ctx, kubeClient, s2iConfig.Scope)
+	if err != nil {
+		log.Error(err, "unable to set up the scope of namespaces")
+		os.Exit(1)
+	}
+	var uncached []client.Object
+	if !s2iScope.All() {
+		log.Info("serving the namespaces", "namespaces", s2iScope.Namespaces())
+		// the cluster-scoped objects could not be read from the cache of the namespaces
+		uncached = append(uncached, &v1alpha1.S2iBuilderTemplate{})
+	}
+
 	// Create a newgo Cmd to provide shared dependencies and start components
 	log.Info("setting up manager")
+	election := s2iConfig.LeaderElection
 	mgr, err := manager.New(cfg, manager.Options{
-		MetricsBindAddress: metricsAddr,
-		// We have to set the port to 443 for consistency, because the default port value has been changed
+		NewCache:               s2iScope.NewCache(),
+		ClientDisableCacheFor:  uncached,
+		MetricsBindAddress:     s2iConfig.MetricsAddress,
+		HealthProbeBindAddress: s2iConfig.HealthProbeAddress,
+		// The default port is 443 for consistency, because the default port value has been changed
 		// from 443 to 9443 after controller-runtime 0.7.0
 		// Please see also https://github.com/kubernetes-sigs/controller-runtime/releases/tag/v0.7.0
-		Port: 443,
+		Port: s2iConfig.WebhookPort,
+		// The controllers run only in the leader, the runnables which don't need the leader election,
+		// e.g. the trigger server, the webhooks and the config watcher, run in all the replicas
+		LeaderElection:          election.Enabled,
+		LeaderElectionNamespace: election.Namespace,
+		LeaderElectionID:        election.ID,
+		LeaseDuration:           &election.LeaseDuration,
+		RenewDeadline:           &election.RenewDeadline,
+		RetryPeriod:             &election.RetryPeriod,
+		// the leader steps down when it is stopped, so that another replica takes over without waiting for the lease
+		LeaderElectionReleaseOnCancel: true,
 	})
 	if err != nil {
 		log.Error(err, "unable to set up overall controller manager")
@@ -105,17 +227,50 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Set up s2i metrics
-	log.Info("start collect s2i metrics")
-	go metrics.CollectS2iMetrics(mgr.GetClient())
-
 	// Start webhook handler
 	log.Info("start webhook handler")
-	go handler.Run(mgr.GetClient())
+	logArchiver, err := loghandler.NewLogArchiver(s2iConfig.LogArchive, mgr.GetClient())
+	if err != nil {
+		log.Error(err, "unable to set up log archiver")
+		os.Exit(1)
+	}
+	// the trigger server takes the objects out of the scope as not found
+	server := handler.NewServer(s2iConfig.Trigger, scope.NewClient(mgr.GetClient(), s2iScope), kubeClient, logArchiver)
+	if err = mgr.Add(server); err != nil {
+		log.Error(err, "unable to set up webhook handler")
+		os.Exit(1)
+	}
+
+	// All the replicas are ready once the trigger server is serving, whether they are the leader or not.
+	// The leader exits when it loses the leadership, so that it is restarted without a liveness check.
+	if err = mgr.AddHealthzCheck("ping", healthz.Ping); err != nil {
+		log.Error(err, "unable to set up health check")
+		os.Exit(1)
+	}
+	if err = mgr.AddReadyzCheck("trigger", server.ReadyCheck); err != nil {
+		log.Error(err, "unable to set up ready check")
+		os.Exit(1)
+	}
+
+	// Restart the operator when the namespaces which match the selector are changed
+	if s2iConfig.Scope.NamespaceSelector != "" {
+		if err = mgr.Add(s2iScope); err != nil {
+			log.Error(err, "unable to set up the scope of namespaces")
+			os.Exit(1)
+		}
+	}
+
+	// Reload the config when the config file is changed
+	if configFile != "" {
+		if err = mgr.Add(s2iconfig.NewWatcher(configFile, s2iConfig, load)); err != nil {
+			log.Error(err, "unable to set up the config watcher")
+			os.Exit(1)
+		}
+	}
 
 	//Start the Cmd
 	log.Info("Starting the Cmd.")
-	if err := mgr.Start(signals.SetupSignalHandler()); err != nil {
+	if err := mgr.Start(ctx); err != nil {
 		log.Error(err, "unable to run the manager")
 		os.Exit(1)
 	}
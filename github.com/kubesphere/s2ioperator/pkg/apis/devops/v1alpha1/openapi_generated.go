@@ -43,6 +43,8 @@ func GetOpenAPIDefinitions(ref common.ReferenceCallback) map[string]common.OpenA
 		"github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.Parameter":                schema_pkg_apis_devops_v1alpha1_Parameter(ref),
 		"github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.ProxyConfig":              schema_pkg_apis_devops_v1alpha1_ProxyConfig(ref),
 		"github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iAutoScale":             schema_pkg_apis_devops_v1alpha1_S2iAutoScale(ref),
+		"github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iBuildCache":            schema_pkg_apis_devops_v1alpha1_S2iBuildCache(ref),
+		"github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iBuildMatrix":           schema_pkg_apis_devops_v1alpha1_S2iBuildMatrix(ref),
 		"github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iBuildResult":           schema_pkg_apis_devops_v1alpha1_S2iBuildResult(ref),
 		"github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iBuildSource":           schema_pkg_apis_devops_v1alpha1_S2iBuildSource(ref),
 		"github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iBuilder":               schema_pkg_apis_devops_v1alpha1_S2iBuilder(ref),
@@ -54,12 +56,23 @@ func GetOpenAPIDefinitions(ref common.ReferenceCallback) map[string]common.OpenA
 		"github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iBuilderTemplateSpec":   schema_pkg_apis_devops_v1alpha1_S2iBuilderTemplateSpec(ref),
 		"github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iBuilderTemplateStatus": schema_pkg_apis_devops_v1alpha1_S2iBuilderTemplateStatus(ref),
 		"github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iConfig":                schema_pkg_apis_devops_v1alpha1_S2iConfig(ref),
+		"github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iDeployTarget":          schema_pkg_apis_devops_v1alpha1_S2iDeployTarget(ref),
+		"github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iHook":                  schema_pkg_apis_devops_v1alpha1_S2iHook(ref),
+		"github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iHookResult":            schema_pkg_apis_devops_v1alpha1_S2iHookResult(ref),
+		"github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iLogArchive":            schema_pkg_apis_devops_v1alpha1_S2iLogArchive(ref),
+		"github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iMatrixBuilderImage":    schema_pkg_apis_devops_v1alpha1_S2iMatrixBuilderImage(ref),
+		"github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iMatrixCell":            schema_pkg_apis_devops_v1alpha1_S2iMatrixCell(ref),
+		"github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iMatrixCellResult":      schema_pkg_apis_devops_v1alpha1_S2iMatrixCellResult(ref),
+		"github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iMatrixParameter":       schema_pkg_apis_devops_v1alpha1_S2iMatrixParameter(ref),
+		"github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iPlatformBuildResult":   schema_pkg_apis_devops_v1alpha1_S2iPlatformBuildResult(ref),
 		"github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iRun":                   schema_pkg_apis_devops_v1alpha1_S2iRun(ref),
+		"github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iRunAttempt":            schema_pkg_apis_devops_v1alpha1_S2iRunAttempt(ref),
 		"github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iRunList":               schema_pkg_apis_devops_v1alpha1_S2iRunList(ref),
 		"github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iRunSpec":               schema_pkg_apis_devops_v1alpha1_S2iRunSpec(ref),
 		"github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iRunStatus":             schema_pkg_apis_devops_v1alpha1_S2iRunStatus(ref),
 		"github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.UserDefineTemplate":       schema_pkg_apis_devops_v1alpha1_UserDefineTemplate(ref),
 		"github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.VolumeSpec":               schema_pkg_apis_devops_v1alpha1_VolumeSpec(ref),
+		"github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.s2iBuilderValidator":      schema_pkg_apis_devops_v1alpha1_s2iBuilderValidator(ref),
 		"k8s.io/api/core/v1.AWSElasticBlockStoreVolumeSource":                                 schema_k8sio_api_core_v1_AWSElasticBlockStoreVolumeSource(ref),
 		"k8s.io/api/core/v1.Affinity":                                    schema_k8sio_api_core_v1_Affinity(ref),
 		"k8s.io/api/core/v1.AttachedVolume":                              schema_k8sio_api_core_v1_AttachedVolume(ref),
@@ -772,7 +785,8 @@ func schema_pkg_apis_devops_v1alpha1_S2iAutoScale(ref common.ReferenceCallback)
 	return common.OpenAPIDefinition{
 		Schema: spec.Schema{
 			SchemaProps: spec.SchemaProps{
-				Type: []string{"object"},
+				Description: "S2iAutoScale is a target of the annotation devops.kubesphere.io/autoscale, it is decoded as S2iDeployTarget. Deprecated: use S2iDeployTarget in spec.deployTargets instead.",
+				Type:        []string{"object"},
 				Properties: map[string]spec.Schema{
 					"kind": {
 						SchemaProps: spec.SchemaProps{
@@ -815,6 +829,108 @@ func schema_pkg_apis_devops_v1alpha1_S2iAutoScale(ref common.ReferenceCallback)
 	}
 }
 
+func schema_pkg_apis_devops_v1alpha1_S2iBuildCache(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "S2iBuildCache describes a persistent volume which is shared by all runs of a builder, it is used to keep caches like maven or npm repositories between builds. The size, storage class and access modes can not be changed while the volume exists unless the cache is purged in the same update.",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"size": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Size is the requested storage size of the cache volume.",
+							Default:     map[string]interface{}{},
+							Ref:         ref("k8s.io/apimachinery/pkg/api/resource.Quantity"),
+						},
+					},
+					"storageClassName": {
+						SchemaProps: spec.SchemaProps{
+							Description: "StorageClassName is the storage class of the cache volume, the cluster default is used if not set.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"accessModes": {
+						SchemaProps: spec.SchemaProps{
+							Description: "AccessModes of the cache volume, default is ReadWriteOnce.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: "",
+										Type:    []string{"string"},
+										Format:  "",
+									},
+								},
+							},
+						},
+					},
+					"paths": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Paths are the directories in the build container which are backed by the cache volume.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: "",
+										Type:    []string{"string"},
+										Format:  "",
+									},
+								},
+							},
+						},
+					},
+				},
+				Required: []string{"size", "paths"},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/api/resource.Quantity"},
+	}
+}
+
+func schema_pkg_apis_devops_v1alpha1_S2iBuildMatrix(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Type: []string{"object"},
+				Properties: map[string]spec.Schema{
+					"builderImages": {
+						SchemaProps: spec.SchemaProps{
+							Description: "BuilderImages are the builder images to build with.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: map[string]interface{}{},
+										Ref:     ref("github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iMatrixBuilderImage"),
+									},
+								},
+							},
+						},
+					},
+					"parameters": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Parameters are the template parameters or environments to build with, every parameter is an axis of the matrix.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: map[string]interface{}{},
+										Ref:     ref("github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iMatrixParameter"),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iMatrixBuilderImage", "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iMatrixParameter"},
+	}
+}
+
 func schema_pkg_apis_devops_v1alpha1_S2iBuildResult(ref common.ReferenceCallback) common.OpenAPIDefinition {
 	return common.OpenAPIDefinition{
 		Schema: spec.Schema{
@@ -871,9 +987,25 @@ func schema_pkg_apis_devops_v1alpha1_S2iBuildResult(ref common.ReferenceCallback
 							Format:      "",
 						},
 					},
+					"platformResults": {
+						SchemaProps: spec.SchemaProps{
+							Description: "PlatformResults are the build results of each platform of a multi-platform image.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: map[string]interface{}{},
+										Ref:     ref("github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iPlatformBuildResult"),
+									},
+								},
+							},
+						},
+					},
 				},
 			},
 		},
+		Dependencies: []string{
+			"github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iPlatformBuildResult"},
 	}
 }
 
@@ -918,6 +1050,27 @@ func schema_pkg_apis_devops_v1alpha1_S2iBuildSource(ref common.ReferenceCallback
 							Format:      "",
 						},
 					},
+					"builderImageDigest": {
+						SchemaProps: spec.SchemaProps{
+							Description: "BuilderImageDigest is the digest of the builder image which is used, e.g. sha256:...",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"runtimeImage": {
+						SchemaProps: spec.SchemaProps{
+							Description: "RuntimeImage is the runtime image which is used if the build is a two-stage build.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"runtimeImageDigest": {
+						SchemaProps: spec.SchemaProps{
+							Description: "RuntimeImageDigest is the digest of the runtime image which is used.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
 					"description": {
 						SchemaProps: spec.SchemaProps{
 							Description: "Description is a result image description label. The default is no description.",
@@ -1067,11 +1220,38 @@ func schema_pkg_apis_devops_v1alpha1_S2iBuilderSpec(ref common.ReferenceCallback
 							Ref:         ref("github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.UserDefineTemplate"),
 						},
 					},
+					"cache": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Cache define a persistent build cache of this builder, the cache can be purged by the annotation devops.kubesphere.io/purgebuildcache",
+							Ref:         ref("github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iBuildCache"),
+						},
+					},
+					"deletionPolicy": {
+						SchemaProps: spec.SchemaProps{
+							Description: "DeletionPolicy decides what happens to the s2iruns of this builder when it is deleted, default is Delete. The summary of the s2iruns is kept in the ConfigMap <builder name>-archive by Archive.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"deployTargets": {
+						SchemaProps: spec.SchemaProps{
+							Description: "DeployTargets are the workloads updated to the image built by the s2iruns of this builder, it replaces the annotation devops.kubesphere.io/autoscale.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: map[string]interface{}{},
+										Ref:     ref("github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iDeployTarget"),
+									},
+								},
+							},
+						},
+					},
 				},
 			},
 		},
 		Dependencies: []string{
-			"github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iConfig", "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.UserDefineTemplate"},
+			"github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iBuildCache", "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iConfig", "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iDeployTarget", "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.UserDefineTemplate"},
 	}
 }
 
@@ -1110,6 +1290,13 @@ func schema_pkg_apis_devops_v1alpha1_S2iBuilderStatus(ref common.ReferenceCallba
 							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Time"),
 						},
 					},
+					"cacheClaimName": {
+						SchemaProps: spec.SchemaProps{
+							Description: "CacheClaimName return the name of the PersistentVolumeClaim used as build cache",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
 				},
 				Required: []string{"runCount"},
 			},
@@ -1780,6 +1967,49 @@ func schema_pkg_apis_devops_v1alpha1_S2iConfig(ref common.ReferenceCallback) com
 							},
 						},
 					},
+					"platforms": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Platforms are the target platforms of the image such as linux/amd64 and linux/arm64/v8. If set, the image is built on nodes of each platform and pushed as a manifest list.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: "",
+										Type:    []string{"string"},
+										Format:  "",
+									},
+								},
+							},
+						},
+					},
+					"preBuildHooks": {
+						SchemaProps: spec.SchemaProps{
+							Description: "PreBuildHooks run in order against the cloned source before the image is built, the image is built from the same source after all of them succeed.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: map[string]interface{}{},
+										Ref:     ref("github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iHook"),
+									},
+								},
+							},
+						},
+					},
+					"postBuildHooks": {
+						SchemaProps: spec.SchemaProps{
+							Description: "PostBuildHooks run in order after the image is built and pushed.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: map[string]interface{}{},
+										Ref:     ref("github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iHook"),
+									},
+								},
+							},
+						},
+					},
 					"outputBuildResult": {
 						SchemaProps: spec.SchemaProps{
 							Description: "Whether output build result to status.",
@@ -1796,109 +2026,706 @@ func schema_pkg_apis_devops_v1alpha1_S2iConfig(ref common.ReferenceCallback) com
 					},
 					"secretCode": {
 						SchemaProps: spec.SchemaProps{
-							Description: "SecretCode",
+							Description: "SecretCode authorizes the triggers of the s2ibuilder. Deprecated: it is readable by anyone who can get the s2ibuilder, use TriggerSecretRef instead.",
 							Type:        []string{"string"},
 							Format:      "",
 						},
 					},
+					"triggerSecretRef": {
+						SchemaProps: spec.SchemaProps{
+							Description: "TriggerSecretRef selects a key of a Secret in the namespace of the s2ibuilder whose value holds the secret codes of the triggers, one per line. All of the codes are active, so that a new code can be added before the old one is removed. The github triggers are authorized by the X-Hub-Signature-256 of the events, which are signed with one of the codes as the secret of the github webhook.",
+							Ref:         ref("k8s.io/api/core/v1.SecretKeySelector"),
+						},
+					},
 				},
 				Required: []string{"imageName", "sourceUrl"},
 			},
 		},
 		Dependencies: []string{
-			"github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.AuthConfig", "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.CGroupLimits", "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.DockerConfig", "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.EnvironmentSpec", "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.ProxyConfig", "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.VolumeSpec", "k8s.io/api/core/v1.LocalObjectReference"},
+			"github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.AuthConfig", "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.CGroupLimits", "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.DockerConfig", "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.EnvironmentSpec", "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.ProxyConfig", "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iHook", "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.VolumeSpec", "k8s.io/api/core/v1.LocalObjectReference", "k8s.io/api/core/v1.SecretKeySelector"},
 	}
 }
 
-func schema_pkg_apis_devops_v1alpha1_S2iRun(ref common.ReferenceCallback) common.OpenAPIDefinition {
+func schema_pkg_apis_devops_v1alpha1_S2iDeployTarget(ref common.ReferenceCallback) common.OpenAPIDefinition {
 	return common.OpenAPIDefinition{
 		Schema: spec.Schema{
 			SchemaProps: spec.SchemaProps{
-				Description: "S2iRun is the Schema for the s2iruns API",
+				Description: "S2iDeployTarget is a workload in the namespace of the builder, the image of its pod template is updated after each s2irun of the builder.",
 				Type:        []string{"object"},
 				Properties: map[string]spec.Schema{
+					"apiVersion": {
+						SchemaProps: spec.SchemaProps{
+							Description: "APIVersion of the workload, it is required by the custom resources, e.g. serving.knative.dev/v1 of the Services of Knative. The built-in kinds are in apps/v1 and batch/v1 by default, except batch/v1beta1 of CronJob.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
 					"kind": {
 						SchemaProps: spec.SchemaProps{
-							Description: "Kind is a string value representing the REST resource this object represents. Servers may infer this from the endpoint the client submits requests to. Cannot be updated. In CamelCase. More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#types-kinds",
+							Description: "Kind of the workload, Deployment, StatefulSet, DaemonSet, CronJob, Job or the kind of a custom resource. The pod template of Job is immutable, the Job is created again by each s2irun.",
+							Default:     "",
 							Type:        []string{"string"},
 							Format:      "",
 						},
 					},
-					"apiVersion": {
+					"name": {
 						SchemaProps: spec.SchemaProps{
-							Description: "APIVersion defines the versioned schema of this representation of an object. Servers should convert recognized schemas to the latest internal value, and may reject unrecognized values. More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#resources",
+							Description: "Name of the workload",
+							Default:     "",
 							Type:        []string{"string"},
 							Format:      "",
 						},
 					},
-					"metadata": {
+					"initReplicas": {
 						SchemaProps: spec.SchemaProps{
-							Default: map[string]interface{}{},
-							Ref:     ref("k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta"),
+							Description: "InitReplicas is set to the workload of 0 replicas after the first successful s2irun, default is 1",
+							Type:        []string{"integer"},
+							Format:      "int32",
 						},
 					},
-					"spec": {
+					"containers": {
 						SchemaProps: spec.SchemaProps{
-							Default: map[string]interface{}{},
-							Ref:     ref("github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iRunSpec"),
+							Description: "Containers are the names of the containers updated, it is required if the pod template has more than one",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: "",
+										Type:    []string{"string"},
+										Format:  "",
+									},
+								},
+							},
 						},
 					},
-					"status": {
+					"containersPath": {
 						SchemaProps: spec.SchemaProps{
-							Default: map[string]interface{}{},
-							Ref:     ref("github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iRunStatus"),
+							Description: "ContainersPath is the JSONPath to the containers of the custom resource, only the fields are supported, default is {.spec.template.spec.containers}",
+							Type:        []string{"string"},
+							Format:      "",
 						},
 					},
 				},
+				Required: []string{"kind", "name"},
 			},
 		},
-		Dependencies: []string{
-			"github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iRunSpec", "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iRunStatus", "k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta"},
 	}
 }
 
-func schema_pkg_apis_devops_v1alpha1_S2iRunList(ref common.ReferenceCallback) common.OpenAPIDefinition {
+func schema_pkg_apis_devops_v1alpha1_S2iHook(ref common.ReferenceCallback) common.OpenAPIDefinition {
 	return common.OpenAPIDefinition{
 		Schema: spec.Schema{
 			SchemaProps: spec.SchemaProps{
-				Description: "S2iRunList contains a list of S2iRun",
+				Description: "S2iHook is a container which runs before or after the image is built.",
 				Type:        []string{"object"},
 				Properties: map[string]spec.Schema{
-					"kind": {
+					"name": {
 						SchemaProps: spec.SchemaProps{
-							Description: "Kind is a string value representing the REST resource this object represents. Servers may infer this from the endpoint the client submits requests to. Cannot be updated. In CamelCase. More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#types-kinds",
+							Description: "Name of the hook, it should be unique in the pre-build or post-build hooks.",
+							Default:     "",
 							Type:        []string{"string"},
 							Format:      "",
 						},
 					},
-					"apiVersion": {
+					"image": {
 						SchemaProps: spec.SchemaProps{
-							Description: "APIVersion defines the versioned schema of this representation of an object. Servers should convert recognized schemas to the latest internal value, and may reject unrecognized values. More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#resources",
+							Description: "Image of the hook container. It is required by pre-build hooks, and post-build hooks use the built image if not set.",
 							Type:        []string{"string"},
 							Format:      "",
 						},
 					},
-					"metadata": {
+					"command": {
 						SchemaProps: spec.SchemaProps{
-							Default: map[string]interface{}{},
-							Ref:     ref("k8s.io/apimachinery/pkg/apis/meta/v1.ListMeta"),
+							Description: "Command overrides the entrypoint of the image.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: "",
+										Type:    []string{"string"},
+										Format:  "",
+									},
+								},
+							},
 						},
 					},
-					"items": {
+					"args": {
 						SchemaProps: spec.SchemaProps{
-							Type: []string{"array"},
+							Description: "Args are the arguments to the entrypoint.",
+							Type:        []string{"array"},
 							Items: &spec.SchemaOrArray{
 								Schema: &spec.Schema{
 									SchemaProps: spec.SchemaProps{
-										Default: map[string]interface{}{},
-										Ref:     ref("github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iRun"),
+										Default: "",
+										Type:    []string{"string"},
+										Format:  "",
 									},
 								},
 							},
 						},
 					},
-				},
-				Required: []string{"items"},
+					"env": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Env is a list of environment variables to set in the hook container.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: map[string]interface{}{},
+										Ref:     ref("github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.EnvironmentSpec"),
+									},
+								},
+							},
+						},
+					},
+				},
+				Required: []string{"name"},
+			},
+		},
+		Dependencies: []string{
+			"github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.EnvironmentSpec"},
+	}
+}
+
+func schema_pkg_apis_devops_v1alpha1_S2iHookResult(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Type: []string{"object"},
+				Properties: map[string]spec.Schema{
+					"name": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Name of the hook",
+							Default:     "",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"stage": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Stage is PreBuild or PostBuild",
+							Default:     "",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"kubernetesJobName": {
+						SchemaProps: spec.SchemaProps{
+							Description: "KubernetesJobName is the job which runs the hook",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"runState": {
+						SchemaProps: spec.SchemaProps{
+							Description: "RunState indicates whether the hook is done or failed",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"exitCode": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ExitCode of the hook container",
+							Type:        []string{"integer"},
+							Format:      "int32",
+						},
+					},
+					"reason": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Reason and Message of the termination of the hook container",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"message": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+				},
+				Required: []string{"name", "stage"},
+			},
+		},
+	}
+}
+
+func schema_pkg_apis_devops_v1alpha1_S2iLogArchive(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Type: []string{"object"},
+				Properties: map[string]spec.Schema{
+					"kubernetesJobName": {
+						SchemaProps: spec.SchemaProps{
+							Description: "KubernetesJobName is the job whose log is archived",
+							Default:     "",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"podName": {
+						SchemaProps: spec.SchemaProps{
+							Description: "PodName is the pod whose log is archived",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"sink": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Sink is the type of the storage of the log, one of ConfigMap, Secret, PVC and S3.",
+							Default:     "",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"location": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Location of the log in the sink, such as the name of the ConfigMap, the path of the file or the url of the object.",
+							Default:     "",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"truncated": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Truncated is true if only the tail of the log is kept because of the size limit of the sink.",
+							Type:        []string{"boolean"},
+							Format:      "",
+						},
+					},
+					"archiveTime": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ArchiveTime is when the log is archived",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Time"),
+						},
+					},
+				},
+				Required: []string{"kubernetesJobName", "sink", "location"},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/apis/meta/v1.Time"},
+	}
+}
+
+func schema_pkg_apis_devops_v1alpha1_S2iMatrixBuilderImage(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Type: []string{"object"},
+				Properties: map[string]spec.Schema{
+					"name": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Name is used in the tag of the image built with the builder image, e.g. java11",
+							Default:     "",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"builderImage": {
+						SchemaProps: spec.SchemaProps{
+							Description: "BuilderImage describes which image is used for building the result images.",
+							Default:     "",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"runtimeImage": {
+						SchemaProps: spec.SchemaProps{
+							Description: "RuntimeImage is the runtime image used with the builder image, it is found in the template of the builder if not set.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+				},
+				Required: []string{"name", "builderImage"},
+			},
+		},
+	}
+}
+
+func schema_pkg_apis_devops_v1alpha1_S2iMatrixCell(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "S2iMatrixCell is a combination of the builder image and parameter values in the matrix.",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"name": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Name is the suffix of the tag of the image built by the cell.",
+							Default:     "",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"builderImage": {
+						SchemaProps: spec.SchemaProps{
+							Description: "BuilderImage describes which image is used for building the result images.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"runtimeImage": {
+						SchemaProps: spec.SchemaProps{
+							Description: "RuntimeImage is the runtime image used with the builder image.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"parameters": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Parameters are the values of the template parameters or environments.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: map[string]interface{}{},
+										Ref:     ref("github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.EnvironmentSpec"),
+									},
+								},
+							},
+						},
+					},
+				},
+				Required: []string{"name"},
+			},
+		},
+		Dependencies: []string{
+			"github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.EnvironmentSpec"},
+	}
+}
+
+func schema_pkg_apis_devops_v1alpha1_S2iMatrixCellResult(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Type: []string{"object"},
+				Properties: map[string]spec.Schema{
+					"name": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Name is the suffix of the tag of the image built by the cell.",
+							Default:     "",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"builderImage": {
+						SchemaProps: spec.SchemaProps{
+							Description: "BuilderImage describes which image is used for building the result images.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"runtimeImage": {
+						SchemaProps: spec.SchemaProps{
+							Description: "RuntimeImage is the runtime image used with the builder image.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"parameters": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Parameters are the values of the template parameters or environments.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: map[string]interface{}{},
+										Ref:     ref("github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.EnvironmentSpec"),
+									},
+								},
+							},
+						},
+					},
+					"runState": {
+						SchemaProps: spec.SchemaProps{
+							Description: "RunState indicates whether the build of this cell is done or failed",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"kubernetesJobName": {
+						SchemaProps: spec.SchemaProps{
+							Description: "KubernetesJobName is the job which builds the image of this cell",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"logURL": {
+						SchemaProps: spec.SchemaProps{
+							Description: "LogURL is the log location of the job",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"imageName": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ImageName is the image built by this cell",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"imageSize": {
+						SchemaProps: spec.SchemaProps{
+							Description: "The size in bytes of the image",
+							Type:        []string{"integer"},
+							Format:      "int64",
+						},
+					},
+					"imageID": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Image ID.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+				},
+				Required: []string{"name"},
+			},
+		},
+		Dependencies: []string{
+			"github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.EnvironmentSpec"},
+	}
+}
+
+func schema_pkg_apis_devops_v1alpha1_S2iMatrixParameter(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Type: []string{"object"},
+				Properties: map[string]spec.Schema{
+					"key": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Key is the key of the template parameter or the name of the environment.",
+							Default:     "",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"values": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Values are the values to build with, the value is used in the tag of the image after the characters other than letters and digits are replaced with \"-\".",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: "",
+										Type:    []string{"string"},
+										Format:  "",
+									},
+								},
+							},
+						},
+					},
+				},
+				Required: []string{"key", "values"},
+			},
+		},
+	}
+}
+
+func schema_pkg_apis_devops_v1alpha1_S2iPlatformBuildResult(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Type: []string{"object"},
+				Properties: map[string]spec.Schema{
+					"platform": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Platform is the target platform of the build, e.g. linux/arm64",
+							Default:     "",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"runState": {
+						SchemaProps: spec.SchemaProps{
+							Description: "RunState indicates whether the build of this platform is done or failed",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"kubernetesJobName": {
+						SchemaProps: spec.SchemaProps{
+							Description: "KubernetesJobName is the job which builds the image of this platform",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"logURL": {
+						SchemaProps: spec.SchemaProps{
+							Description: "LogURL is the log location of the job",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"imageName": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ImageName is the platform specific image which is added to the manifest list",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"imageSize": {
+						SchemaProps: spec.SchemaProps{
+							Description: "The size in bytes of the image",
+							Type:        []string{"integer"},
+							Format:      "int64",
+						},
+					},
+					"imageID": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Image ID.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+				},
+				Required: []string{"platform"},
+			},
+		},
+	}
+}
+
+func schema_pkg_apis_devops_v1alpha1_S2iRun(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "S2iRun is the Schema for the s2iruns API",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"kind": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Kind is a string value representing the REST resource this object represents. Servers may infer this from the endpoint the client submits requests to. Cannot be updated. In CamelCase. More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#types-kinds",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"apiVersion": {
+						SchemaProps: spec.SchemaProps{
+							Description: "APIVersion defines the versioned schema of this representation of an object. Servers should convert recognized schemas to the latest internal value, and may reject unrecognized values. More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#resources",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"metadata": {
+						SchemaProps: spec.SchemaProps{
+							Default: map[string]interface{}{},
+							Ref:     ref("k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta"),
+						},
+					},
+					"spec": {
+						SchemaProps: spec.SchemaProps{
+							Default: map[string]interface{}{},
+							Ref:     ref("github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iRunSpec"),
+						},
+					},
+					"status": {
+						SchemaProps: spec.SchemaProps{
+							Default: map[string]interface{}{},
+							Ref:     ref("github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iRunStatus"),
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iRunSpec", "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iRunStatus", "k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta"},
+	}
+}
+
+func schema_pkg_apis_devops_v1alpha1_S2iRunAttempt(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Type: []string{"object"},
+				Properties: map[string]spec.Schema{
+					"podName": {
+						SchemaProps: spec.SchemaProps{
+							Description: "PodName is the pod of the attempt",
+							Default:     "",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"runState": {
+						SchemaProps: spec.SchemaProps{
+							Description: "RunState indicates whether the attempt is done or failed",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"logURL": {
+						SchemaProps: spec.SchemaProps{
+							Description: "LogURL is the log location of the pod",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"startTime": {
+						SchemaProps: spec.SchemaProps{
+							Description: "StartTime and CompletionTime of the pod",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Time"),
+						},
+					},
+					"completionTime": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("k8s.io/apimachinery/pkg/apis/meta/v1.Time"),
+						},
+					},
+				},
+				Required: []string{"podName"},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/apis/meta/v1.Time"},
+	}
+}
+
+func schema_pkg_apis_devops_v1alpha1_S2iRunList(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "S2iRunList contains a list of S2iRun",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"kind": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Kind is a string value representing the REST resource this object represents. Servers may infer this from the endpoint the client submits requests to. Cannot be updated. In CamelCase. More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#types-kinds",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"apiVersion": {
+						SchemaProps: spec.SchemaProps{
+							Description: "APIVersion defines the versioned schema of this representation of an object. Servers should convert recognized schemas to the latest internal value, and may reject unrecognized values. More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#resources",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"metadata": {
+						SchemaProps: spec.SchemaProps{
+							Default: map[string]interface{}{},
+							Ref:     ref("k8s.io/apimachinery/pkg/apis/meta/v1.ListMeta"),
+						},
+					},
+					"items": {
+						SchemaProps: spec.SchemaProps{
+							Type: []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: map[string]interface{}{},
+										Ref:     ref("github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iRun"),
+									},
+								},
+							},
+						},
+					},
+				},
+				Required: []string{"items"},
 			},
 		},
 		Dependencies: []string{
@@ -1956,10 +2783,25 @@ func schema_pkg_apis_devops_v1alpha1_S2iRunSpec(ref common.ReferenceCallback) co
 							Format:      "",
 						},
 					},
+					"matrix": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Matrix builds an image for each combination of the builder images and parameter values, the tag of each image has the name of the combination as suffix, e.g. latest-java11. The deploy targets of the builder are not updated by the runs with a matrix.",
+							Ref:         ref("github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iBuildMatrix"),
+						},
+					},
+					"pinResolvedSource": {
+						SchemaProps: spec.SchemaProps{
+							Description: "PinResolvedSource makes the reruns of the run build the commit resolved by it. The config of a build is frozen once it is created, the configs created after the source is resolved use the commit and the builder and runtime image digests resolved by the run, so that they build the same inputs.",
+							Type:        []string{"boolean"},
+							Format:      "",
+						},
+					},
 				},
 				Required: []string{"builderName"},
 			},
 		},
+		Dependencies: []string{
+			"github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iBuildMatrix"},
 	}
 }
 
@@ -2015,11 +2857,116 @@ func schema_pkg_apis_devops_v1alpha1_S2iRunStatus(ref common.ReferenceCallback)
 							Ref:         ref("github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iBuildSource"),
 						},
 					},
+					"matrixResults": {
+						SchemaProps: spec.SchemaProps{
+							Description: "MatrixResults are the states and results of each cell if the run has a build matrix.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: map[string]interface{}{},
+										Ref:     ref("github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iMatrixCellResult"),
+									},
+								},
+							},
+						},
+					},
+					"hookResults": {
+						SchemaProps: spec.SchemaProps{
+							Description: "HookResults are the states of the pre-build and post-build hooks.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: map[string]interface{}{},
+										Ref:     ref("github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iHookResult"),
+									},
+								},
+							},
+						},
+					},
+					"attempts": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Attempts is the number of pods the build job has started, it is greater than one if the build is retried.",
+							Type:        []string{"integer"},
+							Format:      "int32",
+						},
+					},
+					"attemptResults": {
+						SchemaProps: spec.SchemaProps{
+							Description: "AttemptResults are the outcomes of the pods of the build job, ordered by the time they are created.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: map[string]interface{}{},
+										Ref:     ref("github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iRunAttempt"),
+									},
+								},
+							},
+						},
+					},
+					"rerunOf": {
+						SchemaProps: spec.SchemaProps{
+							Description: "RerunOf is the name of the S2iRun which is rerun by this S2iRun.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"configSnapshotName": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ConfigSnapshotName is the immutable ConfigMap which keeps the effective config of the S2iRun, it is saved the first time the S2iRun is reconciled and the changes of the S2iBuilder after that do not affect the S2iRun.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"failureReason": {
+						SchemaProps: spec.SchemaProps{
+							Description: "FailureReason is a brief CamelCase reason why the build failed or is stuck, e.g. ImagePullBackOff, OOMKilled, Error.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"failureStage": {
+						SchemaProps: spec.SchemaProps{
+							Description: "FailureStage is the stage of the build which failed, one of Clone, PreBuild, Assemble, Commit and Push.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"failureMessage": {
+						SchemaProps: spec.SchemaProps{
+							Description: "FailureMessage is a human readable message about the failure, such as the exit code of the container.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"logTail": {
+						SchemaProps: spec.SchemaProps{
+							Description: "LogTail is the last lines of the log of the failed container.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"logArchives": {
+						SchemaProps: spec.SchemaProps{
+							Description: "LogArchives are the locations where the logs of the build, manifest and post-build jobs are archived after the jobs are finished.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: map[string]interface{}{},
+										Ref:     ref("github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iLogArchive"),
+									},
+								},
+							},
+						},
+					},
 				},
 			},
 		},
 		Dependencies: []string{
-			"github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iBuildResult", "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iBuildSource", "k8s.io/apimachinery/pkg/apis/meta/v1.Time"},
+			"github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iBuildResult", "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iBuildSource", "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iHookResult", "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iLogArchive", "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iMatrixCellResult", "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1.S2iRunAttempt", "k8s.io/apimachinery/pkg/apis/meta/v1.Time"},
 	}
 }
 
@@ -2099,6 +3046,32 @@ func schema_pkg_apis_devops_v1alpha1_VolumeSpec(ref common.ReferenceCallback) co
 	}
 }
 
+func schema_pkg_apis_devops_v1alpha1_s2iBuilderValidator(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "s2iBuilderValidator validates the s2ibuilders as webhook.Validator does, and adds the admission warnings of the deprecated fields to the allowed responses.",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"validator": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("sigs.k8s.io/controller-runtime/pkg/webhook/admission.Handler"),
+						},
+					},
+					"decoder": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("sigs.k8s.io/controller-runtime/pkg/webhook/admission.Decoder"),
+						},
+					},
+				},
+				Required: []string{"validator", "decoder"},
+			},
+		},
+		Dependencies: []string{
+			"sigs.k8s.io/controller-runtime/pkg/webhook/admission.Decoder", "sigs.k8s.io/controller-runtime/pkg/webhook/admission.Handler"},
+	}
+}
+
 func schema_k8sio_api_core_v1_AWSElasticBlockStoreVolumeSource(ref common.ReferenceCallback) common.OpenAPIDefinition {
 	return common.OpenAPIDefinition{
 		Schema: spec.Schema{
@@ -17,9 +17,11 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"encoding/json"
 	"fmt"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -44,10 +46,13 @@ const (
 const (
 	AutoScaleAnnotations             = "devops.kubesphere.io/autoscale"
 	S2iRunLabel                      = "devops.kubesphere.io/s2ir"
+	S2iBuilderLabel                  = "devops.kubesphere.io/s2ib"
 	S2irCompletedScaleAnnotations    = "devops.kubesphere.io/completedscale"
 	WorkLoadCompletedInitAnnotations = "devops.kubesphere.io/inithasbeencomplted"
 	S2iRunDoNotAutoScaleAnnotations  = "devops.kubesphere.io/donotautoscale"
+	PurgeBuildCacheAnnotations       = "devops.kubesphere.io/purgebuildcache"
 	DescriptionAnnotations           = "desc"
+	TriggerRateLimitAnnotations      = "devops.kubesphere.io/trigger-rate-limit"
 )
 const (
 	KindDeployment  = "Deployment"
@@ -60,6 +65,21 @@ type EnvironmentSpec struct {
 	Value string `json:"value"`
 }
 
+// S2iHook is a container which runs before or after the image is built.
+type S2iHook struct {
+	// Name of the hook, it should be unique in the pre-build or post-build hooks.
+	Name string `json:"name"`
+	// Image of the hook container. It is required by pre-build hooks, and post-build hooks
+	// use the built image if not set.
+	Image string `json:"image,omitempty"`
+	// Command overrides the entrypoint of the image.
+	Command []string `json:"command,omitempty"`
+	// Args are the arguments to the entrypoint.
+	Args []string `json:"args,omitempty"`
+	// Env is a list of environment variables to set in the hook container.
+	Env []EnvironmentSpec `json:"env,omitempty"`
+}
+
 // ProxyConfig holds proxy configuration.
 type ProxyConfig struct {
 	HTTPProxy  string `json:"httpProxy,omitempty"`
@@ -420,14 +440,32 @@ type S2iConfig struct {
 	// The values of Node Affinity.
 	NodeAffinityValues []string `json:"nodeAffinityValues,omitempty"`
 
+	// Platforms are the target platforms of the image such as linux/amd64 and linux/arm64/v8.
+	// If set, the image is built on nodes of each platform and pushed as a manifest list.
+	Platforms []string `json:"platforms,omitempty"`
+
+	// PreBuildHooks run in order against the cloned source before the image is built,
+	// the image is built from the same source after all of them succeed.
+	PreBuildHooks []S2iHook `json:"preBuildHooks,omitempty"`
+
+	// PostBuildHooks run in order after the image is built and pushed.
+	PostBuildHooks []S2iHook `json:"postBuildHooks,omitempty"`
+
 	// Whether output build result to status.
 	OutputBuildResult bool `json:"outputBuildResult,omitempty"`
 
 	// Regular expressions, ignoring names that do not match the provided regular expression
 	BranchExpression string `json:"branchExpression,omitempty"`
 
-	// SecretCode
+	// SecretCode authorizes the triggers of the s2ibuilder.
+	// Deprecated: it is readable by anyone who can get the s2ibuilder, use TriggerSecretRef instead.
 	SecretCode string `json:"secretCode,omitempty"`
+
+	// TriggerSecretRef selects a key of a Secret in the namespace of the s2ibuilder whose value holds the secret
+	// codes of the triggers, one per line. All of the codes are active, so that a new code can be added before
+	// the old one is removed. The github triggers are authorized by the X-Hub-Signature-256 of the events, which
+	// are signed with one of the codes as the secret of the github webhook.
+	TriggerSecretRef *corev1.SecretKeySelector `json:"triggerSecretRef,omitempty"`
 }
 
 type UserDefineTemplate struct {
@@ -439,6 +477,33 @@ type UserDefineTemplate struct {
 	BuilderImage string `json:"builderImage,omitempty"`
 }
 
+// S2iBuildCache describes a persistent volume which is shared by all runs of a builder,
+// it is used to keep caches like maven or npm repositories between builds. The size, storage class and
+// access modes can not be changed while the volume exists unless the cache is purged in the same update.
+type S2iBuildCache struct {
+	// Size is the requested storage size of the cache volume.
+	Size resource.Quantity `json:"size"`
+	// StorageClassName is the storage class of the cache volume, the cluster default is used if not set.
+	StorageClassName *string `json:"storageClassName,omitempty"`
+	// AccessModes of the cache volume, default is ReadWriteOnce.
+	AccessModes []corev1.PersistentVolumeAccessMode `json:"accessModes,omitempty"`
+	// Paths are the directories in the build container which are backed by the cache volume.
+	Paths []string `json:"paths"`
+}
+
+// S2iBuilderDeletionPolicy decides what happens to the s2iruns of a builder when the builder is deleted.
+// +kubebuilder:validation:Enum=Delete;Orphan;Archive
+type S2iBuilderDeletionPolicy string
+
+const (
+	// DeletionPolicyDelete deletes the s2iruns with the builder.
+	DeletionPolicyDelete S2iBuilderDeletionPolicy = "Delete"
+	// DeletionPolicyOrphan keeps the s2iruns, they are counted again by a new builder of the same name.
+	DeletionPolicyOrphan S2iBuilderDeletionPolicy = "Orphan"
+	// DeletionPolicyArchive keeps a summary of the s2iruns in a ConfigMap, then deletes them.
+	DeletionPolicyArchive S2iBuilderDeletionPolicy = "Archive"
+)
+
 // S2iBuilderSpec defines the desired state of S2iBuilder
 type S2iBuilderSpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
@@ -446,6 +511,15 @@ type S2iBuilderSpec struct {
 	Config *S2iConfig `json:"config,omitempty"`
 	//FromTemplate define some inputs from user
 	FromTemplate *UserDefineTemplate `json:"fromTemplate,omitempty"`
+	//Cache define a persistent build cache of this builder, the cache can be purged
+	//by the annotation devops.kubesphere.io/purgebuildcache
+	Cache *S2iBuildCache `json:"cache,omitempty"`
+	//DeletionPolicy decides what happens to the s2iruns of this builder when it is deleted, default is Delete.
+	//The summary of the s2iruns is kept in the ConfigMap <builder name>-archive by Archive.
+	DeletionPolicy S2iBuilderDeletionPolicy `json:"deletionPolicy,omitempty"`
+	//DeployTargets are the workloads updated to the image built by the s2iruns of this builder, it replaces
+	//the annotation devops.kubesphere.io/autoscale.
+	DeployTargets []S2iDeployTarget `json:"deployTargets,omitempty"`
 }
 
 // S2iBuilderStatus defines the observed state of S2iBuilder
@@ -458,6 +532,8 @@ type S2iBuilderStatus struct {
 	LastRunName *string `json:"lastRunName,omitempty"`
 	//LastRunStartTime return the startTime of the newest run of this builder
 	LastRunStartTime *metav1.Time `json:"lastRunStartTime,omitempty"`
+	//CacheClaimName return the name of the PersistentVolumeClaim used as build cache
+	CacheClaimName string `json:"cacheClaimName,omitempty"`
 }
 
 // +genclient
@@ -488,6 +564,41 @@ type S2iBuilderList struct {
 	Items           []S2iBuilder `json:"items"`
 }
 
+// GetBuildCacheClaimName returns the name of PersistentVolumeClaim which holds the build cache of the builder.
+func (r *S2iBuilder) GetBuildCacheClaimName() string {
+	return r.Name + "-build-cache"
+}
+
+// GetArchiveConfigMapName returns the name of ConfigMap which keeps the summary of the s2iruns of the builder
+// after it is deleted with the deletion policy Archive.
+func (r *S2iBuilder) GetArchiveConfigMapName() string {
+	return r.Name + "-archive"
+}
+
+// S2iDeployTarget is a workload in the namespace of the builder, the image of its pod template is updated
+// after each s2irun of the builder.
+type S2iDeployTarget struct {
+	// APIVersion of the workload, it is required by the custom resources, e.g. serving.knative.dev/v1 of
+	// the Services of Knative. The built-in kinds are in apps/v1 and batch/v1 by default, except batch/v1beta1
+	// of CronJob.
+	APIVersion string `json:"apiVersion,omitempty"`
+	// Kind of the workload, Deployment, StatefulSet, DaemonSet, CronJob, Job or the kind of a custom resource.
+	// The pod template of Job is immutable, the Job is created again by each s2irun.
+	Kind string `json:"kind"`
+	// Name of the workload
+	Name string `json:"name"`
+	// InitReplicas is set to the workload of 0 replicas after the first successful s2irun, default is 1
+	// +kubebuilder:validation:Minimum=0
+	InitReplicas *int32 `json:"initReplicas,omitempty"`
+	// Containers are the names of the containers updated, it is required if the pod template has more than one
+	Containers []string `json:"containers,omitempty"`
+	// ContainersPath is the JSONPath to the containers of the custom resource, only the fields are supported,
+	// default is {.spec.template.spec.containers}
+	ContainersPath string `json:"containersPath,omitempty"`
+}
+
+// S2iAutoScale is a target of the annotation devops.kubesphere.io/autoscale, it is decoded as S2iDeployTarget.
+// Deprecated: use S2iDeployTarget in spec.deployTargets instead.
 type S2iAutoScale struct {
 	Kind         string   `json:"kind"`
 	Name         string   `json:"name"`
@@ -495,6 +606,39 @@ type S2iAutoScale struct {
 	Containers   []string `json:"containers,omitempty"`
 }
 
+// GetDeployTargets returns spec.deployTargets, or the targets of the annotation devops.kubesphere.io/autoscale
+// if the builder is not migrated yet.
+func (r *S2iBuilder) GetDeployTargets() ([]S2iDeployTarget, error) {
+	if len(r.Spec.DeployTargets) != 0 {
+		return r.Spec.DeployTargets, nil
+	}
+	annotation, ok := r.Annotations[AutoScaleAnnotations]
+	if !ok {
+		return nil, nil
+	}
+	targets := make([]S2iDeployTarget, 0)
+	if err := json.Unmarshal([]byte(annotation), &targets); err != nil {
+		return nil, err
+	}
+	return targets, nil
+}
+
+// MigrateDeployTargets moves the targets of the annotation devops.kubesphere.io/autoscale to spec.deployTargets,
+// the annotation is dropped if spec.deployTargets is already set. It returns true if the builder is changed,
+// an invalid annotation is kept and returned as an error.
+func (r *S2iBuilder) MigrateDeployTargets() (bool, error) {
+	if _, ok := r.Annotations[AutoScaleAnnotations]; !ok {
+		return false, nil
+	}
+	targets, err := r.GetDeployTargets()
+	if err != nil {
+		return false, err
+	}
+	r.Spec.DeployTargets = targets
+	delete(r.Annotations, AutoScaleAnnotations)
+	return true, nil
+}
+
 type DockerConfigJson struct {
 	Auths DockerConfigMap `json:"auths"`
 }
@@ -18,30 +18,45 @@ package v1alpha1
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"path"
+	"reflect"
+	"regexp"
 	"strings"
 
 	"github.com/kubesphere/s2ioperator/pkg/errors"
 	"github.com/kubesphere/s2ioperator/pkg/util/reflectutils"
+	"github.com/kubesphere/s2ioperator/pkg/workload"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
 	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	errorutil "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
 const (
 	DefaultRevisionId = "master"
 	DefaultTag        = "latest"
+
+	s2iBuilderValidatingPath = "/validate-devops-kubesphere-io-v1alpha1-s2ibuilder"
 )
 
+var platformPartRegexp = regexp.MustCompile(`^[a-z0-9]+$`)
+
 // log is for logging in this package.
 var s2ibuilderlog = ctrl.Log.WithName("s2ibuilder-resource")
 
 func (r *S2iBuilder) SetupWebhookWithManager(mgr ctrl.Manager) error {
 	kclient = mgr.GetClient()
+	// the builder skips the path which is already registered, so the validating webhook is registered here to
+	// warn the deprecated fields
+	mgr.GetWebhookServer().Register(s2iBuilderValidatingPath, &webhook.Admission{Handler: newS2iBuilderValidator()})
 	return ctrl.NewWebhookManagedBy(mgr).
 		For(r).
 		Complete()
@@ -65,6 +80,11 @@ func (r *S2iBuilder) Default() {
 		r.Spec.Config.Tag = DefaultTag
 	}
 
+	// the invalid annotation is kept, it is rejected by the validation
+	if _, err := r.MigrateDeployTargets(); err != nil {
+		s2ibuilderlog.Info("skip migrating the invalid annotation", "name", r.Name, "annotation", AutoScaleAnnotations)
+	}
+
 	// TODO(user): fill in your defaulting logic.
 }
 
@@ -73,6 +93,52 @@ func (r *S2iBuilder) Default() {
 
 var _ webhook.Validator = &S2iBuilder{}
 
+// s2iBuilderValidator validates the s2ibuilders as webhook.Validator does, and adds the admission warnings
+// of the deprecated fields to the allowed responses.
+type s2iBuilderValidator struct {
+	validator admission.Handler
+	decoder   *admission.Decoder
+}
+
+func newS2iBuilderValidator() *s2iBuilderValidator {
+	return &s2iBuilderValidator{validator: admission.ValidatingWebhookFor(&S2iBuilder{}).Handler}
+}
+
+var _ admission.DecoderInjector = &s2iBuilderValidator{}
+
+// InjectDecoder injects the decoder into the validator and the wrapped handler.
+func (v *s2iBuilderValidator) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	_, err := admission.InjectDecoderInto(d, v.validator)
+	return err
+}
+
+// Handle handles the admission requests of s2ibuilders.
+func (v *s2iBuilderValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	resp := v.validator.Handle(ctx, req)
+	if !resp.Allowed || (req.Operation != admissionv1.Create && req.Operation != admissionv1.Update) {
+		return resp
+	}
+	builder := &S2iBuilder{}
+	if err := v.decoder.Decode(req, builder); err != nil {
+		return resp
+	}
+	if warnings := builder.warnings(); len(warnings) != 0 {
+		return resp.WithWarnings(warnings...)
+	}
+	return resp
+}
+
+// warnings returns the warnings of the deprecated fields in use.
+func (r *S2iBuilder) warnings() []string {
+	warnings := make([]string, 0)
+	if r.Spec.Config != nil && r.Spec.Config.SecretCode != "" {
+		warnings = append(warnings, "spec.config.secretCode is deprecated, it is readable by anyone who can get "+
+			"the s2ibuilder, use spec.config.triggerSecretRef instead")
+	}
+	return warnings
+}
+
 // ValidateCreate implements webhook.Validator so a webhook will be registered for the type
 func (r *S2iBuilder) ValidateCreate() error {
 	s2ibuilderlog.Info("validate create", "name", r.Name)
@@ -104,9 +170,16 @@ func (r *S2iBuilder) ValidateCreate() error {
 		}
 		fromTemplate = true
 	}
-	if anno, ok := r.Annotations[AutoScaleAnnotations]; ok {
-		if err := validatingS2iBuilderAutoScale(anno); err != nil {
-			return errors.NewFieldInvalidValueWithReason(AutoScaleAnnotations, err.Error())
+	targets, err := r.GetDeployTargets()
+	if err != nil {
+		return errors.NewFieldInvalidValueWithReason(AutoScaleAnnotations, err.Error())
+	}
+	if errs := validateDeployTargets(r.Namespace, targets, nil); len(errs) != 0 {
+		return errorutil.NewAggregate(errs)
+	}
+	if r.Spec.Cache != nil {
+		if errs := validateBuildCache(r.Spec.Cache); len(errs) != 0 {
+			return errorutil.NewAggregate(errs)
 		}
 	}
 	if errs := validateConfig(r.Spec.Config, fromTemplate); len(errs) == 0 {
@@ -149,9 +222,21 @@ func (r *S2iBuilder) ValidateUpdate(old runtime.Object) error {
 		}
 		fromTemplate = true
 	}
-	if anno, ok := r.Annotations[AutoScaleAnnotations]; ok {
-		if err := validatingS2iBuilderAutoScale(anno); err != nil {
-			return errors.NewFieldInvalidValueWithReason(AutoScaleAnnotations, err.Error())
+	targets, err := r.GetDeployTargets()
+	if err != nil {
+		return errors.NewFieldInvalidValueWithReason(AutoScaleAnnotations, err.Error())
+	}
+	// the workloads of the unchanged targets are not checked again, they might be deleted since
+	oldTargets, _ := old.(*S2iBuilder).GetDeployTargets()
+	if errs := validateDeployTargets(r.Namespace, targets, oldTargets); len(errs) != 0 {
+		return errorutil.NewAggregate(errs)
+	}
+	if r.Spec.Cache != nil {
+		if errs := validateBuildCache(r.Spec.Cache); len(errs) != 0 {
+			return errorutil.NewAggregate(errs)
+		}
+		if errs := validateBuildCacheUpdate(r, old.(*S2iBuilder)); len(errs) != 0 {
+			return errorutil.NewAggregate(errs)
 		}
 	}
 	if errs := validateConfig(r.Spec.Config, fromTemplate); len(errs) == 0 {
@@ -198,6 +283,22 @@ func validateConfig(config *S2iConfig, fromTemplate bool) []error {
 			allErrs = append(allErrs, errors.NewFieldInvalidValueWithReason("builderImage", err.Error()))
 		}
 	}
+	if len(config.Platforms) != 0 {
+		allErrs = append(allErrs, validatePlatforms(config.Platforms)...)
+	}
+	allErrs = append(allErrs, validateHooks("preBuildHooks", config.PreBuildHooks, true)...)
+	allErrs = append(allErrs, validateHooks("postBuildHooks", config.PostBuildHooks, false)...)
+	if len(config.PreBuildHooks) != 0 && config.IsBinaryURL {
+		allErrs = append(allErrs, errors.NewFieldInvalidValueWithReason("preBuildHooks", "b2i does not support pre-build hooks"))
+	}
+	if config.TriggerSecretRef != nil {
+		if config.TriggerSecretRef.Name == "" {
+			allErrs = append(allErrs, errors.NewFieldRequired("triggerSecretRef.name"))
+		}
+		if config.TriggerSecretRef.Key == "" {
+			allErrs = append(allErrs, errors.NewFieldRequired("triggerSecretRef.key"))
+		}
+	}
 	if config.RuntimeAuthentication != nil {
 		if config.RuntimeAuthentication.SecretRef == nil {
 			if config.RuntimeAuthentication.Username == "" && config.RuntimeAuthentication.Password == "" {
@@ -264,20 +365,191 @@ func validateParameter(user, tmpt []Parameter) []error {
 	return allErrs
 }
 
-func validatingS2iBuilderAutoScale(anno string) error {
+// validateHooks checks the hooks have unique names which are valid in container names, the image is
+// required if the hook could not use the built image.
+func validateHooks(field string, hooks []S2iHook, imageRequired bool) []error {
+	allErrs := make([]error, 0)
+	names := make(map[string]bool)
+	for _, hook := range hooks {
+		// the hook runs in container named pre-build-<name> or post-build-<name>
+		if msgs := validation.IsDNS1123Label("post-build-" + hook.Name); hook.Name == "" || len(msgs) != 0 {
+			allErrs = append(allErrs, errors.NewFieldInvalidValueWithReason(field+".name",
+				fmt.Sprintf("name [%s] should be a DNS label no longer than 52 characters", hook.Name)))
+		} else if names[hook.Name] {
+			allErrs = append(allErrs, errors.NewFieldInvalidValueWithReason(field+".name",
+				fmt.Sprintf("name [%s] is duplicated", hook.Name)))
+		}
+		names[hook.Name] = true
+		if hook.Image == "" {
+			if imageRequired {
+				allErrs = append(allErrs, errors.NewFieldRequired(field+".image"))
+			}
+		} else if err := validateDockerReference(hook.Image); err != nil {
+			allErrs = append(allErrs, errors.NewFieldInvalidValueWithReason(field+".image", err.Error()))
+		}
+		for _, env := range hook.Env {
+			if len(env.Name) == 0 {
+				allErrs = append(allErrs, errors.NewFieldRequired(field+".env.name"))
+			}
+		}
+	}
+	return allErrs
+}
+
+// validatePlatforms checks the platforms are in the form of linux/<arch>[/<variant>] without duplication
+func validatePlatforms(platforms []string) []error {
+	allErrs := make([]error, 0)
+	seen := make(map[string]bool)
+	for _, platform := range platforms {
+		parts := strings.Split(platform, "/")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] != "linux" || !platformPartRegexp.MatchString(parts[1]) ||
+			(len(parts) == 3 && !platformPartRegexp.MatchString(parts[2])) {
+			allErrs = append(allErrs, errors.NewFieldInvalidValueWithReason("platforms",
+				fmt.Sprintf("platform [%s] should be in the form of linux/<arch>[/<variant>]", platform)))
+			continue
+		}
+		if seen[platform] {
+			allErrs = append(allErrs, errors.NewFieldInvalidValueWithReason("platforms",
+				fmt.Sprintf("platform [%s] is duplicated", platform)))
+		}
+		seen[platform] = true
+	}
+	return allErrs
+}
+
+func validateBuildCache(cache *S2iBuildCache) []error {
+	allErrs := make([]error, 0)
+	if cache.Size.Sign() <= 0 {
+		allErrs = append(allErrs, errors.NewFieldInvalidValueWithReason("cache.size", "should be greater than 0"))
+	}
+	if len(cache.Paths) == 0 {
+		allErrs = append(allErrs, errors.NewFieldRequired("cache.paths"))
+	}
+	for _, p := range cache.Paths {
+		if !path.IsAbs(p) || path.Clean(p) == "/" {
+			allErrs = append(allErrs, errors.NewFieldInvalidValueWithReason("cache.paths",
+				fmt.Sprintf("path [%s] should be an absolute path other than /", p)))
+		}
+	}
+	return allErrs
+}
+
+// validateBuildCacheUpdate rejects the changes of spec.cache which can not be applied to the existing build cache
+// claim, they are only allowed together with the purge annotation, the claim is then recreated from the new spec.
+func validateBuildCacheUpdate(r, old *S2iBuilder) []error {
+	allErrs := make([]error, 0)
+	if old.Spec.Cache == nil {
+		return allErrs
+	}
+	if _, ok := r.Annotations[PurgeBuildCacheAnnotations]; ok {
+		return allErrs
+	}
+	claim := &corev1.PersistentVolumeClaim{}
+	err := kclient.Get(context.TODO(), types.NamespacedName{Namespace: r.Namespace, Name: r.GetBuildCacheClaimName()}, claim)
+	if err != nil {
+		if !k8serror.IsNotFound(err) {
+			allErrs = append(allErrs, err)
+		}
+		return allErrs
+	}
+	reason := fmt.Sprintf("can not be changed while the build cache claim %s exists, set the annotation %s to purge the cache",
+		claim.Name, PurgeBuildCacheAnnotations)
+	if r.Spec.Cache.Size.Cmp(old.Spec.Cache.Size) != 0 {
+		allErrs = append(allErrs, errors.NewFieldInvalidValueWithReason("cache.size", reason))
+	}
+	if !reflect.DeepEqual(r.Spec.Cache.StorageClassName, old.Spec.Cache.StorageClassName) {
+		allErrs = append(allErrs, errors.NewFieldInvalidValueWithReason("cache.storageClassName", reason))
+	}
+	if !reflect.DeepEqual(r.Spec.Cache.AccessModes, old.Spec.Cache.AccessModes) {
+		allErrs = append(allErrs, errors.NewFieldInvalidValueWithReason("cache.accessModes", reason))
+	}
+	return allErrs
+}
 
-	s2iAutoScale := make([]S2iAutoScale, 0)
-	if err := json.Unmarshal([]byte(anno), &s2iAutoScale); err != nil {
+// validateDeployTargets validates all the targets, the workloads of the targets which are not in oldTargets should
+// exist in the namespace and have the containers of the targets.
+func validateDeployTargets(namespace string, targets, oldTargets []S2iDeployTarget) []error {
+	allErrs := make([]error, 0)
+	workloads := make(map[string]bool)
+	for i, target := range targets {
+		field := fmt.Sprintf("deployTargets[%d]", i)
+		updater, err := workload.For(target.APIVersion, target.Kind, target.ContainersPath)
+		if err != nil {
+			allErrs = append(allErrs, errors.NewFieldInvalidValueWithReason(field, err.Error()))
+			continue
+		}
+		if target.Name == "" {
+			allErrs = append(allErrs, errors.NewFieldRequired(field+".name"))
+			continue
+		}
+		if msgs := validation.IsDNS1123Subdomain(target.Name); len(msgs) != 0 {
+			allErrs = append(allErrs, errors.NewFieldInvalidValueWithReason(field+".name", strings.Join(msgs, ", ")))
+			continue
+		}
+		key := updater.GroupKind().String() + "/" + target.Name
+		if workloads[key] {
+			allErrs = append(allErrs, errors.NewFieldInvalidValueWithReason(field,
+				fmt.Sprintf("%s [%s] is duplicated", target.Kind, target.Name)))
+			continue
+		}
+		workloads[key] = true
+		if target.InitReplicas != nil && *target.InitReplicas < 0 {
+			allErrs = append(allErrs, errors.NewFieldInvalidValueWithReason(field+".initReplicas", "should not be negative"))
+		}
+		containers := make(map[string]bool)
+		for _, container := range target.Containers {
+			if containers[container] {
+				allErrs = append(allErrs, errors.NewFieldInvalidValueWithReason(field+".containers",
+					fmt.Sprintf("container [%s] is duplicated", container)))
+			}
+			containers[container] = true
+		}
+		if containsDeployTarget(oldTargets, target) {
+			continue
+		}
+		if err := validateDeployTargetWorkload(namespace, field, target, updater); err != nil {
+			allErrs = append(allErrs, err)
+		}
+	}
+	return allErrs
+}
+
+func containsDeployTarget(targets []S2iDeployTarget, target S2iDeployTarget) bool {
+	for _, t := range targets {
+		if reflect.DeepEqual(t, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateDeployTargetWorkload checks the workload of the target exists and has the containers of the target
+func validateDeployTargetWorkload(namespace, field string, target S2iDeployTarget, updater *workload.Updater) error {
+	w, err := updater.Get(context.TODO(), kclient, types.NamespacedName{Namespace: namespace, Name: target.Name})
+	if err != nil {
+		if k8serror.IsNotFound(err) {
+			return errors.NewFieldInvalidValueWithReason(field+".name",
+				fmt.Sprintf("%s [%s] not found in namespace [%s]", target.Kind, target.Name, namespace))
+		}
+		if meta.IsNoMatchError(err) {
+			return errors.NewFieldInvalidValueWithReason(field+".kind",
+				fmt.Sprintf("%s is not served by the cluster", updater.GroupVersionKind))
+		}
 		return err
 	}
-	for _, scale := range s2iAutoScale {
-		switch scale.Kind {
-		case KindStatefulSet:
-			return nil
-		case KindDeployment:
-			return nil
-		default:
-			return fmt.Errorf("unsupport workload type [%s], name [%s]", scale.Kind, scale.Name)
+
+	names, err := w.ContainerNames()
+	if err != nil {
+		return errors.NewFieldInvalidValueWithReason(field+".containersPath", err.Error())
+	}
+	if len(target.Containers) == 0 && len(names) > 1 {
+		return errors.NewFieldInvalidValueWithReason(field+".containers",
+			fmt.Sprintf("should be specified, %s [%s] has the containers %v", target.Kind, target.Name, names))
+	}
+	for _, container := range target.Containers {
+		if !reflectutils.Contains(container, names) {
+			return errors.NewFieldInvalidValueWithReason(field+".containers",
+				fmt.Sprintf("container [%s] not found in %s [%s], should be one of %v", container, target.Kind, target.Name, names))
 		}
 	}
 	return nil
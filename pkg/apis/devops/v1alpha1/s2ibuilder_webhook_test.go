@@ -0,0 +1,217 @@
+/*
+Copyright 2019 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/onsi/gomega"
+	admissionv1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestS2iBuilderValidatorWarnings(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	s := runtime.NewScheme()
+	g.Expect(AddToScheme(s)).NotTo(gomega.HaveOccurred())
+	decoder, err := admission.NewDecoder(s)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	validator := newS2iBuilderValidator()
+	g.Expect(validator.InjectDecoder(decoder)).NotTo(gomega.HaveOccurred())
+
+	request := func(config *S2iConfig) admission.Request {
+		builder := &S2iBuilder{
+			TypeMeta:   metav1.TypeMeta{APIVersion: SchemeGroupVersion.String(), Kind: ResourceKindS2iBuilder},
+			ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+			Spec:       S2iBuilderSpec{Config: config},
+		}
+		raw, err := json.Marshal(builder)
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+		return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: admissionv1.Create,
+			Object:    runtime.RawExtension{Raw: raw},
+		}}
+	}
+	newConfig := func() *S2iConfig {
+		return &S2iConfig{
+			SourceURL:         "https://github.com/kubesphere/devops-java-sample.git",
+			BuilderImage:      "kubesphere/java-8-centos7:v2.1.0",
+			BuilderPullPolicy: PullIfNotPresent,
+		}
+	}
+
+	config := newConfig()
+	config.SecretCode = "code"
+	resp := validator.Handle(context.TODO(), request(config))
+	g.Expect(resp.Allowed).To(gomega.BeTrue())
+	g.Expect(resp.Warnings).To(gomega.HaveLen(1))
+
+	config = newConfig()
+	config.TriggerSecretRef = &corev1.SecretKeySelector{
+		LocalObjectReference: corev1.LocalObjectReference{Name: "trigger"},
+		Key:                  "codes",
+	}
+	resp = validator.Handle(context.TODO(), request(config))
+	g.Expect(resp.Allowed).To(gomega.BeTrue())
+	g.Expect(resp.Warnings).To(gomega.BeEmpty())
+
+	config.TriggerSecretRef.Key = ""
+	resp = validator.Handle(context.TODO(), request(config))
+	g.Expect(resp.Allowed).To(gomega.BeFalse())
+}
+
+func TestValidateDeployTargets(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	s := runtime.NewScheme()
+	g.Expect(clientgoscheme.AddToScheme(s)).NotTo(gomega.HaveOccurred())
+	origin := kclient
+	defer func() { kclient = origin }()
+	kclient = fake.NewFakeClientWithScheme(s,
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "default"},
+			Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "hello"}, {Name: "sidecar"}}}}},
+		},
+		&appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "world", Namespace: "default"},
+			Spec: appsv1.StatefulSetSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "world"}}}}},
+		},
+		&appsv1.DaemonSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "default"},
+			Spec: appsv1.DaemonSetSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "hello"}}}}},
+		},
+	)
+	negative := int32(-1)
+
+	for _, test := range []struct {
+		targets []S2iDeployTarget
+		errs    int
+	}{
+		{targets: []S2iDeployTarget{{Kind: KindDeployment, Name: "hello", Containers: []string{"hello"}}, {Kind: KindStatefulSet, Name: "world"}}},
+		// all the targets are validated
+		{targets: []S2iDeployTarget{{Kind: KindStatefulSet, Name: "world"}, {Kind: "Rollout", Name: "hello"}, {Kind: KindDeployment}}, errs: 2},
+		{targets: []S2iDeployTarget{{Kind: "DaemonSet", Name: "hello"}, {Kind: "CronJob", Name: "hello"}}, errs: 1},
+		{targets: []S2iDeployTarget{{APIVersion: "argoproj.io/v1alpha1", Kind: "Rollout", Name: "hello", ContainersPath: "{.spec.containers[*]}"}}, errs: 1},
+		{targets: []S2iDeployTarget{{Kind: KindDeployment, Name: "hello", ContainersPath: "{.spec.template.spec.containers}"}}, errs: 1},
+		{targets: []S2iDeployTarget{{Kind: KindStatefulSet, Name: "world"}, {Kind: KindStatefulSet, Name: "world"}}, errs: 1},
+		{targets: []S2iDeployTarget{{Kind: KindStatefulSet, Name: "World"}}, errs: 1},
+		{targets: []S2iDeployTarget{{Kind: KindStatefulSet, Name: "world", InitReplicas: &negative}}, errs: 1},
+		{targets: []S2iDeployTarget{{Kind: KindStatefulSet, Name: "hello"}}, errs: 1},
+		{targets: []S2iDeployTarget{{Kind: KindDeployment, Name: "hello"}}, errs: 1},
+		{targets: []S2iDeployTarget{{Kind: KindDeployment, Name: "hello", Containers: []string{"hello", "world"}}}, errs: 1},
+		{targets: []S2iDeployTarget{{Kind: KindDeployment, Name: "hello", Containers: []string{"hello", "hello"}}}, errs: 1},
+	} {
+		errs := validateDeployTargets("default", test.targets, nil)
+		g.Expect(errs).To(gomega.HaveLen(test.errs), "targets %+v", test.targets)
+	}
+
+	// the workloads of the unchanged targets are not checked on update
+	deleted := []S2iDeployTarget{{Kind: KindDeployment, Name: "deleted"}}
+	g.Expect(validateDeployTargets("default", deleted, deleted)).To(gomega.BeEmpty())
+	g.Expect(validateDeployTargets("other", deleted, nil)).To(gomega.HaveLen(1))
+}
+
+func TestMigrateDeployTargets(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	builder := &S2iBuilder{ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "default", Annotations: map[string]string{
+		AutoScaleAnnotations: `[{"Kind": "Deployment","Name":"hello","initReplicas":3},{"kind":"StatefulSet","name":"world"}]`,
+	}}}
+	targets, err := builder.GetDeployTargets()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(targets).To(gomega.HaveLen(2))
+
+	changed, err := builder.MigrateDeployTargets()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(changed).To(gomega.BeTrue())
+	g.Expect(builder.Annotations).NotTo(gomega.HaveKey(AutoScaleAnnotations))
+	g.Expect(builder.Spec.DeployTargets).To(gomega.Equal(targets))
+	g.Expect(*builder.Spec.DeployTargets[0].InitReplicas).To(gomega.BeEquivalentTo(3))
+
+	changed, err = builder.MigrateDeployTargets()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(changed).To(gomega.BeFalse())
+
+	// the annotation is dropped if the spec is already set
+	builder.Annotations[AutoScaleAnnotations] = `[{"kind":"Deployment","name":"other"}]`
+	changed, err = builder.MigrateDeployTargets()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(changed).To(gomega.BeTrue())
+	g.Expect(builder.Spec.DeployTargets).To(gomega.Equal(targets))
+
+	// the invalid annotation is kept
+	invalid := &S2iBuilder{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{AutoScaleAnnotations: "hello"}}}
+	_, err = invalid.MigrateDeployTargets()
+	g.Expect(err).To(gomega.HaveOccurred())
+	g.Expect(invalid.Annotations).To(gomega.HaveKey(AutoScaleAnnotations))
+}
+
+func TestValidateBuildCacheUpdate(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	s := runtime.NewScheme()
+	g.Expect(clientgoscheme.AddToScheme(s)).NotTo(gomega.HaveOccurred())
+	origin := kclient
+	defer func() { kclient = origin }()
+	kclient = fake.NewFakeClientWithScheme(s, &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo-build-cache", Namespace: "default"},
+	})
+
+	standard := "standard"
+	newBuilder := func(name string, cache *S2iBuildCache) *S2iBuilder {
+		return &S2iBuilder{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       S2iBuilderSpec{Cache: cache},
+		}
+	}
+	newCache := func() *S2iBuildCache {
+		return &S2iBuildCache{Size: resource.MustParse("1Gi"), Paths: []string{"/root/.m2"}}
+	}
+	old := newBuilder("foo", newCache())
+
+	cache := newCache()
+	cache.Paths = []string{"/root/.npm"}
+	g.Expect(validateBuildCacheUpdate(newBuilder("foo", cache), old)).To(gomega.BeEmpty())
+
+	cache = newCache()
+	cache.Size = resource.MustParse("2Gi")
+	cache.StorageClassName = &standard
+	cache.AccessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany}
+	g.Expect(validateBuildCacheUpdate(newBuilder("foo", cache), old)).To(gomega.HaveLen(3))
+
+	// the claim is recreated from the new spec when the cache is purged
+	purged := newBuilder("foo", cache)
+	purged.Annotations = map[string]string{PurgeBuildCacheAnnotations: "true"}
+	g.Expect(validateBuildCacheUpdate(purged, old)).To(gomega.BeEmpty())
+
+	// the cache can be changed freely before the claim is created
+	g.Expect(validateBuildCacheUpdate(newBuilder("bar", cache), newBuilder("bar", newCache()))).To(gomega.BeEmpty())
+	g.Expect(validateBuildCacheUpdate(newBuilder("foo", cache), newBuilder("foo", nil))).To(gomega.BeEmpty())
+}
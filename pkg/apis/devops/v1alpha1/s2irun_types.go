@@ -17,6 +17,9 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"regexp"
+	"strings"
+
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -27,8 +30,32 @@ const (
 	ResourceKindS2iRun     = "S2iRun"
 	ResourceSingularS2iRun = "s2irun"
 	ResourcePluralS2iRun   = "s2iruns"
+
+	// S2iRunContainerName is the name of the container which builds the image in the jobs of s2iruns
+	S2iRunContainerName = "s2irun"
+)
+
+const (
+	// RerunAnnotation asks the controller to rerun the S2iRun as a new S2iRun, it is removed after the new
+	// S2iRun is created. The commit built by the S2iRun is pinned in the new S2iRun if the value is "pinned".
+	RerunAnnotation = "devops.kubesphere.io/rerun"
+	// RerunOfAnnotation is the name of the S2iRun which is rerun by this S2iRun.
+	RerunOfAnnotation = "devops.kubesphere.io/rerun-of"
+	// RerunPinned is the value of RerunAnnotation which pins the commit in the new S2iRun.
+	RerunPinned = "pinned"
+
+	// TriggerSourceAnnotation is the trigger which creates the S2iRun, e.g. general or github.
+	TriggerSourceAnnotation = "devops.kubesphere.io/trigger-source"
+	TriggerSourceGeneral    = "general"
+	TriggerSourceGithub     = "github"
+	// TriggerSourceRerun is the source of the S2iRun which reruns another one.
+	TriggerSourceRerun = "rerun"
+	// TriggerSourceManual is the source of the S2iRun created without a trigger.
+	TriggerSourceManual = "manual"
 )
 
+var matrixValueNameRegexp = regexp.MustCompile(`[^a-z0-9]+`)
+
 // S2iRunSpec defines the desired state of S2iRun
 type S2iRunSpec struct {
 	//BuilderName specify the name of s2ibuilder, required
@@ -43,6 +70,125 @@ type S2iRunSpec struct {
 	NewRevisionId string `json:"newRevisionId,omitempty"`
 	//NewSourceURL is used to download new binary artifacts
 	NewSourceURL string `json:"newSourceURL,omitempty"`
+	//Matrix builds an image for each combination of the builder images and parameter values,
+	//the tag of each image has the name of the combination as suffix, e.g. latest-java11. The deploy targets of
+	//the builder are not updated by the runs with a matrix.
+	Matrix *S2iBuildMatrix `json:"matrix,omitempty"`
+	//PinResolvedSource makes the reruns of the run build the commit resolved by it. The config of a build is
+	//frozen once it is created, the configs created after the source is resolved use the commit and the builder
+	//and runtime image digests resolved by the run, so that they build the same inputs.
+	PinResolvedSource bool `json:"pinResolvedSource,omitempty"`
+}
+
+type S2iBuildMatrix struct {
+	// BuilderImages are the builder images to build with.
+	BuilderImages []S2iMatrixBuilderImage `json:"builderImages,omitempty"`
+	// Parameters are the template parameters or environments to build with, every parameter is an axis of the matrix.
+	Parameters []S2iMatrixParameter `json:"parameters,omitempty"`
+}
+
+type S2iMatrixBuilderImage struct {
+	// Name is used in the tag of the image built with the builder image, e.g. java11
+	Name string `json:"name"`
+	// BuilderImage describes which image is used for building the result images.
+	BuilderImage string `json:"builderImage"`
+	// RuntimeImage is the runtime image used with the builder image, it is found in the
+	// template of the builder if not set.
+	RuntimeImage string `json:"runtimeImage,omitempty"`
+}
+
+type S2iMatrixParameter struct {
+	// Key is the key of the template parameter or the name of the environment.
+	Key string `json:"key"`
+	// Values are the values to build with, the value is used in the tag of the image after
+	// the characters other than letters and digits are replaced with "-".
+	Values []string `json:"values"`
+}
+
+// S2iMatrixCell is a combination of the builder image and parameter values in the matrix.
+type S2iMatrixCell struct {
+	// Name is the suffix of the tag of the image built by the cell.
+	Name string `json:"name"`
+	// BuilderImage describes which image is used for building the result images.
+	BuilderImage string `json:"builderImage,omitempty"`
+	// RuntimeImage is the runtime image used with the builder image.
+	RuntimeImage string `json:"runtimeImage,omitempty"`
+	// Parameters are the values of the template parameters or environments.
+	Parameters []EnvironmentSpec `json:"parameters,omitempty"`
+}
+
+// Cells returns all the combinations in the matrix.
+func (m *S2iBuildMatrix) Cells() []S2iMatrixCell {
+	cells := []S2iMatrixCell{{}}
+	if len(m.BuilderImages) != 0 {
+		next := make([]S2iMatrixCell, 0, len(m.BuilderImages))
+		for _, image := range m.BuilderImages {
+			next = append(next, S2iMatrixCell{
+				Name:         image.Name,
+				BuilderImage: image.BuilderImage,
+				RuntimeImage: image.RuntimeImage,
+			})
+		}
+		cells = next
+	}
+	for _, parameter := range m.Parameters {
+		next := make([]S2iMatrixCell, 0, len(cells)*len(parameter.Values))
+		for _, cell := range cells {
+			for _, value := range parameter.Values {
+				c := *cell.DeepCopy()
+				c.Name = strings.TrimPrefix(c.Name+"-"+MatrixValueName(value), "-")
+				c.Parameters = append(c.Parameters, EnvironmentSpec{Name: parameter.Key, Value: value})
+				next = append(next, c)
+			}
+		}
+		cells = next
+	}
+	if len(cells) == 1 && cells[0].Name == "" {
+		return nil
+	}
+	return cells
+}
+
+// NewRerun returns a new S2iRun with the same inputs as the run, the commit which is built by the run is
+// used if pinRevision or PinResolvedSource of the run is true and the commit is known.
+func (r *S2iRun) NewRerun(pinRevision bool) *S2iRun {
+	rerun := &S2iRun{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: r.Name + "-rerun-",
+			Namespace:    r.Namespace,
+			Labels:       make(map[string]string),
+			Annotations: map[string]string{
+				RerunOfAnnotation: r.Name,
+			},
+		},
+		Spec: *r.Spec.DeepCopy(),
+	}
+	for k, v := range r.Labels {
+		rerun.Labels[k] = v
+	}
+	if desc, ok := r.Annotations[DescriptionAnnotations]; ok {
+		rerun.Annotations[DescriptionAnnotations] = desc
+	}
+	if (pinRevision || r.Spec.PinResolvedSource) && r.Status.S2iBuildSource != nil && r.Status.S2iBuildSource.CommitID != "" {
+		rerun.Spec.NewRevisionId = r.Status.S2iBuildSource.CommitID
+	}
+	return rerun
+}
+
+// TriggerSource returns how the S2iRun is created, which is the TriggerSourceAnnotation if it is set.
+func (r *S2iRun) TriggerSource() string {
+	if source, ok := r.Annotations[TriggerSourceAnnotation]; ok && source != "" {
+		return source
+	}
+	if _, ok := r.Annotations[RerunOfAnnotation]; ok {
+		return TriggerSourceRerun
+	}
+	return TriggerSourceManual
+}
+
+// MatrixValueName returns the name of a parameter value used in the cell name, e.g. 1.8 => 1-8
+func MatrixValueName(value string) string {
+	return strings.Trim(matrixValueNameRegexp.ReplaceAllString(strings.ToLower(value), "-"), "-")
 }
 
 // S2iRunStatus defines the observed state of S2iRun
@@ -66,6 +212,106 @@ type S2iRunStatus struct {
 	S2iBuildResult *S2iBuildResult `json:"s2iBuildResult,omitempty"`
 	// S2i build source info.
 	S2iBuildSource *S2iBuildSource `json:"s2iBuildSource,omitempty"`
+	// MatrixResults are the states and results of each cell if the run has a build matrix.
+	MatrixResults []S2iMatrixCellResult `json:"matrixResults,omitempty"`
+	// HookResults are the states of the pre-build and post-build hooks.
+	HookResults []S2iHookResult `json:"hookResults,omitempty"`
+	// Attempts is the number of pods the build job has started, it is greater than one if the build is retried.
+	Attempts int32 `json:"attempts,omitempty"`
+	// AttemptResults are the outcomes of the pods of the build job, ordered by the time they are created.
+	AttemptResults []S2iRunAttempt `json:"attemptResults,omitempty"`
+	// RerunOf is the name of the S2iRun which is rerun by this S2iRun.
+	RerunOf string `json:"rerunOf,omitempty"`
+	// ConfigSnapshotName is the immutable ConfigMap which keeps the effective config of the S2iRun, it is saved
+	// the first time the S2iRun is reconciled and the changes of the S2iBuilder after that do not affect the S2iRun.
+	ConfigSnapshotName string `json:"configSnapshotName,omitempty"`
+	// FailureReason is a brief CamelCase reason why the build failed or is stuck, e.g. ImagePullBackOff, OOMKilled, Error.
+	FailureReason string `json:"failureReason,omitempty"`
+	// FailureStage is the stage of the build which failed, one of Clone, PreBuild, Assemble, Commit and Push.
+	FailureStage BuildStage `json:"failureStage,omitempty"`
+	// FailureMessage is a human readable message about the failure, such as the exit code of the container.
+	FailureMessage string `json:"failureMessage,omitempty"`
+	// LogTail is the last lines of the log of the failed container.
+	LogTail string `json:"logTail,omitempty"`
+	// LogArchives are the locations where the logs of the build, manifest and post-build jobs are archived after
+	// the jobs are finished.
+	LogArchives []S2iLogArchive `json:"logArchives,omitempty"`
+}
+
+type S2iRunAttempt struct {
+	// PodName is the pod of the attempt
+	PodName string `json:"podName"`
+	// RunState indicates whether the attempt is done or failed
+	RunState RunState `json:"runState,omitempty"`
+	// LogURL is the log location of the pod
+	LogURL string `json:"logURL,omitempty"`
+	// StartTime and CompletionTime of the pod
+	StartTime      *metav1.Time `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+type S2iLogArchive struct {
+	// KubernetesJobName is the job whose log is archived
+	KubernetesJobName string `json:"kubernetesJobName"`
+	// PodName is the pod whose log is archived
+	PodName string `json:"podName,omitempty"`
+	// Sink is the type of the storage of the log, one of ConfigMap, Secret, PVC and S3.
+	Sink string `json:"sink"`
+	// Location of the log in the sink, such as the name of the ConfigMap, the path of the file or the url of the object.
+	Location string `json:"location"`
+	// Truncated is true if only the tail of the log is kept because of the size limit of the sink.
+	Truncated bool `json:"truncated,omitempty"`
+	// ArchiveTime is when the log is archived
+	ArchiveTime *metav1.Time `json:"archiveTime,omitempty"`
+}
+
+type BuildStage string
+
+const (
+	CloneStage    BuildStage = "Clone"
+	PreBuildStage BuildStage = "PreBuild"
+	AssembleStage BuildStage = "Assemble"
+	CommitStage   BuildStage = "Commit"
+	PushStage     BuildStage = "Push"
+)
+
+type HookStage string
+
+const (
+	PreBuild  HookStage = "PreBuild"
+	PostBuild HookStage = "PostBuild"
+)
+
+type S2iHookResult struct {
+	// Name of the hook
+	Name string `json:"name"`
+	// Stage is PreBuild or PostBuild
+	Stage HookStage `json:"stage"`
+	// KubernetesJobName is the job which runs the hook
+	KubernetesJobName string `json:"kubernetesJobName,omitempty"`
+	// RunState indicates whether the hook is done or failed
+	RunState RunState `json:"runState,omitempty"`
+	// ExitCode of the hook container
+	ExitCode int32 `json:"exitCode,omitempty"`
+	// Reason and Message of the termination of the hook container
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type S2iMatrixCellResult struct {
+	S2iMatrixCell `json:",inline"`
+	// RunState indicates whether the build of this cell is done or failed
+	RunState RunState `json:"runState,omitempty"`
+	// KubernetesJobName is the job which builds the image of this cell
+	KubernetesJobName string `json:"kubernetesJobName,omitempty"`
+	// LogURL is the log location of the job
+	LogURL string `json:"logURL,omitempty"`
+	// ImageName is the image built by this cell
+	ImageName string `json:"imageName,omitempty"`
+	// The size in bytes of the image
+	ImageSize int64 `json:"imageSize,omitempty"`
+	// Image ID.
+	ImageID string `json:"imageID,omitempty"`
 }
 
 type S2iBuildResult struct {
@@ -81,6 +327,25 @@ type S2iBuildResult struct {
 	ImageRepoTags []string `json:"imageRepoTags,omitempty"`
 	// Command for pull image.
 	CommandPull string `json:"commandPull,omitempty"`
+	// PlatformResults are the build results of each platform of a multi-platform image.
+	PlatformResults []S2iPlatformBuildResult `json:"platformResults,omitempty"`
+}
+
+type S2iPlatformBuildResult struct {
+	// Platform is the target platform of the build, e.g. linux/arm64
+	Platform string `json:"platform"`
+	// RunState indicates whether the build of this platform is done or failed
+	RunState RunState `json:"runState,omitempty"`
+	// KubernetesJobName is the job which builds the image of this platform
+	KubernetesJobName string `json:"kubernetesJobName,omitempty"`
+	// LogURL is the log location of the job
+	LogURL string `json:"logURL,omitempty"`
+	// ImageName is the platform specific image which is added to the manifest list
+	ImageName string `json:"imageName,omitempty"`
+	// The size in bytes of the image
+	ImageSize int64 `json:"imageSize,omitempty"`
+	// Image ID.
+	ImageID string `json:"imageID,omitempty"`
 }
 
 type S2iBuildSource struct {
@@ -95,6 +360,12 @@ type S2iBuildSource struct {
 
 	// // BuilderImage describes which image is used for building the result images.
 	BuilderImage string `json:"builderImage,omitempty"`
+	// BuilderImageDigest is the digest of the builder image which is used, e.g. sha256:...
+	BuilderImageDigest string `json:"builderImageDigest,omitempty"`
+	// RuntimeImage is the runtime image which is used if the build is a two-stage build.
+	RuntimeImage string `json:"runtimeImage,omitempty"`
+	// RuntimeImageDigest is the digest of the runtime image which is used.
+	RuntimeImageDigest string `json:"runtimeImageDigest,omitempty"`
 	// Description is a result image description label. The default is no
 	// description.
 	Description string `json:"description,omitempty"`
@@ -119,6 +390,7 @@ type S2iBuildSource struct {
 // +kubebuilder:printcolumn:name="StartTime",type="date",JSONPath=".status.startTime"
 // +kubebuilder:printcolumn:name="CompletionTime",type="date",JSONPath=".status.completionTime"
 // +kubebuilder:printcolumn:name="ImageName",type="string",JSONPath=".status.s2iBuildResult.imageName"
+// +kubebuilder:printcolumn:name="Reason",type="string",JSONPath=".status.failureReason",priority=1
 type S2iRun struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
@@ -56,3 +56,29 @@ func TestStorageS2iRun(t *testing.T) {
 	g.Expect(c.Delete(context.TODO(), fetched)).NotTo(gomega.HaveOccurred())
 	g.Expect(c.Get(context.TODO(), key, fetched)).To(gomega.HaveOccurred())
 }
+
+func TestS2iBuildMatrixCells(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	matrix := &S2iBuildMatrix{
+		BuilderImages: []S2iMatrixBuilderImage{
+			{Name: "java8", BuilderImage: "kubesphere/java-8-centos7"},
+			{Name: "java11", BuilderImage: "kubesphere/java-11-centos7"},
+		},
+		Parameters: []S2iMatrixParameter{
+			{Key: "MAVEN_PROFILE", Values: []string{"dev", "Prod.EU"}},
+		},
+	}
+	cells := matrix.Cells()
+	g.Expect(cells).To(gomega.HaveLen(4))
+	g.Expect(cells[0].Name).To(gomega.Equal("java8-dev"))
+	g.Expect(cells[3].Name).To(gomega.Equal("java11-prod-eu"))
+	g.Expect(cells[3].BuilderImage).To(gomega.Equal("kubesphere/java-11-centos7"))
+	g.Expect(cells[3].Parameters).To(gomega.Equal([]EnvironmentSpec{{Name: "MAVEN_PROFILE", Value: "Prod.EU"}}))
+
+	matrix.BuilderImages = nil
+	cells = matrix.Cells()
+	g.Expect(cells).To(gomega.HaveLen(2))
+	g.Expect(cells[1].Name).To(gomega.Equal("prod-eu"))
+
+	g.Expect((&S2iBuildMatrix{}).Cells()).To(gomega.BeEmpty())
+}
@@ -20,16 +20,33 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"regexp"
+	"strings"
 
 	"github.com/kubesphere/s2ioperator/pkg/errors"
+	"github.com/kubesphere/s2ioperator/pkg/util/reflectutils"
 	k8serror "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	errorutil "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 )
 
+// MaxMatrixCells is the max number of images could be built by a build matrix
+const MaxMatrixCells = 32
+
+// matrixJobNameOverhead is the length of the parts other than the names of the run and cell in the job name of
+// a cell, <run>-<the last part of uid>-<cell>-job, which is the value of the job-name label of its pods.
+const matrixJobNameOverhead = len("-xxxxxxxxxxxx-") + len("-job")
+
+// generatedNameSuffixLength is the length of the random suffix appended to generateName by apiserver
+const generatedNameSuffixLength = 5
+
+var matrixNameRegexp = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
 // log is for logging in this package.
 var (
 	s2irunlog = ctrl.Log.WithName("s2irun-resource")
@@ -66,6 +83,13 @@ func (r *S2iRun) ValidateCreate() error {
 		if r.Spec.NewSourceURL != "" && !builder.Spec.Config.IsBinaryURL {
 			return errors.NewFieldInvalidValueWithReason("newSourceURL", "only b2i could set newSourceURL")
 		}
+	} else {
+		builder = nil
+	}
+	if r.Spec.Matrix != nil {
+		if errs := validateMatrix(r.runName(), r.Spec.Matrix, builder); len(errs) != 0 {
+			return errorutil.NewAggregate(errs)
+		}
 	}
 
 	err = kclient.Get(context.TODO(), types.NamespacedName{Namespace: r.Namespace, Name: r.Name}, origin)
@@ -99,6 +123,13 @@ func (r *S2iRun) ValidateUpdate(old runtime.Object) error {
 		if r.Spec.NewSourceURL != "" && !builder.Spec.Config.IsBinaryURL {
 			return errors.NewFieldInvalidValueWithReason("newSourceURL", "only b2i could set newSourceURL")
 		}
+	} else {
+		builder = nil
+	}
+	if r.Spec.Matrix != nil {
+		if errs := validateMatrix(r.runName(), r.Spec.Matrix, builder); len(errs) != 0 {
+			return errorutil.NewAggregate(errs)
+		}
 	}
 
 	err = kclient.Get(context.TODO(), types.NamespacedName{Namespace: r.Namespace, Name: r.Name}, origin)
@@ -123,3 +154,97 @@ func (r *S2iRun) ValidateDelete() error {
 	// TODO(user): fill in your validation logic upon object deletion.
 	return nil
 }
+
+// runName returns the name of the run, or the longest name generated from its generateName
+func (r *S2iRun) runName() string {
+	if r.Name == "" && r.GenerateName != "" {
+		return r.GenerateName + strings.Repeat("x", generatedNameSuffixLength)
+	}
+	return r.Name
+}
+
+// validateMatrix checks the names of the cells are valid, unique and short enough to be in the job names of
+// the run, and the builder images are in the template if the builder uses a template. The builder is nil if
+// it is not created yet.
+func validateMatrix(runName string, matrix *S2iBuildMatrix, builder *S2iBuilder) []error {
+	allErrs := make([]error, 0)
+	if len(matrix.BuilderImages) == 0 && len(matrix.Parameters) == 0 {
+		return append(allErrs, errors.NewFieldRequired("matrix.builderImages|parameters"))
+	}
+	if builder != nil && len(builder.Spec.Config.Platforms) != 0 {
+		allErrs = append(allErrs, errors.NewFieldInvalidValueWithReason("matrix", "builder with platforms does not support matrix"))
+	}
+	if builder != nil && len(builder.Spec.Config.PostBuildHooks) != 0 {
+		allErrs = append(allErrs, errors.NewFieldInvalidValueWithReason("matrix", "builder with post-build hooks does not support matrix"))
+	}
+
+	var templateImages []string
+	if builder != nil && builder.Spec.FromTemplate != nil {
+		t := &S2iBuilderTemplate{}
+		if err := kclient.Get(context.TODO(), types.NamespacedName{Name: builder.Spec.FromTemplate.Name}, t); err != nil {
+			if !k8serror.IsNotFound(err) {
+				return append(allErrs, err)
+			}
+		}
+		for _, info := range t.Spec.ContainerInfo {
+			templateImages = append(templateImages, info.BuilderImage)
+		}
+	}
+	names := make(map[string]bool)
+	for _, image := range matrix.BuilderImages {
+		if !matrixNameRegexp.MatchString(image.Name) {
+			allErrs = append(allErrs, errors.NewFieldInvalidValueWithReason("matrix.builderImages.name",
+				fmt.Sprintf("name [%s] should consist of lower case alphanumeric characters or '-'", image.Name)))
+		} else if names[image.Name] {
+			allErrs = append(allErrs, errors.NewFieldInvalidValueWithReason("matrix.builderImages.name",
+				fmt.Sprintf("name [%s] is duplicated", image.Name)))
+		}
+		names[image.Name] = true
+		if err := validateDockerReference(image.BuilderImage); err != nil {
+			allErrs = append(allErrs, errors.NewFieldInvalidValueWithReason("matrix.builderImages.builderImage", err.Error()))
+		} else if templateImages != nil && !reflectutils.Contains(image.BuilderImage, templateImages) {
+			allErrs = append(allErrs, errors.NewFieldInvalidValueWithReason("matrix.builderImages.builderImage",
+				fmt.Sprintf("builder image [%s] not in template builder images [%v]", image.BuilderImage, templateImages)))
+		}
+	}
+
+	keys := make(map[string]bool)
+	for _, parameter := range matrix.Parameters {
+		if parameter.Key == "" {
+			allErrs = append(allErrs, errors.NewFieldRequired("matrix.parameters.key"))
+		} else if keys[parameter.Key] {
+			allErrs = append(allErrs, errors.NewFieldInvalidValueWithReason("matrix.parameters.key",
+				fmt.Sprintf("key [%s] is duplicated", parameter.Key)))
+		}
+		keys[parameter.Key] = true
+		if len(parameter.Values) == 0 {
+			allErrs = append(allErrs, errors.NewFieldRequired("matrix.parameters.values"))
+		}
+		valueNames := make(map[string]bool)
+		for _, value := range parameter.Values {
+			name := MatrixValueName(value)
+			if name == "" || valueNames[name] {
+				allErrs = append(allErrs, errors.NewFieldInvalidValueWithReason("matrix.parameters.values",
+					fmt.Sprintf("value [%s] of key [%s] has an empty or duplicated name [%s] in tag", value, parameter.Key, name)))
+			}
+			valueNames[name] = true
+		}
+	}
+	if len(allErrs) != 0 {
+		return allErrs
+	}
+	cells := matrix.Cells()
+	if len(cells) > MaxMatrixCells {
+		allErrs = append(allErrs, errors.NewFieldInvalidValueWithReason("matrix",
+			fmt.Sprintf("matrix has %d cells, which should not be more than %d", len(cells), MaxMatrixCells)))
+	}
+	maxNameLength := validation.LabelValueMaxLength - len(runName) - matrixJobNameOverhead
+	for _, cell := range cells {
+		if len(cell.Name) > maxNameLength {
+			allErrs = append(allErrs, errors.NewFieldInvalidValueWithReason("matrix",
+				fmt.Sprintf("name [%s] of the cell should not be longer than %d characters with the run name [%s]",
+					cell.Name, maxNameLength, runName)))
+		}
+	}
+	return allErrs
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2019 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestValidateMatrixOfBuilder(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	s := runtime.NewScheme()
+	g.Expect(AddToScheme(s)).NotTo(gomega.HaveOccurred())
+	newBuilder := func(name string, config S2iConfig) *S2iBuilder {
+		return &S2iBuilder{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       S2iBuilderSpec{Config: &config},
+		}
+	}
+	origin := kclient
+	defer func() { kclient = origin }()
+	kclient = fake.NewFakeClientWithScheme(s,
+		newBuilder("hello", S2iConfig{}),
+		newBuilder("platforms", S2iConfig{Platforms: []string{"linux/amd64", "linux/arm64"}}),
+		newBuilder("hooks", S2iConfig{PostBuildHooks: []S2iHook{{Name: "test"}}}),
+	)
+
+	newRun := func(builder string) *S2iRun {
+		return &S2iRun{
+			ObjectMeta: metav1.ObjectMeta{Name: "run", Namespace: "default"},
+			Spec: S2iRunSpec{BuilderName: builder, Matrix: &S2iBuildMatrix{
+				Parameters: []S2iMatrixParameter{{Key: "VERSION", Values: []string{"1", "2"}}},
+			}},
+		}
+	}
+	g.Expect(newRun("hello").ValidateCreate()).NotTo(gomega.HaveOccurred())
+	for _, builder := range []string{"platforms", "hooks"} {
+		g.Expect(newRun(builder).ValidateCreate()).To(gomega.HaveOccurred(), builder)
+		g.Expect(newRun(builder).ValidateUpdate(newRun(builder))).To(gomega.HaveOccurred(), builder)
+	}
+
+	// the job names of the cells are the values of the job-name labels
+	long := newRun("hello")
+	long.Spec.Matrix.Parameters[0].Values = []string{strings.Repeat("a", 42), "1"}
+	g.Expect(long.ValidateCreate()).NotTo(gomega.HaveOccurred())
+	long.Spec.Matrix.Parameters[0].Values[0] += "a"
+	g.Expect(long.ValidateCreate()).To(gomega.HaveOccurred())
+	generated := newRun("hello")
+	generated.Name, generated.GenerateName = "", "run-"
+	generated.Spec.Matrix.Parameters[0].Values = []string{strings.Repeat("a", 37)}
+	g.Expect(generated.ValidateCreate()).To(gomega.HaveOccurred())
+}
@@ -261,6 +261,64 @@ func (in *S2iAutoScale) DeepCopy() *S2iAutoScale {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *S2iBuildCache) DeepCopyInto(out *S2iBuildCache) {
+	*out = *in
+	out.Size = in.Size.DeepCopy()
+	if in.StorageClassName != nil {
+		in, out := &in.StorageClassName, &out.StorageClassName
+		*out = new(string)
+		**out = **in
+	}
+	if in.AccessModes != nil {
+		in, out := &in.AccessModes, &out.AccessModes
+		*out = make([]v1.PersistentVolumeAccessMode, len(*in))
+		copy(*out, *in)
+	}
+	if in.Paths != nil {
+		in, out := &in.Paths, &out.Paths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new S2iBuildCache.
+func (in *S2iBuildCache) DeepCopy() *S2iBuildCache {
+	if in == nil {
+		return nil
+	}
+	out := new(S2iBuildCache)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *S2iBuildMatrix) DeepCopyInto(out *S2iBuildMatrix) {
+	*out = *in
+	if in.BuilderImages != nil {
+		in, out := &in.BuilderImages, &out.BuilderImages
+		*out = make([]S2iMatrixBuilderImage, len(*in))
+		copy(*out, *in)
+	}
+	if in.Parameters != nil {
+		in, out := &in.Parameters, &out.Parameters
+		*out = make([]S2iMatrixParameter, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new S2iBuildMatrix.
+func (in *S2iBuildMatrix) DeepCopy() *S2iBuildMatrix {
+	if in == nil {
+		return nil
+	}
+	out := new(S2iBuildMatrix)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *S2iBuildResult) DeepCopyInto(out *S2iBuildResult) {
 	*out = *in
@@ -269,6 +327,11 @@ func (in *S2iBuildResult) DeepCopyInto(out *S2iBuildResult) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.PlatformResults != nil {
+		in, out := &in.PlatformResults, &out.PlatformResults
+		*out = make([]S2iPlatformBuildResult, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new S2iBuildResult.
@@ -368,6 +431,18 @@ func (in *S2iBuilderSpec) DeepCopyInto(out *S2iBuilderSpec) {
 		*out = new(UserDefineTemplate)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Cache != nil {
+		in, out := &in.Cache, &out.Cache
+		*out = new(S2iBuildCache)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DeployTargets != nil {
+		in, out := &in.DeployTargets, &out.DeployTargets
+		*out = make([]S2iDeployTarget, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new S2iBuilderSpec.
@@ -597,6 +672,30 @@ func (in *S2iConfig) DeepCopyInto(out *S2iConfig) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.Platforms != nil {
+		in, out := &in.Platforms, &out.Platforms
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PreBuildHooks != nil {
+		in, out := &in.PreBuildHooks, &out.PreBuildHooks
+		*out = make([]S2iHook, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PostBuildHooks != nil {
+		in, out := &in.PostBuildHooks, &out.PostBuildHooks
+		*out = make([]S2iHook, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.TriggerSecretRef != nil {
+		in, out := &in.TriggerSecretRef, &out.TriggerSecretRef
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new S2iConfig.
@@ -609,12 +708,187 @@ func (in *S2iConfig) DeepCopy() *S2iConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *S2iDeployTarget) DeepCopyInto(out *S2iDeployTarget) {
+	*out = *in
+	if in.InitReplicas != nil {
+		in, out := &in.InitReplicas, &out.InitReplicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Containers != nil {
+		in, out := &in.Containers, &out.Containers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new S2iDeployTarget.
+func (in *S2iDeployTarget) DeepCopy() *S2iDeployTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(S2iDeployTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *S2iHook) DeepCopyInto(out *S2iHook) {
+	*out = *in
+	if in.Command != nil {
+		in, out := &in.Command, &out.Command
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Args != nil {
+		in, out := &in.Args, &out.Args
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Env != nil {
+		in, out := &in.Env, &out.Env
+		*out = make([]EnvironmentSpec, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new S2iHook.
+func (in *S2iHook) DeepCopy() *S2iHook {
+	if in == nil {
+		return nil
+	}
+	out := new(S2iHook)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *S2iHookResult) DeepCopyInto(out *S2iHookResult) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new S2iHookResult.
+func (in *S2iHookResult) DeepCopy() *S2iHookResult {
+	if in == nil {
+		return nil
+	}
+	out := new(S2iHookResult)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *S2iLogArchive) DeepCopyInto(out *S2iLogArchive) {
+	*out = *in
+	if in.ArchiveTime != nil {
+		in, out := &in.ArchiveTime, &out.ArchiveTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new S2iLogArchive.
+func (in *S2iLogArchive) DeepCopy() *S2iLogArchive {
+	if in == nil {
+		return nil
+	}
+	out := new(S2iLogArchive)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *S2iMatrixBuilderImage) DeepCopyInto(out *S2iMatrixBuilderImage) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new S2iMatrixBuilderImage.
+func (in *S2iMatrixBuilderImage) DeepCopy() *S2iMatrixBuilderImage {
+	if in == nil {
+		return nil
+	}
+	out := new(S2iMatrixBuilderImage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *S2iMatrixCell) DeepCopyInto(out *S2iMatrixCell) {
+	*out = *in
+	if in.Parameters != nil {
+		in, out := &in.Parameters, &out.Parameters
+		*out = make([]EnvironmentSpec, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new S2iMatrixCell.
+func (in *S2iMatrixCell) DeepCopy() *S2iMatrixCell {
+	if in == nil {
+		return nil
+	}
+	out := new(S2iMatrixCell)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *S2iMatrixCellResult) DeepCopyInto(out *S2iMatrixCellResult) {
+	*out = *in
+	in.S2iMatrixCell.DeepCopyInto(&out.S2iMatrixCell)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new S2iMatrixCellResult.
+func (in *S2iMatrixCellResult) DeepCopy() *S2iMatrixCellResult {
+	if in == nil {
+		return nil
+	}
+	out := new(S2iMatrixCellResult)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *S2iMatrixParameter) DeepCopyInto(out *S2iMatrixParameter) {
+	*out = *in
+	if in.Values != nil {
+		in, out := &in.Values, &out.Values
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new S2iMatrixParameter.
+func (in *S2iMatrixParameter) DeepCopy() *S2iMatrixParameter {
+	if in == nil {
+		return nil
+	}
+	out := new(S2iMatrixParameter)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *S2iPlatformBuildResult) DeepCopyInto(out *S2iPlatformBuildResult) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new S2iPlatformBuildResult.
+func (in *S2iPlatformBuildResult) DeepCopy() *S2iPlatformBuildResult {
+	if in == nil {
+		return nil
+	}
+	out := new(S2iPlatformBuildResult)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *S2iRun) DeepCopyInto(out *S2iRun) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
 	in.Status.DeepCopyInto(&out.Status)
 }
 
@@ -636,6 +910,29 @@ func (in *S2iRun) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *S2iRunAttempt) DeepCopyInto(out *S2iRunAttempt) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new S2iRunAttempt.
+func (in *S2iRunAttempt) DeepCopy() *S2iRunAttempt {
+	if in == nil {
+		return nil
+	}
+	out := new(S2iRunAttempt)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *S2iRunList) DeepCopyInto(out *S2iRunList) {
 	*out = *in
@@ -671,6 +968,11 @@ func (in *S2iRunList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *S2iRunSpec) DeepCopyInto(out *S2iRunSpec) {
 	*out = *in
+	if in.Matrix != nil {
+		in, out := &in.Matrix, &out.Matrix
+		*out = new(S2iBuildMatrix)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new S2iRunSpec.
@@ -704,6 +1006,32 @@ func (in *S2iRunStatus) DeepCopyInto(out *S2iRunStatus) {
 		*out = new(S2iBuildSource)
 		**out = **in
 	}
+	if in.MatrixResults != nil {
+		in, out := &in.MatrixResults, &out.MatrixResults
+		*out = make([]S2iMatrixCellResult, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.HookResults != nil {
+		in, out := &in.HookResults, &out.HookResults
+		*out = make([]S2iHookResult, len(*in))
+		copy(*out, *in)
+	}
+	if in.AttemptResults != nil {
+		in, out := &in.AttemptResults, &out.AttemptResults
+		*out = make([]S2iRunAttempt, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LogArchives != nil {
+		in, out := &in.LogArchives, &out.LogArchives
+		*out = make([]S2iLogArchive, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new S2iRunStatus.
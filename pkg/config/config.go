@@ -0,0 +1,243 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+
+	"github.com/kubesphere/s2ioperator/pkg/config/v1alpha1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+)
+
+// NewDefaultConfig returns the config with the defaults of the config file
+func NewDefaultConfig() *Config {
+	c := &Config{}
+	file := &v1alpha1.S2iOperatorConfiguration{}
+	v1alpha1.SetDefaults(file)
+	convert(file, c)
+	return c
+}
+
+// LoadFile loads the config file into c, the fields which are not in the file are set to the defaults
+func LoadFile(path string, c *Config) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	file := &v1alpha1.S2iOperatorConfiguration{}
+	if err = yaml.UnmarshalStrict(data, file); err != nil {
+		return fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+	if file.APIVersion != v1alpha1.APIVersion || file.Kind != v1alpha1.Kind {
+		return fmt.Errorf("invalid config file %s: unsupported %s, %s of %s is expected", path,
+			file.GroupVersionKind(), v1alpha1.Kind, v1alpha1.APIVersion)
+	}
+	v1alpha1.SetDefaults(file)
+	convert(file, c)
+	return nil
+}
+
+// convert sets the config from the config file in v1alpha1
+func convert(in *v1alpha1.S2iOperatorConfiguration, out *Config) {
+	out.mu.Lock()
+	defer out.mu.Unlock()
+	out.MetricsAddress = in.MetricsAddress
+	out.HealthProbeAddress = in.HealthProbeAddress
+	out.WebhookPort = in.WebhookPort
+	out.LeaderElection = LeaderElectionConfig{
+		Enabled:       in.LeaderElection.LeaderElect,
+		Namespace:     in.LeaderElection.ResourceNamespace,
+		ID:            in.LeaderElection.ResourceName,
+		LeaseDuration: in.LeaderElection.LeaseDuration.Duration,
+		RenewDeadline: in.LeaderElection.RenewDeadline.Duration,
+		RetryPeriod:   in.LeaderElection.RetryPeriod.Duration,
+	}
+	out.Scope = ScopeConfig{
+		Namespaces:        in.Scope.Namespaces,
+		NamespaceSelector: in.Scope.NamespaceSelector,
+	}
+	out.S2IRunJobTemplate = in.Job.Template
+	out.S2IRunImage = in.Job.S2iRunImage
+	out.ManifestToolImage = in.Job.ManifestToolImage
+	out.GitCloneImage = in.Job.GitCloneImage
+	out.Scheduling = SchedulingConfig{
+		TaintKey:           in.Job.Scheduling.TaintKey,
+		NodeAffinityKey:    in.Job.Scheduling.NodeAffinityKey,
+		NodeAffinityValues: in.Job.Scheduling.NodeAffinityValues,
+	}
+	out.RBAC = RBACConfig{
+		ServiceAccountName: in.RBAC.ServiceAccountName,
+		RoleName:           in.RBAC.RoleName,
+		RoleBindingName:    in.RBAC.RoleBindingName,
+	}
+	out.LogURL = LogURLConfig{
+		Backend:    in.LogURL.Backend,
+		URL:        in.LogURL.URL,
+		Template:   in.LogURL.Template,
+		Datasource: in.LogURL.Datasource,
+	}
+	out.LogArchive = LogArchiveConfig{
+		Sink:      in.LogArchive.Sink,
+		MaxBytes:  in.LogArchive.MaxBytes,
+		Directory: in.LogArchive.Directory,
+		S3: S3Config{
+			Endpoint: in.LogArchive.S3.Endpoint,
+			Bucket:   in.LogArchive.S3.Bucket,
+			Region:   in.LogArchive.S3.Region,
+			Prefix:   in.LogArchive.S3.Prefix,
+		},
+	}
+	out.Trigger = TriggerConfig{
+		Address:         in.Trigger.Address,
+		CertFile:        in.Trigger.CertFile,
+		KeyFile:         in.Trigger.KeyFile,
+		ClientCAFile:    in.Trigger.ClientCAFile,
+		MaxRequestBytes: in.Trigger.MaxRequestBytes,
+		ReadTimeout:     in.Trigger.ReadTimeout.Duration,
+		WriteTimeout:    in.Trigger.WriteTimeout.Duration,
+		IdleTimeout:     in.Trigger.IdleTimeout.Duration,
+		ShutdownTimeout: in.Trigger.ShutdownTimeout.Duration,
+		RateLimit: RateLimitConfig{
+			BuilderQPS:        *in.Trigger.RateLimit.BuilderQPS,
+			BuilderBurst:      *in.Trigger.RateLimit.BuilderBurst,
+			IPQPS:             *in.Trigger.RateLimit.IPQPS,
+			IPBurst:           *in.Trigger.RateLimit.IPBurst,
+			TrustForwardedFor: in.Trigger.RateLimit.TrustForwardedFor,
+		},
+	}
+}
+
+// Validate checks the config at startup and before it is reloaded. The backend of the log url and the sink of
+// the log archive are checked when they are created.
+func (c *Config) Validate() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	allErrs := make([]error, 0)
+	if c.WebhookPort <= 0 || c.WebhookPort > 65535 {
+		allErrs = append(allErrs, fmt.Errorf("webhookPort %d should be in 1-65535", c.WebhookPort))
+	}
+	if election := c.LeaderElection; election.Enabled {
+		if msgs := validation.IsDNS1123Subdomain(election.ID); len(msgs) != 0 {
+			allErrs = append(allErrs, fmt.Errorf("leaderElection.resourceName %q is invalid: %v", election.ID, msgs))
+		}
+		if election.RetryPeriod <= 0 || election.RenewDeadline <= election.RetryPeriod || election.LeaseDuration <= election.RenewDeadline {
+			allErrs = append(allErrs, fmt.Errorf("leaderElection.leaseDuration should be greater than renewDeadline, "+
+				"which should be greater than retryPeriod"))
+		}
+	}
+	if len(c.Scope.Namespaces) != 0 && c.Scope.NamespaceSelector != "" {
+		allErrs = append(allErrs, fmt.Errorf("scope.namespaces and scope.namespaceSelector should not be set together"))
+	}
+	for _, namespace := range c.Scope.Namespaces {
+		if msgs := validation.IsDNS1123Label(namespace); len(msgs) != 0 {
+			allErrs = append(allErrs, fmt.Errorf("scope.namespaces %q is invalid: %v", namespace, msgs))
+		}
+	}
+	if _, err := labels.Parse(c.Scope.NamespaceSelector); err != nil {
+		allErrs = append(allErrs, fmt.Errorf("scope.namespaceSelector %q is invalid: %v", c.Scope.NamespaceSelector, err))
+	}
+	for _, f := range []struct{ field, value string }{
+		{"job.template", c.S2IRunJobTemplate},
+		{"job.s2irunImage", c.S2IRunImage},
+		{"job.manifestToolImage", c.ManifestToolImage},
+		{"job.gitCloneImage", c.GitCloneImage},
+	} {
+		if f.value == "" {
+			allErrs = append(allErrs, fmt.Errorf("%s is required", f.field))
+		}
+	}
+	for _, f := range []struct{ field, value string }{
+		{"job.scheduling.taintKey", c.Scheduling.TaintKey},
+		{"job.scheduling.nodeAffinityKey", c.Scheduling.NodeAffinityKey},
+	} {
+		if msgs := validation.IsQualifiedName(f.value); len(msgs) != 0 {
+			allErrs = append(allErrs, fmt.Errorf("%s %q is invalid: %v", f.field, f.value, msgs))
+		}
+	}
+	for _, value := range c.Scheduling.NodeAffinityValues {
+		if msgs := validation.IsValidLabelValue(value); len(msgs) != 0 {
+			allErrs = append(allErrs, fmt.Errorf("job.scheduling.nodeAffinityValues %q is invalid: %v", value, msgs))
+		}
+	}
+	for _, f := range []struct{ field, value string }{
+		{"rbac.serviceAccountName", c.RBAC.ServiceAccountName},
+		{"rbac.roleName", c.RBAC.RoleName},
+		{"rbac.roleBindingName", c.RBAC.RoleBindingName},
+	} {
+		if msgs := validation.IsDNS1123Subdomain(f.value); len(msgs) != 0 {
+			allErrs = append(allErrs, fmt.Errorf("%s %q is invalid: %v", f.field, f.value, msgs))
+		}
+	}
+	if c.LogArchive.MaxBytes <= 0 {
+		allErrs = append(allErrs, fmt.Errorf("logArchive.maxBytes should be greater than 0"))
+	}
+	if (c.Trigger.CertFile == "") != (c.Trigger.KeyFile == "") {
+		allErrs = append(allErrs, fmt.Errorf("trigger.certFile and trigger.keyFile should be set together"))
+	}
+	if c.Trigger.ClientCAFile != "" && c.Trigger.CertFile == "" {
+		allErrs = append(allErrs, fmt.Errorf("trigger.clientCAFile requires trigger.certFile"))
+	}
+	if c.Trigger.MaxRequestBytes <= 0 {
+		allErrs = append(allErrs, fmt.Errorf("trigger.maxRequestBytes should be greater than 0"))
+	}
+	if c.Trigger.ReadTimeout < 0 || c.Trigger.WriteTimeout < 0 || c.Trigger.IdleTimeout < 0 || c.Trigger.ShutdownTimeout < 0 {
+		allErrs = append(allErrs, fmt.Errorf("the timeouts of trigger should not be negative"))
+	}
+	limit := c.Trigger.RateLimit
+	if limit.BuilderQPS < 0 || limit.BuilderBurst < 0 || limit.IPQPS < 0 || limit.IPBurst < 0 {
+		allErrs = append(allErrs, fmt.Errorf("the qps and burst of trigger.rateLimit should not be negative"))
+	}
+	return utilerrors.NewAggregate(allErrs)
+}
+
+// Reloadable returns the fields which are reloaded when the config file is changed
+func (c *Config) Reloadable() ReloadableConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return ReloadableConfig{
+		S2IRunJobTemplate: c.S2IRunJobTemplate,
+		S2IRunImage:       c.S2IRunImage,
+		ManifestToolImage: c.ManifestToolImage,
+		GitCloneImage:     c.GitCloneImage,
+		Scheduling:        c.Scheduling,
+	}
+}
+
+// Reload applies the reloadable fields of next, the names of the other fields which are changed are returned,
+// they take effect after the operator is restarted.
+func (c *Config) Reload(next *Config) []string {
+	next.mu.RLock()
+	defer next.mu.RUnlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.S2IRunJobTemplate = next.S2IRunJobTemplate
+	c.S2IRunImage = next.S2IRunImage
+	c.ManifestToolImage = next.ManifestToolImage
+	c.GitCloneImage = next.GitCloneImage
+	c.Scheduling = next.Scheduling
+
+	restart := make([]string, 0)
+	for _, field := range []struct {
+		name      string
+		old, next interface{}
+	}{
+		{"metricsAddress", c.MetricsAddress, next.MetricsAddress},
+		{"healthProbeAddress", c.HealthProbeAddress, next.HealthProbeAddress},
+		{"webhookPort", c.WebhookPort, next.WebhookPort},
+		{"leaderElection", c.LeaderElection, next.LeaderElection},
+		{"scope", c.Scope, next.Scope},
+		{"rbac", c.RBAC, next.RBAC},
+		{"logURL", c.LogURL, next.LogURL},
+		{"logArchive", c.LogArchive, next.LogArchive},
+		{"trigger", c.Trigger, next.Trigger},
+	} {
+		if !reflect.DeepEqual(field.old, field.next) {
+			restart = append(restart, field.name)
+		}
+	}
+	return restart
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	// the sample file has the same values as the defaults
+	sample := NewDefaultConfig()
+	if err := LoadFile(filepath.Join("..", "..", "config", "manager", "s2ioperator-config.yaml"), sample); err != nil {
+		t.Fatal(err)
+	}
+	defaults := NewDefaultConfig()
+	defaults.S2IRunImage = sample.S2IRunImage
+	if !reflect.DeepEqual(sample.Reloadable(), defaults.Reloadable()) || !reflect.DeepEqual(sample.Trigger, defaults.Trigger) ||
+		!reflect.DeepEqual(sample.LogArchive, defaults.LogArchive) || !reflect.DeepEqual(sample.RBAC, defaults.RBAC) ||
+		!reflect.DeepEqual(sample.LeaderElection, defaults.LeaderElection) || sample.HealthProbeAddress != defaults.HealthProbeAddress {
+		t.Errorf("the sample config file should have the default values")
+	}
+	if err := sample.Validate(); err != nil {
+		t.Errorf("the sample config file should be valid, got %v", err)
+	}
+
+	dir, err := ioutil.TempDir("", "s2i-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.yaml")
+
+	writeFile(t, path, `apiVersion: config.devops.kubesphere.io/v1alpha1
+kind: S2iOperatorConfiguration
+job:
+  s2irunImage: s2irun:v1
+trigger:
+  writeTimeout: 10m
+  rateLimit:
+    ipQPS: 0
+`)
+	c := NewDefaultConfig()
+	if err = LoadFile(path, c); err != nil {
+		t.Fatal(err)
+	}
+	if c.S2IRunImage != "s2irun:v1" || c.Trigger.WriteTimeout != 10*time.Minute {
+		t.Errorf("the fields in the file should be loaded, got %+v", c)
+	}
+	if c.Trigger.RateLimit.IPQPS != 0 || c.Trigger.RateLimit.BuilderQPS != 0.5 || c.Trigger.ReadTimeout != 30*time.Second {
+		t.Errorf("the fields which are not set should be defaulted, got %+v", c.Trigger)
+	}
+
+	for name, content := range map[string]string{
+		"unknown version": "apiVersion: config.devops.kubesphere.io/v1beta1\nkind: S2iOperatorConfiguration\n",
+		"unknown field":   "apiVersion: config.devops.kubesphere.io/v1alpha1\nkind: S2iOperatorConfiguration\nport: 80\n",
+	} {
+		writeFile(t, path, content)
+		if err = LoadFile(path, NewDefaultConfig()); err == nil {
+			t.Errorf("the config file of %s should be rejected", name)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	c := NewDefaultConfig()
+	if err := c.Validate(); err == nil || !strings.Contains(err.Error(), "job.s2irunImage") {
+		t.Errorf("the s2irun image should be required, got %v", err)
+	}
+	c.S2IRunImage = "s2irun:v1"
+	c.WebhookPort = 0
+	c.RBAC.RoleName = "Invalid_Role"
+	c.Trigger.CertFile = "tls.crt"
+	c.LeaderElection.Enabled = true
+	c.LeaderElection.RenewDeadline = c.LeaderElection.LeaseDuration
+	c.Scope = ScopeConfig{Namespaces: []string{"tenant-a"}, NamespaceSelector: "tenant=a"}
+	err := c.Validate()
+	if err == nil {
+		t.Fatal("the invalid config should be rejected")
+	}
+	for _, field := range []string{"webhookPort", "leaderElection.leaseDuration", "scope.namespaceSelector", "rbac.roleName", "trigger.keyFile"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("%s should be invalid, got %v", field, err)
+		}
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "s2i-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.yaml")
+	writeFile(t, path, "apiVersion: config.devops.kubesphere.io/v1alpha1\nkind: S2iOperatorConfiguration\n"+
+		"job:\n  s2irunImage: s2irun:v1\n")
+
+	load := func(c *Config) error {
+		if err := LoadFile(path, c); err != nil {
+			return err
+		}
+		// the flag overrides the file
+		c.GitCloneImage = "git:flag"
+		return nil
+	}
+	c := NewDefaultConfig()
+	if err = load(c); err != nil {
+		t.Fatal(err)
+	}
+	w := NewWatcher(path, c, load)
+
+	writeFile(t, path, "apiVersion: config.devops.kubesphere.io/v1alpha1\nkind: S2iOperatorConfiguration\n"+
+		"job:\n  s2irunImage: s2irun:v2\n  scheduling:\n    taintKey: example.com/ci\ntrigger:\n  address: :9091\n")
+	w.reload()
+	reloadable := c.Reloadable()
+	if reloadable.S2IRunImage != "s2irun:v2" || reloadable.Scheduling.TaintKey != "example.com/ci" {
+		t.Errorf("the reloadable fields should be reloaded, got %+v", reloadable)
+	}
+	if reloadable.GitCloneImage != "git:flag" {
+		t.Errorf("the flags should override the reloaded file, got %s", reloadable.GitCloneImage)
+	}
+	if c.Trigger.Address != ":8081" {
+		t.Errorf("the address of trigger should not be reloaded, got %s", c.Trigger.Address)
+	}
+
+	// the invalid file is not reloaded
+	writeFile(t, path, "apiVersion: config.devops.kubesphere.io/v1alpha1\nkind: S2iOperatorConfiguration\n"+
+		"job:\n  s2irunImage: s2irun:v3\n  scheduling:\n    taintKey: \"invalid key\"\n")
+	w.reload()
+	if image := c.Reloadable().S2IRunImage; image != "s2irun:v2" {
+		t.Errorf("the invalid config file should not be reloaded, got %s", image)
+	}
+
+	next := NewDefaultConfig()
+	next.Trigger.Address = ":9091"
+	if restart := c.Reload(next); !reflect.DeepEqual(restart, []string{"trigger"}) {
+		t.Errorf("the change of trigger should require a restart, got %v", restart)
+	}
+}
@@ -1,5 +1,116 @@
 package config
 
+import (
+	"sync"
+	"time"
+)
+
+// Config is the config of the operator, which is loaded from the config file and overridden by the flags.
+// The fields in ReloadableConfig are reloaded when the file is changed, they should be read with Reloadable.
 type Config struct {
-	S2IRunJobTemplate string // template file path
+	mu sync.RWMutex
+
+	MetricsAddress     string               // the address the metric endpoint binds to
+	HealthProbeAddress string               // the address the healthz and readyz endpoints bind to
+	WebhookPort        int                  // the port the admission webhooks are served on
+	LeaderElection     LeaderElectionConfig // the leader election of the replicas of the operator
+	Scope              ScopeConfig          // the namespaces the operator serves
+	S2IRunJobTemplate  string               // template file path
+	S2IRunImage        string               // image which builds the image in the jobs
+	ManifestToolImage  string               // image used to push the manifest list of multi-platform builds
+	GitCloneImage      string               // image used to clone the source for pre-build hooks
+	Scheduling         SchedulingConfig     // the default toleration and node affinity of the jobs
+	RBAC               RBACConfig           // the ServiceAccount, Role and RoleBinding the jobs run with
+	LogURL             LogURLConfig         // where the log url of build pods points to
+	LogArchive         LogArchiveConfig     // where the log of build pods is archived
+	Trigger            TriggerConfig        // the http server of the triggers and the api
+}
+
+// ReloadableConfig is the fields of Config which take effect on the jobs created after the file is changed
+type ReloadableConfig struct {
+	S2IRunJobTemplate string
+	S2IRunImage       string
+	ManifestToolImage string
+	GitCloneImage     string
+	Scheduling        SchedulingConfig
+}
+
+// LeaderElectionConfig is the leader election of the replicas, the controllers run only in the leader while the
+// trigger server and the admission webhooks run in all the replicas
+type LeaderElectionConfig struct {
+	Enabled       bool
+	Namespace     string        // the namespace of the lock, defaults to the namespace of the operator in the cluster
+	ID            string        // the name of the lock
+	LeaseDuration time.Duration // how long the non-leaders wait before taking over the leadership
+	RenewDeadline time.Duration // how long the leader retries renewing the leadership before giving it up
+	RetryPeriod   time.Duration // how long the clients wait between the tries
+}
+
+// ScopeConfig restricts the operator to some namespaces, the objects in the other namespaces are ignored, so that
+// the operators of separate tenants could run in a cluster. All the namespaces are served if neither is set.
+type ScopeConfig struct {
+	Namespaces        []string // the names of the namespaces
+	NamespaceSelector string   // the label selector of the namespaces, e.g. tenant=a
+}
+
+// SchedulingConfig is the default toleration and node affinity of the jobs, which are overridden by s2ibuilders
+type SchedulingConfig struct {
+	TaintKey           string   // the jobs tolerate the taint
+	NodeAffinityKey    string   // the jobs prefer the nodes with the label
+	NodeAffinityValues []string // the values of the label
+}
+
+// RBACConfig is the names of the ServiceAccount, Role and RoleBinding created in the namespaces of s2iruns
+type RBACConfig struct {
+	ServiceAccountName string
+	RoleName           string
+	RoleBindingName    string
+}
+
+// LogURLConfig selects the backend which the log url of build pods points to
+type LogURLConfig struct {
+	Backend    string // one of none, kubesphere, loki, elasticsearch and template
+	URL        string // the url of KubeSphere api gateway, Grafana or Kibana
+	Template   string // LogQL for loki, KQL for elasticsearch or the url for template, in Go text/template
+	Datasource string // the Loki datasource in Grafana, or the index pattern id in Kibana
+}
+
+// LogArchiveConfig selects the sink where the log of build pods is archived after the job is finished
+type LogArchiveConfig struct {
+	Sink      string   // one of none, ConfigMap, Secret, PVC and S3
+	MaxBytes  int      // the size limit of the log kept in the ConfigMap, Secret or S3
+	Directory string   // the directory where the PVC is mounted in the operator
+	S3        S3Config // the object store of the S3 sink
+}
+
+// S3Config is the S3 compatible object store, the credential is read from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY
+type S3Config struct {
+	Endpoint string // e.g. https://s3.us-east-1.amazonaws.com or http://minio.minio-system.svc:9000
+	Bucket   string
+	Region   string
+	Prefix   string // the prefix of the object keys
+}
+
+// TriggerConfig is the http server of the triggers, the api and the log of s2iruns
+type TriggerConfig struct {
+	Address         string        // the listen address, e.g. :8081
+	CertFile        string        // serve in TLS if set, the certificate and key are reloaded after they are changed
+	KeyFile         string        // the private key of the certificate
+	ClientCAFile    string        // require the clients to present a certificate signed by the CA if set
+	MaxRequestBytes int64         // the size limit of the request body
+	ReadTimeout     time.Duration // the timeout of reading the request
+	WriteTimeout    time.Duration // the timeout of writing the response, 0 means no timeout which is required by following the log
+	IdleTimeout     time.Duration // the timeout of the idle keep-alive connections
+	ShutdownTimeout time.Duration // how long the in-flight requests are waited on shutdown
+	RateLimit       RateLimitConfig
+}
+
+// RateLimitConfig is the token bucket rate limit of the triggers, the limit of a s2ibuilder can be overridden by
+// its annotation devops.kubesphere.io/trigger-rate-limit, e.g. "qps=0.5,burst=5"
+type RateLimitConfig struct {
+	BuilderQPS        float64 // the rate of the triggers of a s2ibuilder, 0 means no limit
+	BuilderBurst      int
+	IPQPS             float64 // the rate of the triggers from a source ip, 0 means no limit
+	IPBurst           int
+	TrustForwardedFor bool // take the source ip from X-Forwarded-For, if the server is behind a proxy
 }
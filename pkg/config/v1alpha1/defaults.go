@@ -0,0 +1,128 @@
+/*
+Copyright 2019 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SetDefaults sets the fields which are not set in the file to the defaults
+func SetDefaults(obj *S2iOperatorConfiguration) {
+	if obj.MetricsAddress == "" {
+		obj.MetricsAddress = ":8080"
+	}
+	if obj.HealthProbeAddress == "" {
+		obj.HealthProbeAddress = ":8082"
+	}
+	if obj.WebhookPort == 0 {
+		// the default port of controller-runtime is 9443 since 0.7.0, 443 is kept for consistency
+		obj.WebhookPort = 443
+	}
+
+	if obj.LeaderElection.ResourceName == "" {
+		obj.LeaderElection.ResourceName = "s2ioperator-leader-election"
+	}
+	setDefaultDuration(&obj.LeaderElection.LeaseDuration, 15*time.Second)
+	setDefaultDuration(&obj.LeaderElection.RenewDeadline, 10*time.Second)
+	setDefaultDuration(&obj.LeaderElection.RetryPeriod, 2*time.Second)
+
+	if obj.Job.Template == "" {
+		obj.Job.Template = "/etc/template/job.yaml"
+	}
+	if obj.Job.ManifestToolImage == "" {
+		obj.Job.ManifestToolImage = "mplatform/manifest-tool:v1.0.3"
+	}
+	if obj.Job.GitCloneImage == "" {
+		obj.Job.GitCloneImage = "alpine/git:v2.30.2"
+	}
+	if obj.Job.Scheduling.TaintKey == "" {
+		obj.Job.Scheduling.TaintKey = "node.kubernetes.io/ci"
+	}
+	if obj.Job.Scheduling.NodeAffinityKey == "" {
+		obj.Job.Scheduling.NodeAffinityKey = "node-role.kubernetes.io/worker"
+	}
+	if len(obj.Job.Scheduling.NodeAffinityValues) == 0 {
+		obj.Job.Scheduling.NodeAffinityValues = []string{"ci"}
+	}
+
+	if obj.RBAC.ServiceAccountName == "" {
+		obj.RBAC.ServiceAccountName = "s2irun"
+	}
+	if obj.RBAC.RoleName == "" {
+		obj.RBAC.RoleName = "s2i-regular-role"
+	}
+	if obj.RBAC.RoleBindingName == "" {
+		obj.RBAC.RoleBindingName = "s2i-regular-rolebinding"
+	}
+
+	if obj.LogURL.Backend == "" {
+		obj.LogURL.Backend = "kubesphere"
+	}
+	if obj.LogArchive.Sink == "" {
+		obj.LogArchive.Sink = "none"
+	}
+	if obj.LogArchive.MaxBytes == 0 {
+		obj.LogArchive.MaxBytes = 512 * 1024
+	}
+	if obj.LogArchive.Directory == "" {
+		obj.LogArchive.Directory = "/var/log/s2i"
+	}
+	if obj.LogArchive.S3.Region == "" {
+		obj.LogArchive.S3.Region = "us-east-1"
+	}
+	if obj.LogArchive.S3.Prefix == "" {
+		obj.LogArchive.S3.Prefix = "s2i-logs"
+	}
+
+	trigger := &obj.Trigger
+	if trigger.Address == "" {
+		trigger.Address = ":8081"
+	}
+	if trigger.MaxRequestBytes == 0 {
+		trigger.MaxRequestBytes = 25 * 1024 * 1024
+	}
+	setDefaultDuration(&trigger.ReadTimeout, 30*time.Second)
+	// no timeout by default, which is required by following the log of long builds
+	setDefaultDuration(&trigger.WriteTimeout, 0)
+	setDefaultDuration(&trigger.IdleTimeout, 2*time.Minute)
+	setDefaultDuration(&trigger.ShutdownTimeout, 30*time.Second)
+	if trigger.RateLimit.BuilderQPS == nil {
+		qps := 0.5
+		trigger.RateLimit.BuilderQPS = &qps
+	}
+	if trigger.RateLimit.BuilderBurst == nil {
+		burst := 10
+		trigger.RateLimit.BuilderBurst = &burst
+	}
+	if trigger.RateLimit.IPQPS == nil {
+		qps := 5.0
+		trigger.RateLimit.IPQPS = &qps
+	}
+	if trigger.RateLimit.IPBurst == nil {
+		burst := 50
+		trigger.RateLimit.IPBurst = &burst
+	}
+}
+
+// setDefaultDuration sets the duration which is not set, the durations could be set to 0 explicitly
+func setDefaultDuration(d **metav1.Duration, value time.Duration) {
+	if *d == nil {
+		*d = &metav1.Duration{Duration: value}
+	}
+}
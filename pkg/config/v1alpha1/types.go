@@ -0,0 +1,157 @@
+/*
+Copyright 2019 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package v1alpha1 is the v1alpha1 version of the config file of the operator
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	GroupName = "config.devops.kubesphere.io"
+	Version   = "v1alpha1"
+	// Kind is the kind of the config file
+	Kind = "S2iOperatorConfiguration"
+)
+
+// APIVersion is the apiVersion of the config file
+var APIVersion = GroupName + "/" + Version
+
+// S2iOperatorConfiguration is the config file of the operator, e.g.
+//
+//	apiVersion: config.devops.kubesphere.io/v1alpha1
+//	kind: S2iOperatorConfiguration
+//	job:
+//	  s2irunImage: kubespheredev/s2irun:latest
+//	trigger:
+//	  address: :8081
+type S2iOperatorConfiguration struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// MetricsAddress is the address the metric endpoint binds to
+	MetricsAddress string `json:"metricsAddress,omitempty"`
+	// HealthProbeAddress is the address the healthz and readyz endpoints bind to
+	HealthProbeAddress string `json:"healthProbeAddress,omitempty"`
+	// WebhookPort is the port the admission webhooks are served on
+	WebhookPort int `json:"webhookPort,omitempty"`
+	// LeaderElection is required to run more than one replica of the operator
+	LeaderElection LeaderElectionConfiguration `json:"leaderElection,omitempty"`
+	// Scope restricts the operator to some namespaces, all the namespaces are served by default
+	Scope ScopeConfiguration `json:"scope,omitempty"`
+
+	// Job is how the jobs of s2iruns are generated, it is reloaded when the file is changed
+	Job JobConfiguration `json:"job,omitempty"`
+	// RBAC is the names of the ServiceAccount, Role and RoleBinding the jobs run with in each namespace
+	RBAC RBACConfiguration `json:"rbac,omitempty"`
+	// LogURL selects the backend which the log url of build pods points to
+	LogURL LogURLConfiguration `json:"logURL,omitempty"`
+	// LogArchive selects the sink where the log of build pods is archived
+	LogArchive LogArchiveConfiguration `json:"logArchive,omitempty"`
+	// Trigger is the http server of the triggers, the api and the log of s2iruns
+	Trigger TriggerConfiguration `json:"trigger,omitempty"`
+}
+
+// LeaderElectionConfiguration is the leader election of the replicas, the controllers run only in the leader while
+// the trigger server and the admission webhooks run in all the replicas
+type LeaderElectionConfiguration struct {
+	LeaderElect bool `json:"leaderElect,omitempty"`
+	// ResourceNamespace is the namespace of the lock, defaults to the namespace of the operator in the cluster
+	ResourceNamespace string           `json:"resourceNamespace,omitempty"`
+	ResourceName      string           `json:"resourceName,omitempty"`
+	LeaseDuration     *metav1.Duration `json:"leaseDuration,omitempty"`
+	RenewDeadline     *metav1.Duration `json:"renewDeadline,omitempty"`
+	RetryPeriod       *metav1.Duration `json:"retryPeriod,omitempty"`
+}
+
+// ScopeConfiguration is the namespaces the operator serves, either by their names or by a label selector.
+// The objects in the other namespaces are ignored.
+type ScopeConfiguration struct {
+	Namespaces []string `json:"namespaces,omitempty"`
+	// NamespaceSelector is a label selector, e.g. tenant=a, the operator exits to be restarted when
+	// the namespaces which match it are changed
+	NamespaceSelector string `json:"namespaceSelector,omitempty"`
+}
+
+type JobConfiguration struct {
+	// Template is the path of the template of the build jobs
+	Template string `json:"template,omitempty"`
+	// S2iRunImage is the image which builds the image, the env S2IIMAGENAME overrides it
+	S2iRunImage string `json:"s2irunImage,omitempty"`
+	// ManifestToolImage is used to push the manifest list of multi-platform builds
+	ManifestToolImage string `json:"manifestToolImage,omitempty"`
+	// GitCloneImage is used to clone the source for pre-build hooks
+	GitCloneImage string `json:"gitCloneImage,omitempty"`
+	// Scheduling is the default toleration and node affinity of the jobs, which are overridden by s2ibuilders
+	Scheduling SchedulingConfiguration `json:"scheduling,omitempty"`
+}
+
+type SchedulingConfiguration struct {
+	TaintKey           string   `json:"taintKey,omitempty"`
+	NodeAffinityKey    string   `json:"nodeAffinityKey,omitempty"`
+	NodeAffinityValues []string `json:"nodeAffinityValues,omitempty"`
+}
+
+type RBACConfiguration struct {
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+	RoleName           string `json:"roleName,omitempty"`
+	RoleBindingName    string `json:"roleBindingName,omitempty"`
+}
+
+type LogURLConfiguration struct {
+	// Backend is one of none, kubesphere, loki, elasticsearch and template
+	Backend    string `json:"backend,omitempty"`
+	URL        string `json:"url,omitempty"`
+	Template   string `json:"template,omitempty"`
+	Datasource string `json:"datasource,omitempty"`
+}
+
+type LogArchiveConfiguration struct {
+	// Sink is one of none, ConfigMap, Secret, PVC and S3
+	Sink      string          `json:"sink,omitempty"`
+	MaxBytes  int             `json:"maxBytes,omitempty"`
+	Directory string          `json:"directory,omitempty"`
+	S3        S3Configuration `json:"s3,omitempty"`
+}
+
+type S3Configuration struct {
+	Endpoint string `json:"endpoint,omitempty"`
+	Bucket   string `json:"bucket,omitempty"`
+	Region   string `json:"region,omitempty"`
+	Prefix   string `json:"prefix,omitempty"`
+}
+
+type TriggerConfiguration struct {
+	Address         string                 `json:"address,omitempty"`
+	CertFile        string                 `json:"certFile,omitempty"`
+	KeyFile         string                 `json:"keyFile,omitempty"`
+	ClientCAFile    string                 `json:"clientCAFile,omitempty"`
+	MaxRequestBytes int64                  `json:"maxRequestBytes,omitempty"`
+	ReadTimeout     *metav1.Duration       `json:"readTimeout,omitempty"`
+	WriteTimeout    *metav1.Duration       `json:"writeTimeout,omitempty"`
+	IdleTimeout     *metav1.Duration       `json:"idleTimeout,omitempty"`
+	ShutdownTimeout *metav1.Duration       `json:"shutdownTimeout,omitempty"`
+	RateLimit       RateLimitConfiguration `json:"rateLimit,omitempty"`
+}
+
+// RateLimitConfiguration is the rate limit of the triggers, 0 qps means no limit
+type RateLimitConfiguration struct {
+	BuilderQPS        *float64 `json:"builderQPS,omitempty"`
+	BuilderBurst      *int     `json:"builderBurst,omitempty"`
+	IPQPS             *float64 `json:"ipQPS,omitempty"`
+	IPBurst           *int     `json:"ipBurst,omitempty"`
+	TrustForwardedFor bool     `json:"trustForwardedFor,omitempty"`
+}
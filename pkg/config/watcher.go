@@ -0,0 +1,83 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"time"
+
+	log "k8s.io/klog"
+)
+
+// DefaultWatchInterval is how often the config file is checked
+const DefaultWatchInterval = 10 * time.Second
+
+// Watcher reloads the config when the config file is changed. The file is compared by its content, so that
+// the ConfigMap mounted in the operator, which is updated by swapping the symlink, is reloaded as well.
+type Watcher struct {
+	path     string
+	cfg      *Config
+	load     func(c *Config) error
+	interval time.Duration
+	content  []byte
+}
+
+// NewWatcher returns a watcher which reloads cfg from the file at path, load is called with a default config
+// to load the file into it, so that the flags could be applied over the file again.
+func NewWatcher(path string, cfg *Config, load func(c *Config) error) *Watcher {
+	content, _ := ioutil.ReadFile(path)
+	return &Watcher{
+		path:     path,
+		cfg:      cfg,
+		load:     load,
+		interval: DefaultWatchInterval,
+		content:  content,
+	}
+}
+
+// NeedLeaderElection returns false, so that the config is reloaded in all the replicas of the operator
+func (w *Watcher) NeedLeaderElection() bool {
+	return false
+}
+
+// Start checks the file every interval until the context is done
+func (w *Watcher) Start(ctx context.Context) error {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			w.reload()
+		}
+	}
+}
+
+// reload loads and validates the changed file, the config is kept if the file is invalid
+func (w *Watcher) reload() {
+	content, err := ioutil.ReadFile(w.path)
+	if err != nil {
+		log.Errorf("failed to read the config file %s: %v", w.path, err)
+		return
+	}
+	if bytes.Equal(content, w.content) {
+		return
+	}
+	w.content = content
+
+	next := NewDefaultConfig()
+	if err = w.load(next); err != nil {
+		log.Errorf("failed to reload the config file %s: %v", w.path, err)
+		return
+	}
+	if err = next.Validate(); err != nil {
+		log.Errorf("the config file %s is not reloaded: %v", w.path, err)
+		return
+	}
+	restart := w.cfg.Reload(next)
+	log.Infof("the config file %s is reloaded", w.path)
+	if len(restart) != 0 {
+		log.Warningf("the changes of %v in the config file %s take effect after the operator is restarted", restart, w.path)
+	}
+}
@@ -24,7 +24,11 @@ import (
 // AddToManagerFuncs is a list of functions to add all Controllers to the Manager
 var AddToManagerFuncs []func(mgr manager.Manager, cfg *config.Config) error
 
-// AddToManager adds all Controllers to the Manager
+// AddToManager adds all Controllers to the Manager, which run only in the leader if the leader election is enabled.
+// The locks of the leader election are a ConfigMap and a Lease.
+
+// +kubebuilder:rbac:groups=coordination.k8s.io,resources=leases,verbs=get;list;watch;create;update;patch;delete
+
 func AddToManager(m manager.Manager, cfg *config.Config) error {
 	for _, f := range AddToManagerFuncs {
 		if err := f(m, cfg); err != nil {
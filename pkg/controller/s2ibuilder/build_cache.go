@@ -0,0 +1,123 @@
+/*
+Copyright 2019 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s2ibuilder
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// ReconcileBuildCache makes sure the build cache claim of the builder matches spec.cache,
+// a non-empty result means the cache is being purged and the builder should be requeued.
+func (r *ReconcileS2iBuilder) ReconcileBuildCache(instance *devopsv1alpha1.S2iBuilder) (reconcile.Result, error) {
+	claimName := instance.GetBuildCacheClaimName()
+	found := &corev1.PersistentVolumeClaim{}
+	err := r.Get(context.TODO(), types.NamespacedName{Namespace: instance.Namespace, Name: claimName}, found)
+	if err != nil && !errors.IsNotFound(err) {
+		return reconcile.Result{}, err
+	}
+	exists := err == nil
+	if exists && !metav1.IsControlledBy(found, instance) {
+		return reconcile.Result{}, fmt.Errorf("build cache claim %s in ns %s is not owned by s2ibuilder %s", claimName, instance.Namespace, instance.Name)
+	}
+
+	if _, ok := instance.Annotations[devopsv1alpha1.PurgeBuildCacheAnnotations]; ok {
+		if exists {
+			if found.DeletionTimestamp.IsZero() {
+				log.Info("Purging build cache", "Namespace", found.Namespace, "Name", found.Name)
+				if err := r.Delete(context.TODO(), found); err != nil && !errors.IsNotFound(err) {
+					return reconcile.Result{}, err
+				}
+			}
+			// the claim is protected while a build job is still using it
+			return reconcile.Result{RequeueAfter: time.Second * 5}, nil
+		}
+		delete(instance.Annotations, devopsv1alpha1.PurgeBuildCacheAnnotations)
+		if err := r.Update(context.TODO(), instance); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
+	if instance.Spec.Cache == nil {
+		if exists && found.DeletionTimestamp.IsZero() {
+			log.Info("Deleting unused build cache", "Namespace", found.Namespace, "Name", found.Name)
+			if err := r.Delete(context.TODO(), found); err != nil && !errors.IsNotFound(err) {
+				return reconcile.Result{}, err
+			}
+		}
+		instance.Status.CacheClaimName = ""
+		return reconcile.Result{}, nil
+	}
+
+	if exists {
+		if !found.DeletionTimestamp.IsZero() {
+			log.Info("Waiting for old build cache to be deleted", "Namespace", found.Namespace, "Name", found.Name)
+			return reconcile.Result{RequeueAfter: time.Second * 5}, nil
+		}
+		instance.Status.CacheClaimName = claimName
+		return reconcile.Result{}, nil
+	}
+
+	pvc := NewBuildCacheClaim(instance)
+	if err := controllerutil.SetControllerReference(instance, pvc, r.scheme); err != nil {
+		return reconcile.Result{}, err
+	}
+	log.Info("Creating build cache", "Namespace", pvc.Namespace, "Name", pvc.Name)
+	if err := r.Create(context.TODO(), pvc); err != nil {
+		if errors.IsAlreadyExists(err) {
+			return reconcile.Result{RequeueAfter: time.Second * 5}, nil
+		}
+		return reconcile.Result{}, err
+	}
+	instance.Status.CacheClaimName = claimName
+	return reconcile.Result{}, nil
+}
+
+// NewBuildCacheClaim returns the PersistentVolumeClaim described by spec.cache of the builder.
+func NewBuildCacheClaim(instance *devopsv1alpha1.S2iBuilder) *corev1.PersistentVolumeClaim {
+	accessModes := instance.Spec.Cache.AccessModes
+	if len(accessModes) == 0 {
+		accessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}
+	}
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      instance.GetBuildCacheClaimName(),
+			Namespace: instance.Namespace,
+			Labels: map[string]string{
+				devopsv1alpha1.S2iBuilderLabel: instance.Name,
+			},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      accessModes,
+			StorageClassName: instance.Spec.Cache.StorageClassName,
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceStorage: instance.Spec.Cache.Size,
+				},
+			},
+		},
+	}
+}
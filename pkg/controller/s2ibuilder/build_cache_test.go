@@ -0,0 +1,86 @@
+/*
+Copyright 2019 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s2ibuilder
+
+import (
+	"context"
+	"testing"
+
+	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcileBuildCache(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := devopsv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	builder := &devopsv1alpha1.S2iBuilder{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "default", UID: "1"},
+		Spec: devopsv1alpha1.S2iBuilderSpec{Cache: &devopsv1alpha1.S2iBuildCache{
+			Size:  resource.MustParse("1Gi"),
+			Paths: []string{"/root/.m2"},
+		}},
+	}
+	r := &ReconcileS2iBuilder{scheme: scheme, Client: fake.NewFakeClientWithScheme(scheme, builder)}
+
+	for i := 0; i < 2; i++ {
+		if result, err := r.ReconcileBuildCache(builder); err != nil || !result.IsZero() {
+			t.Fatalf("the build cache should be reconciled, got %v, %v", result, err)
+		}
+	}
+	key := types.NamespacedName{Namespace: "default", Name: builder.GetBuildCacheClaimName()}
+	pvc := &corev1.PersistentVolumeClaim{}
+	if err := r.Get(context.TODO(), key, pvc); err != nil {
+		t.Fatal(err)
+	}
+	if !metav1.IsControlledBy(pvc, builder) || pvc.Spec.AccessModes[0] != corev1.ReadWriteOnce ||
+		pvc.Spec.Resources.Requests.Storage().String() != "1Gi" || pvc.Labels[devopsv1alpha1.S2iBuilderLabel] != "hello" {
+		t.Errorf("the build cache claim should be created for the builder, got %+v", pvc)
+	}
+	if builder.Status.CacheClaimName != key.Name {
+		t.Errorf("the claim should be in the status, got %q", builder.Status.CacheClaimName)
+	}
+
+	// the claim of others is not taken over
+	other := builder.DeepCopy()
+	other.UID = "2"
+	if _, err := r.ReconcileBuildCache(other); err == nil {
+		t.Errorf("an error should be returned if the claim is not owned by the builder")
+	}
+
+	builder.Spec.Cache = nil
+	if _, err := r.ReconcileBuildCache(builder); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Get(context.TODO(), key, &corev1.PersistentVolumeClaim{}); !errors.IsNotFound(err) {
+		t.Errorf("the unused build cache should be deleted, got %v", err)
+	}
+	if builder.Status.CacheClaimName != "" {
+		t.Errorf("the claim should be removed from the status, got %q", builder.Status.CacheClaimName)
+	}
+}
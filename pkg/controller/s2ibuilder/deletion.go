@@ -0,0 +1,164 @@
+/*
+Copyright 2019 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s2ibuilder
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// ArchiveDataKey is the key of the summary of the s2iruns in the archive ConfigMap, in JSON of []S2iRunSummary
+	ArchiveDataKey = "s2iruns.json"
+	// maxArchiveBytes keeps the archive below the size limit of ConfigMaps, the oldest s2iruns are dropped
+	maxArchiveBytes = 512 * 1024
+)
+
+// S2iRunSummary is what is kept of a s2irun in the archive ConfigMap after its builder is deleted
+type S2iRunSummary struct {
+	Name           string                  `json:"name"`
+	UID            types.UID               `json:"uid"`
+	TriggerSource  string                  `json:"triggerSource,omitempty"`
+	RerunOf        string                  `json:"rerunOf,omitempty"`
+	RunState       devopsv1alpha1.RunState `json:"runState,omitempty"`
+	CreationTime   metav1.Time             `json:"creationTime"`
+	StartTime      *metav1.Time            `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time            `json:"completionTime,omitempty"`
+	SourceURL      string                  `json:"sourceURL,omitempty"`
+	RevisionId     string                  `json:"revisionId,omitempty"`
+	ImageName      string                  `json:"imageName,omitempty"`
+	ImageID        string                  `json:"imageID,omitempty"`
+	FailureReason  string                  `json:"failureReason,omitempty"`
+	LogURL         string                  `json:"logURL,omitempty"`
+}
+
+func newS2iRunSummary(run *devopsv1alpha1.S2iRun) S2iRunSummary {
+	summary := S2iRunSummary{
+		Name:           run.Name,
+		UID:            run.UID,
+		TriggerSource:  run.TriggerSource(),
+		RerunOf:        run.Status.RerunOf,
+		RunState:       run.Status.RunState,
+		CreationTime:   run.CreationTimestamp,
+		StartTime:      run.Status.StartTime,
+		CompletionTime: run.Status.CompletionTime,
+		FailureReason:  run.Status.FailureReason,
+		LogURL:         run.Status.LogURL,
+	}
+	if source := run.Status.S2iBuildSource; source != nil {
+		summary.SourceURL = source.SourceUrl
+		summary.RevisionId = source.RevisionId
+	}
+	if result := run.Status.S2iBuildResult; result != nil {
+		summary.ImageName = result.ImageName
+		summary.ImageID = result.ImageID
+	}
+	return summary
+}
+
+// finalize handles the s2iruns of the deleted builder by its deletion policy
+func (r *ReconcileS2iBuilder) finalize(instance *devopsv1alpha1.S2iBuilder) error {
+	switch instance.Spec.DeletionPolicy {
+	case devopsv1alpha1.DeletionPolicyOrphan:
+		// the labels of workloads are kept, they point to the s2iruns which are kept
+		log.Info("Orphaning s2iruns", "Namespace", instance.Namespace, "Name", instance.Name)
+		return nil
+	case devopsv1alpha1.DeletionPolicyArchive:
+		if err := r.ArchiveS2iRuns(instance); err != nil {
+			return err
+		}
+	}
+	if err := r.DeleteWorkloadLabels(instance); err != nil {
+		return err
+	}
+	return r.DeleteS2iRuns(instance)
+}
+
+// ArchiveS2iRuns keeps the summary of the s2iruns of the builder in a ConfigMap which is not owned by the builder,
+// the s2iruns archived by the last try are merged, so that none is lost if the deletion of s2iruns is retried.
+func (r *ReconcileS2iBuilder) ArchiveS2iRuns(instance *devopsv1alpha1.S2iBuilder) error {
+	runList, err := r.listS2iRuns(instance)
+	if err != nil {
+		return err
+	}
+
+	configmap := &corev1.ConfigMap{}
+	key := types.NamespacedName{Namespace: instance.Namespace, Name: instance.GetArchiveConfigMapName()}
+	err = r.Get(context.TODO(), key, configmap)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	exists := err == nil
+
+	summaries := make([]S2iRunSummary, 0, len(runList.Items))
+	archived := make(map[types.UID]bool)
+	if data := configmap.Data[ArchiveDataKey]; exists && data != "" {
+		if err := json.Unmarshal([]byte(data), &summaries); err != nil {
+			log.Error(err, "Ignoring the invalid archive", "Namespace", key.Namespace, "Name", key.Name)
+			summaries = summaries[:0]
+		}
+	}
+	for _, summary := range summaries {
+		archived[summary.UID] = true
+	}
+	for i := range runList.Items {
+		if !archived[runList.Items[i].UID] {
+			summaries = append(summaries, newS2iRunSummary(&runList.Items[i]))
+		}
+	}
+	data, err := marshalSummaries(summaries)
+	if err != nil {
+		return err
+	}
+
+	if configmap.Labels == nil {
+		configmap.Labels = make(map[string]string)
+	}
+	configmap.Labels[devopsv1alpha1.S2iBuilderLabel] = instance.Name
+	configmap.Data = map[string]string{ArchiveDataKey: data}
+	if exists {
+		return r.Update(context.TODO(), configmap)
+	}
+	configmap.Namespace = key.Namespace
+	configmap.Name = key.Name
+	log.Info("Archiving s2iruns", "Namespace", key.Namespace, "ConfigMap", key.Name, "Count", len(summaries))
+	return r.Create(context.TODO(), configmap)
+}
+
+// marshalSummaries sorts the summaries from the newest, and drops the oldest ones which exceed maxArchiveBytes
+func marshalSummaries(summaries []S2iRunSummary) (string, error) {
+	sort.SliceStable(summaries, func(i, j int) bool {
+		return summaries[j].CreationTime.Before(&summaries[i].CreationTime)
+	})
+	for {
+		data, err := json.Marshal(summaries)
+		if err != nil {
+			return "", err
+		}
+		if len(data) <= maxArchiveBytes || len(summaries) == 0 {
+			return string(data), nil
+		}
+		summaries = summaries[:len(summaries)*9/10]
+	}
+}
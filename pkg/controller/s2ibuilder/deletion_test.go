@@ -0,0 +1,228 @@
+/*
+Copyright 2019 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s2ibuilder
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newDeletionTestReconciler(t *testing.T, policy devopsv1alpha1.S2iBuilderDeletionPolicy) (*ReconcileS2iBuilder, *devopsv1alpha1.S2iBuilder) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := devopsv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	builder := &devopsv1alpha1.S2iBuilder{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "default", Annotations: map[string]string{
+			devopsv1alpha1.AutoScaleAnnotations: `[{"kind":"Deployment","name":"hello"},{"kind":"StatefulSet","name":"world"}]`,
+		}},
+		Spec: devopsv1alpha1.S2iBuilderSpec{DeletionPolicy: policy},
+	}
+	objects := []runtime.Object{builder,
+		&devopsv1alpha1.S2iRun{
+			ObjectMeta: metav1.ObjectMeta{Name: "hello-1", Namespace: "default", UID: "1", CreationTimestamp: metav1.Unix(1, 0)},
+			Spec:       devopsv1alpha1.S2iRunSpec{BuilderName: "hello"},
+			Status: devopsv1alpha1.S2iRunStatus{RunState: devopsv1alpha1.Failed, FailureReason: "BuildFailed",
+				S2iBuildSource: &devopsv1alpha1.S2iBuildSource{SourceUrl: "https://github.com/kubesphere/hello", RevisionId: "master"}},
+		},
+		&devopsv1alpha1.S2iRun{
+			ObjectMeta: metav1.ObjectMeta{Name: "hello-2", Namespace: "default", UID: "2", CreationTimestamp: metav1.Unix(2, 0)},
+			Spec:       devopsv1alpha1.S2iRunSpec{BuilderName: "hello"},
+			Status: devopsv1alpha1.S2iRunStatus{RunState: devopsv1alpha1.Successful,
+				S2iBuildResult: &devopsv1alpha1.S2iBuildResult{ImageName: "hello:v2"}},
+		},
+		&devopsv1alpha1.S2iRun{
+			ObjectMeta: metav1.ObjectMeta{Name: "world-1", Namespace: "default", UID: "3"},
+			Spec:       devopsv1alpha1.S2iRunSpec{BuilderName: "world"},
+		},
+		// updated by the s2irun of the builder
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "default"},
+			Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{"app": "hello", devopsv1alpha1.S2iRunLabel: "hello-2"}}}},
+		},
+		// updated by the s2irun of another builder since
+		&appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "world", Namespace: "default"},
+			Spec: appsv1.StatefulSetSpec{Template: corev1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{"app": "world", devopsv1alpha1.S2iRunLabel: "world-1"}}}},
+		},
+	}
+	c := &indexedClient{Client: fake.NewFakeClientWithScheme(scheme, objects...)}
+	return &ReconcileS2iBuilder{Client: c, scheme: scheme}, builder
+}
+
+func runNames(t *testing.T, r *ReconcileS2iBuilder) []string {
+	list := &devopsv1alpha1.S2iRunList{}
+	if err := r.List(context.TODO(), list, client.InNamespace("default")); err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0)
+	for _, run := range list.Items {
+		names = append(names, run.Name)
+	}
+	return names
+}
+
+func templateLabel(t *testing.T, r *ReconcileS2iBuilder, workload client.Object, template *corev1.PodTemplateSpec) string {
+	if err := r.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: workload.GetName()}, workload); err != nil {
+		t.Fatal(err)
+	}
+	return template.Labels[devopsv1alpha1.S2iRunLabel]
+}
+
+func TestFinalize(t *testing.T) {
+	for _, policy := range []devopsv1alpha1.S2iBuilderDeletionPolicy{"", devopsv1alpha1.DeletionPolicyDelete,
+		devopsv1alpha1.DeletionPolicyOrphan, devopsv1alpha1.DeletionPolicyArchive} {
+		r, builder := newDeletionTestReconciler(t, policy)
+		if err := r.finalize(builder); err != nil {
+			t.Fatalf("failed to finalize the s2ibuilder of the policy %q: %v", policy, err)
+		}
+
+		names := runNames(t, r)
+		deploy, statefulset := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "hello"}},
+			&appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "world"}}
+		deployLabel := templateLabel(t, r, deploy, &deploy.Spec.Template)
+		if label := templateLabel(t, r, statefulset, &statefulset.Spec.Template); label != "world-1" {
+			t.Errorf("the label of the workload updated by another builder should be kept, got %q", label)
+		}
+
+		if policy == devopsv1alpha1.DeletionPolicyOrphan {
+			if len(names) != 3 || deployLabel != "hello-2" {
+				t.Errorf("the s2iruns and the labels should be kept by Orphan, got %v and %q", names, deployLabel)
+			}
+			continue
+		}
+		if len(names) != 1 || names[0] != "world-1" {
+			t.Errorf("the s2iruns of the builder should be deleted by the policy %q, got %v", policy, names)
+		}
+		if deployLabel != "" {
+			t.Errorf("the label of the workload should be deleted by the policy %q, got %q", policy, deployLabel)
+		}
+
+		configmap := &corev1.ConfigMap{}
+		err := r.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "hello-archive"}, configmap)
+		if policy != devopsv1alpha1.DeletionPolicyArchive {
+			if err == nil {
+				t.Errorf("the s2iruns should not be archived by the policy %q", policy)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		summaries := make([]S2iRunSummary, 0)
+		if err = json.Unmarshal([]byte(configmap.Data[ArchiveDataKey]), &summaries); err != nil {
+			t.Fatal(err)
+		}
+		if len(summaries) != 2 || summaries[0].Name != "hello-2" || summaries[0].ImageName != "hello:v2" ||
+			summaries[1].FailureReason != "BuildFailed" || summaries[1].RevisionId != "master" {
+			t.Errorf("the s2iruns should be archived from the newest, got %+v", summaries)
+		}
+		if configmap.Labels[devopsv1alpha1.S2iBuilderLabel] != "hello" || len(configmap.OwnerReferences) != 0 {
+			t.Errorf("the archive should be labeled by the builder and not owned by it, got %+v", configmap.ObjectMeta)
+		}
+	}
+}
+
+func TestArchiveS2iRunsMerges(t *testing.T) {
+	r, builder := newDeletionTestReconciler(t, devopsv1alpha1.DeletionPolicyArchive)
+	if err := r.ArchiveS2iRuns(builder); err != nil {
+		t.Fatal(err)
+	}
+	// the deletion is retried after a s2irun is deleted
+	run := &devopsv1alpha1.S2iRun{ObjectMeta: metav1.ObjectMeta{Name: "hello-1", Namespace: "default"}}
+	if err := r.Delete(context.TODO(), run); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.ArchiveS2iRuns(builder); err != nil {
+		t.Fatal(err)
+	}
+	configmap := &corev1.ConfigMap{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "hello-archive"}, configmap); err != nil {
+		t.Fatal(err)
+	}
+	summaries := make([]S2iRunSummary, 0)
+	if err := json.Unmarshal([]byte(configmap.Data[ArchiveDataKey]), &summaries); err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 2 {
+		t.Errorf("the s2iruns archived before should be kept, got %+v", summaries)
+	}
+}
+
+func TestMarshalSummaries(t *testing.T) {
+	summaries := make([]S2iRunSummary, 0)
+	for i := 0; i < 5000; i++ {
+		summaries = append(summaries, S2iRunSummary{Name: "hello", UID: types.UID("uid"), CreationTime: metav1.Unix(int64(i), 0),
+			SourceURL: "https://github.com/kubesphere/s2ioperator", ImageName: "kubesphere/hello:latest"})
+	}
+	data, err := marshalSummaries(summaries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) > maxArchiveBytes {
+		t.Errorf("the archive should be limited to %d bytes, got %d", maxArchiveBytes, len(data))
+	}
+	kept := make([]S2iRunSummary, 0)
+	if err = json.Unmarshal([]byte(data), &kept); err != nil {
+		t.Fatal(err)
+	}
+	if len(kept) == 0 || kept[0].CreationTime.Unix() != 4999 {
+		t.Errorf("the newest s2iruns should be kept")
+	}
+}
+
+func TestMigrateDeployTargets(t *testing.T) {
+	r, builder := newDeletionTestReconciler(t, devopsv1alpha1.DeletionPolicyDelete)
+	if err := r.MigrateDeployTargets(builder); err != nil {
+		t.Fatal(err)
+	}
+	migrated := &devopsv1alpha1.S2iBuilder{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "hello"}, migrated); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := migrated.Annotations[devopsv1alpha1.AutoScaleAnnotations]; ok || len(migrated.Spec.DeployTargets) != 2 {
+		t.Errorf("the annotation should be migrated to spec.deployTargets, got %+v", migrated.ObjectMeta.Annotations)
+	}
+	if migrated.ResourceVersion != builder.ResourceVersion || len(builder.Spec.DeployTargets) != 2 {
+		t.Errorf("the migrated builder should be reconciled")
+	}
+
+	// the workloads of spec.deployTargets are handled by the deletion
+	if err := r.finalize(migrated); err != nil {
+		t.Fatal(err)
+	}
+	deploy := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "hello"}}
+	if label := templateLabel(t, r, deploy, &deploy.Spec.Template); label != "" {
+		t.Errorf("the label of the workload should be deleted, got %q", label)
+	}
+}
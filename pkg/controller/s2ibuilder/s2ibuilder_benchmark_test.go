@@ -0,0 +1,185 @@
+/*
+Copyright 2019 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s2ibuilder
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// indexedClient lists the s2iruns by builderNameField like the cache of the manager, the fake client ignores
+// the field selectors. listed is the count of the s2iruns listed.
+type indexedClient struct {
+	client.Client
+	listed int
+}
+
+func (c *indexedClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	if err := c.Client.List(ctx, list, opts...); err != nil {
+		return err
+	}
+	listOpts := client.ListOptions{}
+	listOpts.ApplyOptions(opts)
+	runs, ok := list.(*devopsv1alpha1.S2iRunList)
+	if !ok || listOpts.FieldSelector == nil {
+		return nil
+	}
+	items := make([]runtime.Object, 0)
+	for i := range runs.Items {
+		set := fields.Set{}
+		for _, value := range indexBuilderName(&runs.Items[i]) {
+			set[builderNameField] = value
+		}
+		if listOpts.FieldSelector.Matches(set) {
+			items = append(items, &runs.Items[i])
+		}
+	}
+	c.listed += len(items)
+	return apimeta.SetList(list, items)
+}
+
+// newBenchmarkReconciler returns a reconciler with builders s2ibuilders which have runs s2iruns for each
+func newBenchmarkReconciler(b testing.TB, builders, runs int) (*ReconcileS2iBuilder, *indexedClient) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		b.Fatal(err)
+	}
+	if err := devopsv1alpha1.AddToScheme(scheme); err != nil {
+		b.Fatal(err)
+	}
+	objects := make([]runtime.Object, 0, builders*(runs+1))
+	start := metav1.NewTime(time.Now())
+	for i := 0; i < builders; i++ {
+		name := fmt.Sprintf("builder-%d", i)
+		objects = append(objects, &devopsv1alpha1.S2iBuilder{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Finalizers: []string{s2iBuilderFinalizerName}},
+		})
+		for j := 0; j < runs; j++ {
+			objects = append(objects, &devopsv1alpha1.S2iRun{
+				ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("%s-run-%d", name, j), Namespace: "default"},
+				Spec:       devopsv1alpha1.S2iRunSpec{BuilderName: name},
+				Status:     devopsv1alpha1.S2iRunStatus{RunState: devopsv1alpha1.Successful, StartTime: &start},
+			})
+		}
+	}
+	c := &indexedClient{Client: fake.NewFakeClientWithScheme(scheme, objects...)}
+	return &ReconcileS2iBuilder{Client: c, scheme: scheme}, c
+}
+
+func TestReconcileListsRunsOfBuilder(t *testing.T) {
+	r, c := newBenchmarkReconciler(t, 10, 5)
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "builder-3"}}
+	if _, err := r.Reconcile(context.TODO(), request); err != nil {
+		t.Fatal(err)
+	}
+	if c.listed != 5 {
+		t.Errorf("only the s2iruns of the s2ibuilder should be listed, got %d", c.listed)
+	}
+	builder := &devopsv1alpha1.S2iBuilder{}
+	if err := r.Get(context.TODO(), request.NamespacedName, builder); err != nil {
+		t.Fatal(err)
+	}
+	if builder.Status.RunCount != 5 || builder.Status.LastRunState != devopsv1alpha1.Successful {
+		t.Errorf("the status of the s2ibuilder should be updated, got %+v", builder.Status)
+	}
+}
+
+func TestRunPredicate(t *testing.T) {
+	start := metav1.NewTime(time.Now())
+	old := &devopsv1alpha1.S2iRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "run", Namespace: "default", ResourceVersion: "1"},
+		Spec:       devopsv1alpha1.S2iRunSpec{BuilderName: "builder"},
+		Status:     devopsv1alpha1.S2iRunStatus{RunState: devopsv1alpha1.Running, StartTime: &start},
+	}
+
+	relabeled := old.DeepCopy()
+	relabeled.ResourceVersion = "2"
+	relabeled.Labels = map[string]string{"job-name": "run"}
+	if runPredicate.Update(event.UpdateEvent{ObjectOld: old, ObjectNew: relabeled}) {
+		t.Errorf("the update of the other fields should be ignored")
+	}
+
+	finished := relabeled.DeepCopy()
+	finished.Status.RunState = devopsv1alpha1.Successful
+	if !runPredicate.Update(event.UpdateEvent{ObjectOld: old, ObjectNew: finished}) {
+		t.Errorf("the update of the run state should be processed")
+	}
+
+	restarted := relabeled.DeepCopy()
+	later := metav1.NewTime(start.Add(time.Minute))
+	restarted.Status.StartTime = &later
+	if !runPredicate.Update(event.UpdateEvent{ObjectOld: old, ObjectNew: restarted}) {
+		t.Errorf("the update of the start time should be processed")
+	}
+
+	orphan := finished.DeepCopy()
+	orphan.Spec.BuilderName = ""
+	if runPredicate.Update(event.UpdateEvent{ObjectOld: old, ObjectNew: orphan}) || runPredicate.Create(event.CreateEvent{Object: orphan}) {
+		t.Errorf("the s2iruns without s2ibuilder should be ignored")
+	}
+}
+
+func BenchmarkReconcile(b *testing.B) {
+	for _, size := range []struct{ builders, runs int }{{10, 10}, {10, 100}, {100, 10}} {
+		b.Run(fmt.Sprintf("%dbuilders-%druns", size.builders, size.runs), func(b *testing.B) {
+			r, _ := newBenchmarkReconciler(b, size.builders, size.runs)
+			requests := make([]reconcile.Request, size.builders)
+			for i := range requests {
+				requests[i] = reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: fmt.Sprintf("builder-%d", i)}}
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := r.Reconcile(context.TODO(), requests[i%len(requests)]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkRunPredicate(b *testing.B) {
+	start := metav1.NewTime(time.Now())
+	old := &devopsv1alpha1.S2iRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "run", Namespace: "default"},
+		Spec:       devopsv1alpha1.S2iRunSpec{BuilderName: "builder"},
+		Status:     devopsv1alpha1.S2iRunStatus{RunState: devopsv1alpha1.Running, StartTime: &start},
+	}
+	updated := old.DeepCopy()
+	updated.Annotations = map[string]string{corev1.LastAppliedConfigAnnotation: "{}"}
+	e := event.UpdateEvent{ObjectOld: old, ObjectNew: updated}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if runPredicate.Update(e) {
+			b.Fatal("the update should be ignored")
+		}
+	}
+}
@@ -22,12 +22,15 @@ import (
 
 	"github.com/kubesphere/s2ioperator/pkg/config"
 	"github.com/kubesphere/s2ioperator/pkg/util/sliceutil"
-	v1 "k8s.io/api/apps/v1"
+	"github.com/kubesphere/s2ioperator/pkg/workload"
+	corev1 "k8s.io/api/core/v1"
 
 	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	errorutil "k8s.io/apimachinery/pkg/util/errors"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -44,6 +47,18 @@ var log = ctrl.Log.WithName("s2ibuilder-controller")
 
 const s2iBuilderFinalizerName = "s2ibuilders.finalizers.kubesphere.io"
 
+// builderNameField is the index of s2iruns by spec.builderName in the cache of the manager
+const builderNameField = "spec.builderName"
+
+// indexBuilderName returns the builder name of a s2irun for the index
+func indexBuilderName(o client.Object) []string {
+	run := o.(*devopsv1alpha1.S2iRun)
+	if run.Spec.BuilderName == "" {
+		return nil
+	}
+	return []string{run.Spec.BuilderName}
+}
+
 /**
 * USER ACTION REQUIRED: This is a scaffold file intended for the user to modify with their own Controller
 * business logic.  Delete these comments after modifying this file.*
@@ -74,37 +89,24 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		return err
 	}
 
-	//watch s2irun
-	var mapFn handler.MapFunc = func(o client.Object) []reconcile.Request {
-		run := o.(*devopsv1alpha1.S2iRun)
-		return []reconcile.Request{
-			{NamespacedName: client.ObjectKey{
-				Name:      run.Spec.BuilderName,
-				Namespace: o.GetNamespace(),
-			}},
-		}
+	// the s2iruns of a s2ibuilder are listed by the index
+	err = mgr.GetFieldIndexer().IndexField(context.TODO(), &devopsv1alpha1.S2iRun{}, builderNameField, indexBuilderName)
+	if err != nil {
+		return err
 	}
 
-	// 'UpdateFunc' and 'CreateFunc' used to judge if a event about the object is
-	// what we want. If that is true, the event will be processed by the reconciler.
-	p := predicate.Funcs{
-		UpdateFunc: func(e event.UpdateEvent) bool {
-			run := e.ObjectOld.(*devopsv1alpha1.S2iRun)
-			if run.Spec.BuilderName == "" {
-				return false
-			}
-			return e.ObjectOld != e.ObjectNew
-		},
-		CreateFunc: func(e event.CreateEvent) bool {
-			run := e.Object.(*devopsv1alpha1.S2iRun)
-			if run.Spec.BuilderName == "" {
-				return false
-			}
-			return true
-		},
+	//watch s2irun
+	err = c.Watch(&source.Kind{Type: &devopsv1alpha1.S2iRun{}}, handler.EnqueueRequestsFromMapFunc(builderOfRun), runPredicate)
+
+	if err != nil {
+		return err
 	}
-	err = c.Watch(&source.Kind{Type: &devopsv1alpha1.S2iRun{}}, handler.EnqueueRequestsFromMapFunc(mapFn), p)
 
+	// Watch for changes to the build cache of S2iBuilder
+	err = c.Watch(&source.Kind{Type: &corev1.PersistentVolumeClaim{}}, &handler.EnqueueRequestForOwner{
+		IsController: true,
+		OwnerType:    &devopsv1alpha1.S2iBuilder{},
+	})
 	if err != nil {
 		return err
 	}
@@ -112,6 +114,37 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 	return nil
 }
 
+// builderOfRun maps a s2irun to its s2ibuilder
+func builderOfRun(o client.Object) []reconcile.Request {
+	run := o.(*devopsv1alpha1.S2iRun)
+	return []reconcile.Request{
+		{NamespacedName: client.ObjectKey{
+			Name:      run.Spec.BuilderName,
+			Namespace: o.GetNamespace(),
+		}},
+	}
+}
+
+// runPredicate filters the events of s2iruns, the status of a s2ibuilder only depends on the count of its
+// s2iruns and the state and start time of them, so that the other updates of s2iruns are ignored.
+var runPredicate = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		old, run := e.ObjectOld.(*devopsv1alpha1.S2iRun), e.ObjectNew.(*devopsv1alpha1.S2iRun)
+		if run.Spec.BuilderName == "" {
+			return false
+		}
+		return old.Status.RunState != run.Status.RunState || !old.Status.StartTime.Equal(run.Status.StartTime)
+	},
+	CreateFunc: func(e event.CreateEvent) bool {
+		run := e.Object.(*devopsv1alpha1.S2iRun)
+		return run.Spec.BuilderName != ""
+	},
+	DeleteFunc: func(e event.DeleteEvent) bool {
+		run := e.Object.(*devopsv1alpha1.S2iRun)
+		return run.Spec.BuilderName != ""
+	},
+}
+
 var _ reconcile.Reconciler = &ReconcileS2iBuilder{}
 
 // ReconcileS2iBuilder reconciles a S2iBuilder object
@@ -122,9 +155,12 @@ type ReconcileS2iBuilder struct {
 
 // Reconcile reads that state of the cluster for a S2iBuilder object and makes changes based on the state read
 // and what is in the S2iBuilder.Spec
+
 // +kubebuilder:rbac:groups=devops.kubesphere.io,resources=s2ibuilders,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=devops.kubesphere.io,resources=s2ibuilders/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups=core,resources=persistentvolumeclaims,verbs=get;list;watch;create;delete
+
 func (r *ReconcileS2iBuilder) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
 	// Fetch the S2iBuilder instance
 	log.Info("Reconciler of s2ibuilder called", "NamespaceName", request.NamespacedName)
@@ -153,10 +189,7 @@ func (r *ReconcileS2iBuilder) Reconcile(ctx context.Context, request reconcile.R
 		}
 	} else {
 		if sliceutil.ContainsString(instance.ObjectMeta.Finalizers, s2iBuilderFinalizerName, nil) {
-			if err := r.DeleteS2iRuns(instance); err != nil {
-				return reconcile.Result{}, err
-			}
-			if err := r.DeleteWorkloadLabels(instance); err != nil {
+			if err := r.finalize(instance); err != nil {
 				return reconcile.Result{}, err
 			}
 			instance.ObjectMeta.Finalizers = sliceutil.RemoveString(instance.ObjectMeta.Finalizers, s2iBuilderFinalizerName, nil)
@@ -166,12 +199,17 @@ func (r *ReconcileS2iBuilder) Reconcile(ctx context.Context, request reconcile.R
 		}
 		return reconcile.Result{}, nil
 	}
-	runList := new(devopsv1alpha1.S2iRunList)
-	err = r.Client.List(context.TODO(), runList, client.InNamespace(instance.Namespace))
+
+	if err := r.MigrateDeployTargets(instance); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	if result, err := r.ReconcileBuildCache(instance); err != nil || !result.IsZero() {
+		return result, err
+	}
+
+	runList, err := r.listS2iRuns(instance)
 	if err != nil {
-		if errors.IsNotFound(err) {
-			return reconcile.Result{}, nil
-		}
 		// Error reading the object - requeue the request.
 		return reconcile.Result{}, err
 	}
@@ -207,21 +245,27 @@ func (r *ReconcileS2iBuilder) Reconcile(ctx context.Context, request reconcile.R
 	return reconcile.Result{}, nil
 }
 
-func (r *ReconcileS2iBuilder) DeleteS2iRuns(instance *devopsv1alpha1.S2iBuilder) error {
+// listS2iRuns lists the s2iruns of the builder by the index
+func (r *ReconcileS2iBuilder) listS2iRuns(instance *devopsv1alpha1.S2iBuilder) (*devopsv1alpha1.S2iRunList, error) {
 	runList := new(devopsv1alpha1.S2iRunList)
-	var errList []error
-	err := r.Client.List(context.TODO(), runList, client.InNamespace(instance.Namespace))
+	err := r.Client.List(context.TODO(), runList, client.InNamespace(instance.Namespace),
+		client.MatchingFields{builderNameField: instance.Name})
+	if err != nil {
+		return nil, err
+	}
+	return runList, nil
+}
+
+func (r *ReconcileS2iBuilder) DeleteS2iRuns(instance *devopsv1alpha1.S2iBuilder) error {
+	runList, err := r.listS2iRuns(instance)
 	if err != nil {
-		if errors.IsNotFound(err) {
-			return nil
-		}
-		// Error reading the object - requeue the request.
 		return err
 	}
+	var errList []error
 	for _, item := range runList.Items {
 		if item.Spec.BuilderName == instance.Name {
 			err := r.Delete(context.TODO(), &item)
-			if err != nil {
+			if err != nil && !errors.IsNotFound(err) {
 				errList = append(errList, err)
 			}
 		}
@@ -233,33 +277,67 @@ func (r *ReconcileS2iBuilder) DeleteS2iRuns(instance *devopsv1alpha1.S2iBuilder)
 
 }
 
-func (r *ReconcileS2iBuilder) DeleteWorkloadLabels(instance *devopsv1alpha1.S2iBuilder) error {
-	deployList := new(v1.DeploymentList)
-	err := r.Client.List(context.TODO(), deployList, client.InNamespace(instance.Namespace), client.MatchingLabels(map[string]string{
-		instance.Name: instance.Name,
-	}))
-	if err != nil && errors.IsNotFound(err) {
-		return err
+// MigrateDeployTargets moves the targets of the annotation devops.kubesphere.io/autoscale of the builder created
+// before spec.deployTargets to the spec. The annotation is kept in use if the migration is rejected, e.g. the
+// workloads are deleted, so that the builder is reconciled as before.
+func (r *ReconcileS2iBuilder) MigrateDeployTargets(instance *devopsv1alpha1.S2iBuilder) error {
+	migrated := instance.DeepCopy()
+	changed, err := migrated.MigrateDeployTargets()
+	if err != nil {
+		log.Error(err, "Skip migrating the invalid annotation", "Namespace", instance.Namespace, "Name", instance.Name)
+		return nil
 	}
-	var errList []error
-	for _, deploy := range deployList.Items {
-		delete(deploy.Labels, instance.Name)
-		if err := r.Update(context.TODO(), &deploy); err != nil {
-			errList = append(errList, err)
+	if !changed {
+		return nil
+	}
+	log.Info("Migrating the annotation to spec.deployTargets", "Namespace", instance.Namespace, "Name", instance.Name)
+	if err := r.Update(context.TODO(), migrated); err != nil {
+		if errors.IsConflict(err) {
+			return err
 		}
+		log.Error(err, "Failed to migrate the annotation to spec.deployTargets", "Namespace", instance.Namespace, "Name", instance.Name)
+		return nil
 	}
+	migrated.DeepCopyInto(instance)
+	return nil
+}
 
-	statefulSetList := new(v1.StatefulSetList)
-	err = r.Client.List(context.TODO(), statefulSetList, client.InNamespace(instance.Namespace), client.MatchingLabels(map[string]string{
-		instance.Name: instance.Name,
-	}))
-	if err != nil && errors.IsNotFound(err) {
-		return err
+// DeleteWorkloadLabels removes the label devops.kubesphere.io/s2ir from the pod templates of the workloads which
+// are updated by the s2iruns of the builder, the label is kept if the workload is updated by another builder since.
+func (r *ReconcileS2iBuilder) DeleteWorkloadLabels(instance *devopsv1alpha1.S2iBuilder) error {
+	targets, err := instance.GetDeployTargets()
+	if err != nil {
+		log.Error(err, "Skip deleting the labels of workloads of the invalid annotation", "Namespace", instance.Namespace, "Name", instance.Name)
+		return nil
 	}
 
-	for _, statefulset := range statefulSetList.Items {
-		delete(statefulset.Labels, instance.Name)
-		if err := r.Update(context.TODO(), &statefulset); err != nil {
+	var errList []error
+	for _, target := range targets {
+		updater, err := workload.For(target.APIVersion, target.Kind, target.ContainersPath)
+		if err != nil || updater.Recreate {
+			// the pod template of a Job is immutable, it is not created again only to remove the label
+			continue
+		}
+		w, err := updater.Get(context.TODO(), r.Client, types.NamespacedName{Namespace: instance.Namespace, Name: target.Name})
+		if err != nil {
+			if !errors.IsNotFound(err) && !meta.IsNoMatchError(err) {
+				errList = append(errList, err)
+			}
+			continue
+		}
+		runName, ok := w.TemplateLabels()[devopsv1alpha1.S2iRunLabel]
+		if !ok {
+			continue
+		}
+		if owned, err := r.ownsS2iRun(instance, runName); err != nil || !owned {
+			if err != nil {
+				errList = append(errList, err)
+			}
+			continue
+		}
+		w.RemoveTemplateLabel(devopsv1alpha1.S2iRunLabel)
+		log.Info("Deleting the label of workload", "Kind", target.Kind, "Namespace", instance.Namespace, "Name", target.Name)
+		if err := updater.Update(context.TODO(), r.Client, w); err != nil && !errors.IsNotFound(err) {
 			errList = append(errList, err)
 		}
 	}
@@ -268,3 +346,15 @@ func (r *ReconcileS2iBuilder) DeleteWorkloadLabels(instance *devopsv1alpha1.S2iB
 	}
 	return nil
 }
+
+// ownsS2iRun returns true if the s2irun belongs to the builder, or it has been deleted
+func (r *ReconcileS2iBuilder) ownsS2iRun(instance *devopsv1alpha1.S2iBuilder, name string) (bool, error) {
+	run := &devopsv1alpha1.S2iRun{}
+	err := r.Get(context.TODO(), types.NamespacedName{Namespace: instance.Namespace, Name: name}, run)
+	if errors.IsNotFound(err) {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+	return run.Spec.BuilderName == instance.Name, nil
+}
@@ -69,8 +69,8 @@ func SetupTestReconcile(inner reconcile.Reconciler) (reconcile.Reconciler, chan
 func StartTestManager(mgr manager.Manager, g *gomega.GomegaWithT) (chan struct{}, *sync.WaitGroup) {
 	stop := make(chan struct{})
 	wg := &sync.WaitGroup{}
+	wg.Add(1)
 	go func() {
-		wg.Add(1)
 		g.Expect(mgr.Start(context.Background())).NotTo(gomega.HaveOccurred())
 		wg.Done()
 	}()
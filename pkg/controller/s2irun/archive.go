@@ -0,0 +1,102 @@
+/*
+Copyright 2019 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s2irun
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// logArchiveTimeout bounds the time of reading and archiving the log of a pod, so that a stuck stream does not
+// block the reconciler, the log is archived again in the next reconcile if it is timed out.
+const logArchiveTimeout = 5 * time.Minute
+
+// isLogArchived returns true if the log of the job is archived
+func isLogArchived(instance *devopsv1alpha1.S2iRun, jobName string) bool {
+	for _, archive := range instance.Status.LogArchives {
+		if archive.KubernetesJobName == jobName {
+			return true
+		}
+	}
+	return false
+}
+
+// reconcileLogArchive archives the log of the containers of the latest pod of the finished job, the logs of
+// more than one container are archived in order, each after a line of its name. The job is created without TTL
+// if the log is archived, the TTL is set after the log is archived, so that the job and pod are not deleted
+// before that.
+func (r *ReconcileS2iRun) reconcileLogArchive(instance *devopsv1alpha1.S2iRun, job *batchv1.Job, pod *corev1.Pod, containers ...string) error {
+	if r.logArchiver == nil || isLogArchived(instance, job.Name) {
+		return nil
+	}
+	state := getJobRunState(job)
+	if state != devopsv1alpha1.Successful && state != devopsv1alpha1.Failed {
+		return nil
+	}
+	if pod != nil && r.kubeClient != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), logArchiveTimeout)
+		defer cancel()
+		readers := make([]io.Reader, 0, 2*len(containers))
+		for _, container := range containers {
+			stream, err := r.kubeClient.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+				Container: container,
+			}).Stream(ctx)
+			if err != nil {
+				log.Error(err, "Failed to read log of the job", "Namespace", job.Namespace, "Job", job.Name, "Container", container)
+				return err
+			}
+			defer stream.Close()
+			if len(containers) > 1 {
+				readers = append(readers, strings.NewReader(fmt.Sprintf("==> %s <==\n", container)))
+			}
+			readers = append(readers, stream)
+		}
+		archive, err := r.logArchiver.Archive(ctx, instance, pod, io.MultiReader(readers...))
+		if err != nil {
+			log.Error(err, "Failed to archive log of the job", "Namespace", job.Namespace, "Job", job.Name)
+			return err
+		}
+		archive.KubernetesJobName = job.Name
+		log.Info("Archived log of the job", "Namespace", job.Namespace, "Job", job.Name, "Sink", archive.Sink, "Location", archive.Location)
+		instance.Status.LogArchives = append(instance.Status.LogArchives, *archive)
+	}
+
+	// the job can be deleted after the log is archived
+	if instance.Spec.SecondsAfterFinished > 0 && job.Spec.TTLSecondsAfterFinished == nil {
+		job.Spec.TTLSecondsAfterFinished = &instance.Spec.SecondsAfterFinished
+		if err := r.Update(context.TODO(), job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getPodContainerNames returns the names of the init containers and containers of the pod in the order they run
+func getPodContainerNames(spec corev1.PodSpec) []string {
+	names := make([]string, 0, len(spec.InitContainers)+len(spec.Containers))
+	for _, container := range append(append([]corev1.Container{}, spec.InitContainers...), spec.Containers...) {
+		names = append(names, container.Name)
+	}
+	return names
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2019 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s2irun
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+// memoryArchiver keeps the archived logs in memory
+type memoryArchiver struct {
+	logs map[string]string
+}
+
+func (m *memoryArchiver) Archive(ctx context.Context, run *devopsv1alpha1.S2iRun, pod *corev1.Pod, log io.Reader) (*devopsv1alpha1.S2iLogArchive, error) {
+	data, err := ioutil.ReadAll(log)
+	if err != nil {
+		return nil, err
+	}
+	m.logs[pod.Name] = string(data)
+	return &devopsv1alpha1.S2iLogArchive{PodName: pod.Name, Sink: "Memory", Location: pod.Name}, nil
+}
+
+func (m *memoryArchiver) Open(ctx context.Context, run *devopsv1alpha1.S2iRun, archive devopsv1alpha1.S2iLogArchive) (io.ReadCloser, error) {
+	return ioutil.NopCloser(strings.NewReader(m.logs[archive.Location])), nil
+}
+
+func TestArchiveHookAndManifestLog(t *testing.T) {
+	r := newJobReconciler(t)
+	archiver := &memoryArchiver{logs: make(map[string]string)}
+	r.logArchiver = archiver
+	cfg := devopsv1alpha1.S2iConfig{
+		ImageName:      "hello/world",
+		Tag:            "latest",
+		Platforms:      []string{"linux/amd64", "linux/arm64"},
+		PostBuildHooks: []devopsv1alpha1.S2iHook{{Name: "scan", Image: "aquasec/trivy"}, {Name: "test"}},
+	}
+	run := &devopsv1alpha1.S2iRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello-1", Namespace: "default", UID: "1"},
+		Spec:       devopsv1alpha1.S2iRunSpec{BuilderName: "hello", SecondsAfterFinished: 60},
+	}
+
+	// the jobs are not deleted before their logs are archived
+	manifestJob, err := r.NewManifestJob(run, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hookJob := r.NewPostBuildJob(run, cfg)
+	if manifestJob.Spec.TTLSecondsAfterFinished != nil || hookJob.Spec.TTLSecondsAfterFinished != nil {
+		t.Errorf("the TTL should not be set before the log is archived")
+	}
+
+	if err = r.Create(context.TODO(), hookJob); err != nil {
+		t.Fatal(err)
+	}
+	completeJob(t, r, hookJob.Name, nil)
+	if err = r.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: hookJob.Name}, hookJob); err != nil {
+		t.Fatal(err)
+	}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: hookJob.Name + "-1", Namespace: "default"}}
+	r.kubeClient = kubefake.NewSimpleClientset(pod)
+	if err = r.reconcileLogArchive(run, hookJob, pod, getPodContainerNames(hookJob.Spec.Template.Spec)...); err != nil {
+		t.Fatal(err)
+	}
+	archived := archiver.logs[pod.Name]
+	if !strings.Contains(archived, "==> "+PostBuildContainerPrefix+"scan <==") || !strings.Contains(archived, "==> "+PostBuildContainerPrefix+"test <==") {
+		t.Errorf("the logs of all hooks should be archived, got %s", archived)
+	}
+	if len(run.Status.LogArchives) != 1 || run.Status.LogArchives[0].KubernetesJobName != hookJob.Name {
+		t.Errorf("the archive of the hook job should be recorded, got %+v", run.Status.LogArchives)
+	}
+	job := &batchv1.Job{}
+	if err = r.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: hookJob.Name}, job); err != nil {
+		t.Fatal(err)
+	}
+	if job.Spec.TTLSecondsAfterFinished == nil || *job.Spec.TTLSecondsAfterFinished != 60 {
+		t.Errorf("the TTL should be set after the log is archived, got %v", job.Spec.TTLSecondsAfterFinished)
+	}
+
+	r.logArchiver = nil
+	if manifestJob, err = r.NewManifestJob(run, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if hookJob = r.NewPostBuildJob(run, cfg); manifestJob.Spec.TTLSecondsAfterFinished == nil || hookJob.Spec.TTLSecondsAfterFinished == nil {
+		t.Errorf("the TTL should be set if the log is not archived")
+	}
+}
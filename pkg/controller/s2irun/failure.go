@@ -0,0 +1,196 @@
+/*
+Copyright 2019 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s2irun
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// LogTailLines is the number of the lines of the log kept in the status of the failed run
+	LogTailLines = 20
+	// MaxLogTailBytes limits the size of the log kept in the status
+	MaxLogTailBytes = 4096
+)
+
+// stuckReasons are the waiting reasons of a container which will not start without user action
+var stuckReasons = map[string]bool{
+	"ErrImagePull":               true,
+	"ImagePullBackOff":           true,
+	"InvalidImageName":           true,
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+}
+
+// stageMarkers are the messages in the log of s2irun when a stage of the build begins, in the order of the stages
+var stageMarkers = []struct {
+	stage   devopsv1alpha1.BuildStage
+	markers []string
+}{
+	{devopsv1alpha1.CloneStage, []string{"cloning", "clone source", "git clone", "downloading"}},
+	{devopsv1alpha1.AssembleStage, []string{`"assemble"`, "assemble script", "installing application source"}},
+	{devopsv1alpha1.CommitStage, []string{"committing", "commit container"}},
+	{devopsv1alpha1.PushStage, []string{"pushing", "push image"}},
+}
+
+// buildFailure is the failure found in the pod of the build
+type buildFailure struct {
+	Reason    string
+	Stage     devopsv1alpha1.BuildStage
+	Message   string
+	Container string
+}
+
+// getBuildFailure returns the failure of the pod, nil is returned if no container of the pod failed or is stuck.
+func getBuildFailure(pod *corev1.Pod) *buildFailure {
+	statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		if waiting := status.State.Waiting; waiting != nil && stuckReasons[waiting.Reason] {
+			return &buildFailure{
+				Reason:    waiting.Reason,
+				Stage:     containerStage(status.Name),
+				Message:   fmt.Sprintf("container %s is waiting: %s", status.Name, waiting.Message),
+				Container: status.Name,
+			}
+		}
+		if terminated := status.State.Terminated; terminated != nil && terminated.ExitCode != 0 {
+			failure := &buildFailure{
+				Reason:    terminated.Reason,
+				Stage:     containerStage(status.Name),
+				Message:   fmt.Sprintf("container %s exited with code %d", status.Name, terminated.ExitCode),
+				Container: status.Name,
+			}
+			if terminated.Signal != 0 {
+				failure.Message += fmt.Sprintf(" by signal %d", terminated.Signal)
+			}
+			if failure.Reason == "" {
+				failure.Reason = "Error"
+			}
+			return failure
+		}
+	}
+	if pod.Status.Phase == corev1.PodFailed && pod.Status.Reason != "" {
+		// e.g. the pod is evicted or exceeds the active deadline
+		return &buildFailure{
+			Reason:  pod.Status.Reason,
+			Message: pod.Status.Message,
+		}
+	}
+	return nil
+}
+
+// containerStage returns the stage which runs in the container, the stage of the s2irun container is
+// found from its log.
+func containerStage(name string) devopsv1alpha1.BuildStage {
+	switch {
+	case name == GitCloneContainerName:
+		return devopsv1alpha1.CloneStage
+	case strings.HasPrefix(name, PreBuildContainerPrefix):
+		return devopsv1alpha1.PreBuildStage
+	}
+	return ""
+}
+
+// getLogStage returns the latest stage of the build found in the log of s2irun
+func getLogStage(log string) devopsv1alpha1.BuildStage {
+	latest := -1
+	for _, line := range strings.Split(strings.ToLower(log), "\n") {
+		for i := latest + 1; i < len(stageMarkers); i++ {
+			for _, marker := range stageMarkers[i].markers {
+				if strings.Contains(line, marker) {
+					latest = i
+				}
+			}
+		}
+	}
+	if latest == -1 {
+		return ""
+	}
+	return stageMarkers[latest].stage
+}
+
+// getLogTail returns the last lines of the log of the container, it is empty if the log can not be read.
+func (r *ReconcileS2iRun) getLogTail(pod *corev1.Pod, container string) string {
+	if r.kubeClient == nil {
+		return ""
+	}
+	tailLines := int64(LogTailLines)
+	limitBytes := int64(MaxLogTailBytes)
+	data, err := r.kubeClient.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+		Container:  container,
+		TailLines:  &tailLines,
+		LimitBytes: &limitBytes,
+	}).DoRaw(context.TODO())
+	if err != nil {
+		log.Info("Failed to read log of the failed container", "Namespace", pod.Namespace, "Pod", pod.Name, "Container", container, "Error", err.Error())
+		return ""
+	}
+	return strings.TrimRight(string(data), "\n")
+}
+
+// setFailureStatus sets the reason of the failure of the run from the latest pods of the jobs. The log of the
+// failed container is only read once after the run fails.
+func (r *ReconcileS2iRun) setFailureStatus(instance *devopsv1alpha1.S2iRun, statuses []buildVariantStatus) {
+	if instance.Status.RunState == devopsv1alpha1.Successful {
+		instance.Status.FailureReason = ""
+		instance.Status.FailureStage = ""
+		instance.Status.FailureMessage = ""
+		instance.Status.LogTail = ""
+		return
+	}
+	if instance.Status.RunState == devopsv1alpha1.Failed && instance.Status.LogTail != "" {
+		return
+	}
+	var failure *buildFailure
+	var pod *corev1.Pod
+	for _, status := range statuses {
+		if status.Pod == nil {
+			continue
+		}
+		if failure = getBuildFailure(status.Pod); failure != nil {
+			pod = status.Pod
+			break
+		}
+	}
+	if failure == nil {
+		// the pod of the failed run may be deleted, keep the failure found before
+		if instance.Status.RunState != devopsv1alpha1.Failed {
+			instance.Status.FailureReason = ""
+			instance.Status.FailureStage = ""
+			instance.Status.FailureMessage = ""
+		}
+		return
+	}
+	instance.Status.FailureReason = failure.Reason
+	instance.Status.FailureStage = failure.Stage
+	instance.Status.FailureMessage = failure.Message
+	if instance.Status.RunState != devopsv1alpha1.Failed || failure.Container == "" {
+		return
+	}
+	instance.Status.LogTail = r.getLogTail(pod, failure.Container)
+	if failure.Container == devopsv1alpha1.S2iRunContainerName {
+		instance.Status.FailureStage = getLogStage(instance.Status.LogTail)
+	}
+	if instance.Status.FailureStage != "" {
+		instance.Status.FailureMessage = fmt.Sprintf("%s failed: %s", instance.Status.FailureStage, failure.Message)
+	}
+}
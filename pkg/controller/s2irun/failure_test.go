@@ -0,0 +1,91 @@
+/*
+Copyright 2019 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s2irun
+
+import (
+	"testing"
+
+	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestGetBuildFailure(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     corev1.PodStatus
+		wantReason string
+		wantStage  devopsv1alpha1.BuildStage
+	}{
+		{
+			name: "running",
+			status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{
+				{Name: devopsv1alpha1.S2iRunContainerName, State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+			}},
+		},
+		{
+			name: "image pull",
+			status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{
+				{Name: devopsv1alpha1.S2iRunContainerName, State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff"}}},
+			}},
+			wantReason: "ImagePullBackOff",
+		},
+		{
+			name: "oom killed",
+			status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{
+				{Name: devopsv1alpha1.S2iRunContainerName, State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137}}},
+			}},
+			wantReason: "OOMKilled",
+		},
+		{
+			name: "clone failed",
+			status: corev1.PodStatus{InitContainerStatuses: []corev1.ContainerStatus{
+				{Name: GitCloneContainerName, State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 128}}},
+			}},
+			wantReason: "Error",
+			wantStage:  devopsv1alpha1.CloneStage,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failure := getBuildFailure(&corev1.Pod{Status: tt.status})
+			if tt.wantReason == "" {
+				if failure != nil {
+					t.Errorf("unexpected failure %+v", failure)
+				}
+				return
+			}
+			if failure == nil || failure.Reason != tt.wantReason || failure.Stage != tt.wantStage {
+				t.Errorf("getBuildFailure() = %+v, want reason %s stage %s", failure, tt.wantReason, tt.wantStage)
+			}
+		})
+	}
+}
+
+func TestGetLogStage(t *testing.T) {
+	log := `Cloning source from https://github.com/kubesphere/devops-java-sample.git
+---> Installing application source...
+Running "assemble" in kubesphere/java-8-centos7
+Committing the build container
+Pushing image docker.io/kubesphere/hello:latest
+unauthorized: authentication required`
+	if stage := getLogStage(log); stage != devopsv1alpha1.PushStage {
+		t.Errorf("getLogStage() = %s, want %s", stage, devopsv1alpha1.PushStage)
+	}
+	if stage := getLogStage("Running \"assemble\"\nexit status 1"); stage != devopsv1alpha1.AssembleStage {
+		t.Errorf("getLogStage() = %s, want %s", stage, devopsv1alpha1.AssembleStage)
+	}
+}
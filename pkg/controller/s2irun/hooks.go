@@ -0,0 +1,297 @@
+/*
+Copyright 2019 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s2irun
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	ConfigSourceURLKey       = "sourceUrl"
+	ConfigRevisionIdKey      = "revisionId"
+	GitCloneContainerName    = "git-clone"
+	PreBuildContainerPrefix  = "pre-build-"
+	PostBuildContainerPrefix = "post-build-"
+	WorkspaceVolumeName      = "workspace"
+	WorkspaceMountPath       = "/workspace"
+	WorkspaceSourcePath      = "/workspace/source"
+	// HookImageEnvName is the environment of post-build hooks which holds the built image
+	HookImageEnvName = "S2I_IMAGE"
+	// GitUsernameEnvName and GitPasswordEnvName are the environments of the git-clone container which hold
+	// the credential in the git secret of the builder
+	GitUsernameEnvName = "GIT_USERNAME"
+	GitPasswordEnvName = "GIT_PASSWORD"
+)
+
+// gitCloneScript clones the source with the credential in the environments if it is set, the credential is
+// given to git by a credential helper, so that it is not written in the url or the cloned repository.
+const gitCloneScript = `set -e
+if [ -n "$` + GitUsernameEnvName + `" ]; then
+  git config --global credential.helper '!f() { echo "username=$` + GitUsernameEnvName + `"; echo "password=$` + GitPasswordEnvName + `"; }; f'
+fi
+git clone "$SOURCE_URL" ` + WorkspaceSourcePath + `
+cd ` + WorkspaceSourcePath + `
+if [ -n "$REVISION_ID" ]; then git checkout "$REVISION_ID"; fi`
+
+// newHookContainer returns the container which runs the hook
+func newHookContainer(prefix string, hook devopsv1alpha1.S2iHook) corev1.Container {
+	container := corev1.Container{
+		Name:            prefix + hook.Name,
+		Image:           hook.Image,
+		ImagePullPolicy: corev1.PullIfNotPresent,
+		Command:         hook.Command,
+		Args:            hook.Args,
+	}
+	for _, env := range hook.Env {
+		container.Env = append(container.Env, corev1.EnvVar{Name: env.Name, Value: env.Value})
+	}
+	return container
+}
+
+// setJobPreBuildHooks clones the source to the workspace in an init container, then runs the pre-build hooks
+// as init containers in the workspace. The s2irun container builds the image from the same workspace.
+func setJobPreBuildHooks(job *batchv1.Job, config devopsv1alpha1.S2iConfig, configMapName, gitCloneImage string) {
+	if len(config.PreBuildHooks) == 0 {
+		return
+	}
+	container := getS2iRunContainer(job)
+	if container == nil {
+		return
+	}
+	workspaceMount := corev1.VolumeMount{
+		Name:      WorkspaceVolumeName,
+		MountPath: WorkspaceMountPath,
+	}
+	job.Spec.Template.Spec.Volumes = append(job.Spec.Template.Spec.Volumes, corev1.Volume{
+		Name: WorkspaceVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			EmptyDir: &corev1.EmptyDirVolumeSource{},
+		},
+	})
+	container.VolumeMounts = append(container.VolumeMounts, workspaceMount)
+
+	configMapEnv := func(name, key string) corev1.EnvVar {
+		return corev1.EnvVar{
+			Name: name,
+			ValueFrom: &corev1.EnvVarSource{
+				ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: configMapName},
+					Key:                  key,
+				},
+			},
+		}
+	}
+	cloneContainer := corev1.Container{
+		Name:            GitCloneContainerName,
+		Image:           gitCloneImage,
+		ImagePullPolicy: corev1.PullIfNotPresent,
+		Command:         []string{"sh", "-c", gitCloneScript},
+		Env: []corev1.EnvVar{
+			configMapEnv("SOURCE_URL", ConfigSourceURLKey),
+			configMapEnv("REVISION_ID", ConfigRevisionIdKey),
+		},
+		VolumeMounts: []corev1.VolumeMount{workspaceMount},
+	}
+	if config.GitSecretRef != nil {
+		secretEnv := func(name, key string) corev1.EnvVar {
+			return corev1.EnvVar{
+				Name: name,
+				ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: *config.GitSecretRef,
+						Key:                  key,
+					},
+				},
+			}
+		}
+		cloneContainer.Env = append(cloneContainer.Env,
+			secretEnv(GitUsernameEnvName, corev1.BasicAuthUsernameKey),
+			secretEnv(GitPasswordEnvName, corev1.BasicAuthPasswordKey))
+	}
+	initContainers := []corev1.Container{cloneContainer}
+	for _, hook := range config.PreBuildHooks {
+		hookContainer := newHookContainer(PreBuildContainerPrefix, hook)
+		hookContainer.WorkingDir = WorkspaceSourcePath
+		hookContainer.VolumeMounts = append(hookContainer.VolumeMounts, workspaceMount)
+		initContainers = append(initContainers, hookContainer)
+	}
+	job.Spec.Template.Spec.InitContainers = append(job.Spec.Template.Spec.InitContainers, initContainers...)
+}
+
+// NewPostBuildJob returns the job which runs the post-build hooks in order against the built image,
+// the last hook runs as the container of the job and the others run as init containers.
+func (r *ReconcileS2iRun) NewPostBuildJob(instance *devopsv1alpha1.S2iRun, config devopsv1alpha1.S2iConfig) *batchv1.Job {
+	imageName := GetNewImageName(instance, config)
+	jobName := getResourceName(instance, "", "post-build-job")
+	containers := make([]corev1.Container, 0, len(config.PostBuildHooks))
+	for _, hook := range config.PostBuildHooks {
+		if hook.Image == "" {
+			hook.Image = imageName
+		}
+		container := newHookContainer(PostBuildContainerPrefix, hook)
+		container.Env = append(container.Env, corev1.EnvVar{Name: HookImageEnvName, Value: imageName})
+		containers = append(containers, container)
+	}
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      jobName,
+			Namespace: instance.Namespace,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &instance.Spec.BackoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						"job-name": jobName,
+					},
+				},
+				Spec: corev1.PodSpec{
+					InitContainers:     containers[:len(containers)-1],
+					Containers:         containers[len(containers)-1:],
+					ServiceAccountName: r.cfg.RBAC.ServiceAccountName,
+					RestartPolicy:      corev1.RestartPolicyNever,
+				},
+			},
+		},
+	}
+	// the TTL is set after the log is archived if the log archive is enabled
+	if instance.Spec.SecondsAfterFinished > 0 && r.logArchiver == nil {
+		job.Spec.TTLSecondsAfterFinished = &instance.Spec.SecondsAfterFinished
+	}
+	return job
+}
+
+// reconcilePostBuildJob runs the post-build hooks after the image is built, the run is finished
+// after the hooks are finished.
+func (r *ReconcileS2iRun) reconcilePostBuildJob(instance *devopsv1alpha1.S2iRun, builder *devopsv1alpha1.S2iBuilder) (reconcile.Result, error) {
+	job := r.NewPostBuildJob(instance, *builder.Spec.Config)
+	setJobLabelAnnotations(instance, *builder.Spec.Config, builder.Spec.FromTemplate, job)
+	setJobLabelandToleration(job, *builder.Spec.Config, r.cfg.Reloadable().Scheduling)
+	found := &batchv1.Job{}
+	err := r.Get(context.TODO(), types.NamespacedName{Name: job.Name, Namespace: job.Namespace}, found)
+	if err != nil && k8serror.IsNotFound(err) {
+		log.Info("Creating post-build Job", "Namespace", job.Namespace, "Name", job.Name)
+		if err := controllerutil.SetControllerReference(instance, job, r.scheme); err != nil {
+			return reconcile.Result{}, err
+		}
+		if err = r.Create(context.TODO(), job); err != nil {
+			if k8serror.IsAlreadyExists(err) {
+				log.Info("Skip creating 'Already-Exists' job", "Job-Name", job.Name)
+				return reconcile.Result{RequeueAfter: time.Second * 5}, nil
+			}
+			log.Error(err, "Failed to create post-build Job", "Namespace", job.Namespace, "Name", job.Name)
+			return reconcile.Result{}, err
+		}
+		found = job
+	} else if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	instance.Status.RunState = getJobRunState(found)
+	instance.Status.CompletionTime = nil
+	switch instance.Status.RunState {
+	case devopsv1alpha1.Successful, devopsv1alpha1.Failed:
+		instance.Status.CompletionTime = found.Status.CompletionTime
+	default:
+		// the build is done, but the run is not finished until the hooks are finished
+		instance.Status.RunState = devopsv1alpha1.Running
+	}
+	pod, err := r.getLatestJobPod(found)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if pod != nil {
+		instance.Status.HookResults = append(instance.Status.HookResults, getHookResults(pod, found.Name)...)
+	}
+	if err = r.reconcileLogArchive(instance, found, pod, getPodContainerNames(found.Spec.Template.Spec)...); err != nil {
+		return reconcile.Result{}, err
+	}
+	return reconcile.Result{}, nil
+}
+
+// getHookResults returns the states of the hook containers in the pod
+func getHookResults(pod *corev1.Pod, jobName string) []devopsv1alpha1.S2iHookResult {
+	var results []devopsv1alpha1.S2iHookResult
+	statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		result := devopsv1alpha1.S2iHookResult{KubernetesJobName: jobName}
+		if strings.HasPrefix(status.Name, PreBuildContainerPrefix) {
+			result.Name = strings.TrimPrefix(status.Name, PreBuildContainerPrefix)
+			result.Stage = devopsv1alpha1.PreBuild
+		} else if strings.HasPrefix(status.Name, PostBuildContainerPrefix) {
+			result.Name = strings.TrimPrefix(status.Name, PostBuildContainerPrefix)
+			result.Stage = devopsv1alpha1.PostBuild
+		} else {
+			continue
+		}
+		switch {
+		case status.State.Terminated != nil:
+			result.ExitCode = status.State.Terminated.ExitCode
+			result.Reason = status.State.Terminated.Reason
+			result.Message = status.State.Terminated.Message
+			if result.ExitCode == 0 {
+				result.RunState = devopsv1alpha1.Successful
+			} else {
+				result.RunState = devopsv1alpha1.Failed
+			}
+		case status.State.Running != nil:
+			result.RunState = devopsv1alpha1.Running
+		default:
+			result.RunState = devopsv1alpha1.NotRunning
+			if status.State.Waiting != nil {
+				result.Reason = status.State.Waiting.Reason
+				result.Message = status.State.Waiting.Message
+			}
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// hasFailedHook returns true if any hook of the run failed
+func hasFailedHook(instance *devopsv1alpha1.S2iRun) bool {
+	for _, result := range instance.Status.HookResults {
+		if result.RunState == devopsv1alpha1.Failed {
+			return true
+		}
+	}
+	return false
+}
+
+// setConfigMapSource keeps the source in the configmap for the git-clone container, and lets s2irun
+// build the image from the cloned source. The source should not have the git credential, which is read
+// from the git secret by the git-clone container.
+func setConfigMapSource(config *devopsv1alpha1.S2iConfig, data map[string]string) {
+	if len(config.PreBuildHooks) == 0 {
+		return
+	}
+	data[ConfigSourceURLKey] = config.SourceURL
+	data[ConfigRevisionIdKey] = config.RevisionId
+	config.SourceURL = fmt.Sprintf("file://%s", WorkspaceSourcePath)
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2019 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s2irun
+
+import (
+	"strings"
+	"testing"
+
+	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestPreBuildHooksGitCredential(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "git", Namespace: "default"},
+		Type:       corev1.SecretTypeBasicAuth,
+		Data: map[string][]byte{
+			corev1.BasicAuthUsernameKey: []byte("user"),
+			corev1.BasicAuthPasswordKey: []byte("password"),
+		},
+	}
+	r := &ReconcileS2iRun{scheme: scheme, Client: fake.NewFakeClientWithScheme(scheme, secret)}
+	run := &devopsv1alpha1.S2iRun{ObjectMeta: metav1.ObjectMeta{Name: "hello-1", Namespace: "default"}}
+	config := devopsv1alpha1.S2iConfig{
+		ImageName:     "hello/world",
+		Tag:           "latest",
+		SourceURL:     "https://github.com/hello/private.git",
+		GitSecretRef:  &corev1.LocalObjectReference{Name: "git"},
+		PreBuildHooks: []devopsv1alpha1.S2iHook{{Name: "lint", Image: "golangci/golangci-lint"}},
+	}
+
+	configmap, err := r.NewConfigMap(run, &configSnapshot{Config: config}, buildVariant{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if configmap.Data[ConfigSourceURLKey] != config.SourceURL || strings.Contains(configmap.Data[ConfigDataKey], "password") ||
+		!strings.Contains(configmap.Data[ConfigDataKey], "file://"+WorkspaceSourcePath) {
+		t.Errorf("the credential should not be in the configmap, got %v", configmap.Data)
+	}
+
+	job := &batchv1.Job{}
+	job.Spec.Template.Spec.Containers = []corev1.Container{{Name: devopsv1alpha1.S2iRunContainerName}}
+	setJobPreBuildHooks(job, config, configmap.Name, "alpine/git")
+	initContainers := job.Spec.Template.Spec.InitContainers
+	if len(initContainers) != 2 || initContainers[0].Name != GitCloneContainerName {
+		t.Fatalf("the source should be cloned before the hooks, got %+v", initContainers)
+	}
+	keys := make(map[string]string)
+	for _, env := range initContainers[0].Env {
+		if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil && env.ValueFrom.SecretKeyRef.Name == "git" {
+			keys[env.Name] = env.ValueFrom.SecretKeyRef.Key
+		}
+	}
+	if keys[GitUsernameEnvName] != corev1.BasicAuthUsernameKey || keys[GitPasswordEnvName] != corev1.BasicAuthPasswordKey {
+		t.Errorf("the git-clone container should read the credential from the git secret, got %+v", initContainers[0].Env)
+	}
+
+	// the git secret is checked before the job is created
+	config.GitSecretRef.Name = "missing"
+	if _, err = r.NewConfigMap(run, &configSnapshot{Config: config}, buildVariant{}); err == nil {
+		t.Errorf("an error should be returned if the git secret does not exist")
+	}
+}
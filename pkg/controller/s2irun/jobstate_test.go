@@ -0,0 +1,75 @@
+/*
+Copyright 2019 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s2irun
+
+import (
+	"testing"
+
+	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestGetJobRunState(t *testing.T) {
+	tests := []struct {
+		name   string
+		status batchv1.JobStatus
+		want   devopsv1alpha1.RunState
+	}{
+		{
+			name:   "just created",
+			status: batchv1.JobStatus{},
+			want:   devopsv1alpha1.Unknown,
+		},
+		{
+			name:   "running",
+			status: batchv1.JobStatus{Active: 1},
+			want:   devopsv1alpha1.Running,
+		},
+		{
+			name:   "retrying",
+			status: batchv1.JobStatus{Active: 1, Failed: 1},
+			want:   devopsv1alpha1.Running,
+		},
+		{
+			name:   "waiting for the next attempt",
+			status: batchv1.JobStatus{Failed: 2},
+			want:   devopsv1alpha1.Running,
+		},
+		{
+			name: "succeeded after retries",
+			status: batchv1.JobStatus{Failed: 2, Succeeded: 1, Conditions: []batchv1.JobCondition{
+				{Type: batchv1.JobComplete, Status: corev1.ConditionTrue},
+			}},
+			want: devopsv1alpha1.Successful,
+		},
+		{
+			name: "backoff limit exceeded",
+			status: batchv1.JobStatus{Failed: 3, Conditions: []batchv1.JobCondition{
+				{Type: batchv1.JobFailed, Status: corev1.ConditionTrue},
+			}},
+			want: devopsv1alpha1.Failed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getJobRunState(&batchv1.Job{Status: tt.status}); got != tt.want {
+				t.Errorf("getJobRunState() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
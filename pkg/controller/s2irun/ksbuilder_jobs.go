@@ -3,10 +3,13 @@ package s2irun
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/url"
 	"os"
+	"path"
 	"strings"
 	"text/template"
 
@@ -14,6 +17,7 @@ import (
 	"k8s.io/apimachinery/pkg/util/yaml"
 
 	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
+	s2iconfig "github.com/kubesphere/s2ioperator/pkg/config"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -21,10 +25,8 @@ import (
 )
 
 const (
-	ConfigDataKey     = "data"
-	TaintKey          = "node.kubernetes.io/ci"
-	NodeAffinityKey   = "node-role.kubernetes.io/worker"
-	NodeAffinityValue = "ci"
+	ConfigDataKey        = "data"
+	BuildCacheVolumeName = "build-cache"
 )
 
 func (r *ReconcileS2iRun) NewRegularRole(roleName, namespace string) *v1.Role {
@@ -37,7 +39,7 @@ func (r *ReconcileS2iRun) NewRegularRole(roleName, namespace string) *v1.Role {
 			{
 				APIGroups: []string{""},
 				Resources: []string{"pods"},
-				Verbs:     []string{"get", "list", "watch", "update", "patch"},
+				Verbs:     []string{"get", "list", "watch"},
 			},
 		},
 	}
@@ -78,48 +80,48 @@ func (r *ReconcileS2iRun) NewRoleBinding(name, roleName, saName, namespace strin
 	return roleBinding
 }
 
-func (r *ReconcileS2iRun) NewConfigMap(instance *devopsv1alpha1.S2iRun, config devopsv1alpha1.S2iConfig, template *devopsv1alpha1.UserDefineTemplate) (*corev1.ConfigMap, error) {
-	if template != nil {
-		t := &devopsv1alpha1.S2iBuilderTemplate{}
-		err := r.Get(context.TODO(), types.NamespacedName{Name: template.Name}, t)
-		if err != nil {
-			return nil, err
-		}
-		if template.BuilderImage != "" {
-			config.BuilderImage = template.BuilderImage
-		} else {
-			config.BuilderImage = t.Spec.DefaultBaseImage
+func (r *ReconcileS2iRun) NewConfigMap(instance *devopsv1alpha1.S2iRun, snapshot *configSnapshot, variant buildVariant) (*corev1.ConfigMap, error) {
+	// the credentials are set in the config, do not modify the snapshot
+	config := *snapshot.Config.DeepCopy()
+	if variant.BuilderImage != "" {
+		config.BuilderImage = variant.BuilderImage
+		if variant.RuntimeImage != "" {
+			config.RuntimeImage = variant.RuntimeImage
+		} else if info := findContainerInfo(snapshot.ContainerInfo, variant.BuilderImage); info != nil {
+			config.RuntimeImage = info.RuntimeImage
+			config.RuntimeArtifacts = info.RuntimeArtifacts
 		}
-		if len(template.Parameters) > 0 {
-			for _, p := range template.Parameters {
-				e := p.ToEnvironment()
-				if e != nil {
-					config.Environment = append(config.Environment, *e)
-				}
-			}
+	}
+	if len(variant.Environment) != 0 {
+		for _, env := range variant.Environment {
+			setEnvironment(&config, env)
 		}
 	}
 
-	config.Tag = GetNewImageName(instance, config)
-	config.RevisionId = GetNewRevisionId(instance, config)
-	config.SourceURL = GetNewSourceURL(instance, config)
+	config.Tag = GetVariantImageName(instance, config, variant.Name)
+	setPinnedSource(instance, &config, variant)
 
 	err := r.setDockerSecret(instance, &config)
 	if err != nil {
 		return nil, err
 	}
-	err = r.setGitSecret(instance, &config)
+	dataMap := make(map[string]string)
+	if len(config.PreBuildHooks) == 0 {
+		err = r.setGitSecret(instance, &config)
+	} else if config.GitSecretRef != nil {
+		// the git-clone container clones the source with the git secret, it is only checked here
+		_, _, err = r.getGitCredential(instance, &config)
+	}
 	if err != nil {
 		return nil, err
 	}
+	setConfigMapSource(&config, dataMap)
 	data, err := json.Marshal(config)
 	if err != nil {
 		return nil, err
 	}
 
-	instanceUidSlice := strings.Split(string(instance.UID), "-")
-	configMapName := instance.Name + fmt.Sprintf("-%s", instanceUidSlice[len(instanceUidSlice)-1]) + "-configmap"
-	dataMap := make(map[string]string)
+	configMapName := getResourceName(instance, variant.Name, "configmap")
 	dataMap[ConfigDataKey] = string(data)
 	configMap := &corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
@@ -131,6 +133,27 @@ func (r *ReconcileS2iRun) NewConfigMap(instance *devopsv1alpha1.S2iRun, config d
 	return configMap, nil
 }
 
+// findContainerInfo returns the container info of the builder image in the template
+func findContainerInfo(containerInfo []devopsv1alpha1.ContainerInfo, builderImage string) *devopsv1alpha1.ContainerInfo {
+	for i := range containerInfo {
+		if containerInfo[i].BuilderImage == builderImage {
+			return &containerInfo[i]
+		}
+	}
+	return nil
+}
+
+// getResourceName returns the name of the configmap or job of the run, resources of a build variant
+// have the variant name as suffix, e.g. foo-3f7a9c2b1e4d-arm64v8-job
+func getResourceName(instance *devopsv1alpha1.S2iRun, variantName, kind string) string {
+	instanceUidSlice := strings.Split(string(instance.UID), "-")
+	name := instance.Name + fmt.Sprintf("-%s", instanceUidSlice[len(instanceUidSlice)-1])
+	if variantName != "" {
+		name += "-" + variantName
+	}
+	return name + "-" + kind
+}
+
 type JobTemplateData struct {
 	ObjectMetaName                     string
 	ObjectMetaNamespace                string
@@ -141,20 +164,19 @@ type JobTemplateData struct {
 	ConfigMapName                      string
 }
 
-func (r *ReconcileS2iRun) getJobTemplateData(instance *devopsv1alpha1.S2iRun) (*JobTemplateData, error) {
-	instanceUidSlice := strings.Split(string(instance.UID), "-")
-	configMapName := instance.Name + fmt.Sprintf("-%s", instanceUidSlice[len(instanceUidSlice)-1]) + "-configmap"
-	jobName := instance.Name + fmt.Sprintf("-%s", instanceUidSlice[len(instanceUidSlice)-1]) + "-job"
-	imageName := os.Getenv("S2IIMAGENAME")
+func (r *ReconcileS2iRun) getJobTemplateData(instance *devopsv1alpha1.S2iRun, variantName string) (*JobTemplateData, error) {
+	configMapName := getResourceName(instance, variantName, "configmap")
+	jobName := getResourceName(instance, variantName, "job")
+	imageName := r.cfg.Reloadable().S2IRunImage
 	if imageName == "" {
-		return nil, fmt.Errorf("Failed to get s2i-image name, please set the env 'S2IIMAGENAME' ")
+		return nil, fmt.Errorf("Failed to get s2i-image name, please set job.s2irunImage in the config file or the env 'S2IIMAGENAME' ")
 	}
 
 	data := &JobTemplateData{
 		ObjectMetaName:                     jobName,
 		ObjectMetaNamespace:                instance.ObjectMeta.Namespace,
 		SpecTemplateObjectMetaLabelJobName: jobName,
-		SpecTemplateSpecServiceAccountName: RegularServiceAccount,
+		SpecTemplateSpecServiceAccountName: r.cfg.RBAC.ServiceAccountName,
 		ContainerS2IRunImage:               imageName,
 		SpecBackoffLimit:                   instance.Spec.BackoffLimit,
 		ConfigMapName:                      configMapName,
@@ -162,8 +184,8 @@ func (r *ReconcileS2iRun) getJobTemplateData(instance *devopsv1alpha1.S2iRun) (*
 	return data, nil
 }
 
-func (r *ReconcileS2iRun) GenerateNewJob(instance *devopsv1alpha1.S2iRun, templatePath string) (*batchv1.Job, error) {
-	templateData, err := r.getJobTemplateData(instance)
+func (r *ReconcileS2iRun) GenerateNewJob(instance *devopsv1alpha1.S2iRun, templatePath string, variantName string) (*batchv1.Job, error) {
+	templateData, err := r.getJobTemplateData(instance, variantName)
 	if err != nil {
 		return nil, err
 	}
@@ -186,7 +208,8 @@ func (r *ReconcileS2iRun) GenerateNewJob(instance *devopsv1alpha1.S2iRun, templa
 	if err != nil {
 		return nil, err
 	}
-	if instance.Spec.SecondsAfterFinished > 0 {
+	// the TTL is set after the log is archived if the log archive is enabled
+	if instance.Spec.SecondsAfterFinished > 0 && r.logArchiver == nil {
 		job.Spec.TTLSecondsAfterFinished = &instance.Spec.SecondsAfterFinished
 	}
 	return &job, err
@@ -291,10 +314,13 @@ func setJobLabelAnnotations(instance *devopsv1alpha1.S2iRun, config devopsv1alph
 		job.Annotations[devopsv1alpha1.DescriptionAnnotations] = description
 	}
 }
-func setJobLabelandToleration(job *batchv1.Job, config devopsv1alpha1.S2iConfig) {
-	var taintKey = TaintKey
-	var nodeAffinityKey = NodeAffinityKey
-	var nodeAffinityValues = []string{NodeAffinityValue}
+
+// setJobLabelandToleration sets the toleration and node affinity of the job, the defaults in the operator config
+// are overridden by the s2ibuilder
+func setJobLabelandToleration(job *batchv1.Job, config devopsv1alpha1.S2iConfig, defaults s2iconfig.SchedulingConfig) {
+	var taintKey = defaults.TaintKey
+	var nodeAffinityKey = defaults.NodeAffinityKey
+	var nodeAffinityValues = defaults.NodeAffinityValues
 
 	if config.TaintKey != "" {
 		taintKey = config.TaintKey
@@ -354,6 +380,56 @@ func setJobLabelandToleration(job *batchv1.Job, config devopsv1alpha1.S2iConfig)
 	job.Spec.Template.Spec.Tolerations = tolerations
 
 }
+
+// setJobBuildCache mounts the build cache claim of the builder to the paths defined in spec.cache.
+func setJobBuildCache(job *batchv1.Job, builder *devopsv1alpha1.S2iBuilder) {
+	if builder.Spec.Cache == nil {
+		return
+	}
+	container := getS2iRunContainer(job)
+	if container == nil {
+		return
+	}
+	job.Spec.Template.Spec.Volumes = append(job.Spec.Template.Spec.Volumes, corev1.Volume{
+		Name: BuildCacheVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: builder.GetBuildCacheClaimName(),
+			},
+		},
+	})
+	for _, cachePath := range builder.Spec.Cache.Paths {
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      BuildCacheVolumeName,
+			MountPath: cachePath,
+			SubPath:   buildCacheSubPath(cachePath),
+		})
+	}
+}
+
+// buildCacheSubPath returns the directory in cache volume for path, e.g. /root/.m2 => root-.m2-a1cfd307. The
+// hash of the path is the suffix, so that the paths like /a/b and /a-b have their own directories.
+func buildCacheSubPath(cachePath string) string {
+	cleaned := path.Clean(cachePath)
+	sum := sha256.Sum256([]byte(cleaned))
+	return strings.ReplaceAll(strings.Trim(cleaned, "/"), "/", "-") + "-" + hex.EncodeToString(sum[:4])
+}
+
+// getS2iRunContainer returns the container which runs s2i in the job, the first container is
+// used if the job template does not have a container named s2irun.
+func getS2iRunContainer(job *batchv1.Job) *corev1.Container {
+	containers := job.Spec.Template.Spec.Containers
+	if len(containers) == 0 {
+		return nil
+	}
+	for i := range containers {
+		if containers[i].Name == devopsv1alpha1.S2iRunContainerName {
+			return &containers[i]
+		}
+	}
+	return &containers[0]
+}
+
 func setConfigMapLabelAnnotations(instance *devopsv1alpha1.S2iRun, config devopsv1alpha1.S2iConfig, template *devopsv1alpha1.UserDefineTemplate, cm *corev1.ConfigMap) {
 	description := ""
 	imageName := GetNewImageName(instance, config)
@@ -378,24 +454,33 @@ func setConfigMapLabelAnnotations(instance *devopsv1alpha1.S2iRun, config devops
 	}
 }
 
+// getGitCredential returns the username and password in the git secret of the config
+func (r *ReconcileS2iRun) getGitCredential(instance *devopsv1alpha1.S2iRun, config *devopsv1alpha1.S2iConfig) (username, password []byte, err error) {
+	secret := &corev1.Secret{}
+	err = r.Get(context.TODO(), types.NamespacedName{
+		Namespace: instance.Namespace, Name: config.GitSecretRef.Name}, secret)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	username, ok := secret.Data[corev1.BasicAuthUsernameKey]
+	if !ok {
+		return nil, nil, fmt.Errorf("could not get username in secret %s", secret.Name)
+	}
+	password, ok = secret.Data[corev1.BasicAuthPasswordKey]
+	if !ok {
+		return nil, nil, fmt.Errorf("could not get password in secret %s", secret.Name)
+	}
+	return username, password, nil
+}
+
 // setGitSecret set GitClone Secret
 func (r *ReconcileS2iRun) setGitSecret(instance *devopsv1alpha1.S2iRun, config *devopsv1alpha1.S2iConfig) error {
 	if config.GitSecretRef != nil {
-		secret := &corev1.Secret{}
-		err := r.Get(context.TODO(), types.NamespacedName{
-			Namespace: instance.Namespace, Name: config.GitSecretRef.Name}, secret)
+		username, password, err := r.getGitCredential(instance, config)
 		if err != nil {
 			return err
 		}
-
-		username, ok := secret.Data[corev1.BasicAuthUsernameKey]
-		if !ok {
-			return fmt.Errorf("could not get username in secret %s", secret.Name)
-		}
-		password, ok := secret.Data[corev1.BasicAuthPasswordKey]
-		if !ok {
-			return fmt.Errorf("could not get password in secret %s", secret.Name)
-		}
 		sourceUrl, err := url.Parse(config.SourceURL)
 		if err != nil {
 			return err
@@ -0,0 +1,57 @@
+/*
+Copyright 2019 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s2irun
+
+import (
+	"strings"
+	"testing"
+
+	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSetJobBuildCache(t *testing.T) {
+	builder := &devopsv1alpha1.S2iBuilder{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "default"},
+		Spec: devopsv1alpha1.S2iBuilderSpec{Cache: &devopsv1alpha1.S2iBuildCache{
+			Paths: []string{"/root/.m2", "/a/b", "/a-b", "/a/b/"},
+		}},
+	}
+	job := &batchv1.Job{}
+	job.Spec.Template.Spec.Containers = []corev1.Container{{Name: "sidecar"}, {Name: devopsv1alpha1.S2iRunContainerName}}
+	setJobBuildCache(job, builder)
+
+	volumes := job.Spec.Template.Spec.Volumes
+	if len(volumes) != 1 || volumes[0].PersistentVolumeClaim == nil || volumes[0].PersistentVolumeClaim.ClaimName != "hello-build-cache" {
+		t.Fatalf("the build cache claim should be mounted, got %+v", volumes)
+	}
+	mounts := job.Spec.Template.Spec.Containers[1].VolumeMounts
+	if len(mounts) != 4 || len(job.Spec.Template.Spec.Containers[0].VolumeMounts) != 0 {
+		t.Fatalf("the paths should be mounted in the s2irun container, got %+v", mounts)
+	}
+	if !strings.HasPrefix(mounts[0].SubPath, "root-.m2-") || mounts[0].MountPath != "/root/.m2" {
+		t.Errorf("the sub path should be readable, got %s", mounts[0].SubPath)
+	}
+	if mounts[1].SubPath == mounts[2].SubPath {
+		t.Errorf("the paths /a/b and /a-b should not share the sub path %s", mounts[1].SubPath)
+	}
+	if mounts[1].SubPath != mounts[3].SubPath {
+		t.Errorf("the same path should have the same sub path, got %s and %s", mounts[1].SubPath, mounts[3].SubPath)
+	}
+}
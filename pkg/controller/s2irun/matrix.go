@@ -0,0 +1,107 @@
+/*
+Copyright 2019 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s2irun
+
+import (
+	"strings"
+	"time"
+
+	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// MatrixNotSupportedReason is the failure reason of the matrix run whose builder has the config not supported by
+// the build matrix
+const MatrixNotSupportedReason = "MatrixNotSupported"
+
+// unsupportedMatrixConfig returns the config of the builder which is not supported by the build matrix. The
+// webhook rejects such runs, but the builder may be created or changed after the run is created.
+func unsupportedMatrixConfig(config devopsv1alpha1.S2iConfig) []string {
+	var unsupported []string
+	if len(config.Platforms) != 0 {
+		unsupported = append(unsupported, "platforms")
+	}
+	if len(config.PostBuildHooks) != 0 {
+		unsupported = append(unsupported, "post-build hooks")
+	}
+	return unsupported
+}
+
+// reconcileMatrixJobs builds an image for every cell of the build matrix of the run, the run succeeds
+// only if all the cells succeed.
+func (r *ReconcileS2iRun) reconcileMatrixJobs(instance *devopsv1alpha1.S2iRun, builder *devopsv1alpha1.S2iBuilder, snapshot *configSnapshot) (reconcile.Result, error) {
+	if unsupported := unsupportedMatrixConfig(*builder.Spec.Config); len(unsupported) != 0 {
+		// the run fails without building anything, rather than dropping the config of the builder
+		instance.Status.RunState = devopsv1alpha1.Failed
+		instance.Status.FailureReason = MatrixNotSupportedReason
+		instance.Status.FailureMessage = "matrix is not supported by the builder with " + strings.Join(unsupported, " and ")
+		if instance.Status.CompletionTime == nil {
+			now := metav1.Now()
+			instance.Status.CompletionTime = &now
+		}
+		return reconcile.Result{}, nil
+	}
+	cells := instance.Spec.Matrix.Cells()
+	variants := make([]buildVariant, 0, len(cells))
+	for _, cell := range cells {
+		variants = append(variants, buildVariant{
+			Name:         cell.Name,
+			BuilderImage: cell.BuilderImage,
+			RuntimeImage: cell.RuntimeImage,
+			Environment:  cell.Parameters,
+		})
+	}
+	statuses, err := r.reconcileBuildVariants(instance, builder, snapshot, variants)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if statuses == nil {
+		return reconcile.Result{RequeueAfter: time.Second * 5}, nil
+	}
+	setRunStatus(instance, statuses)
+	r.setFailureStatus(instance, statuses)
+	matrixResults := make([]devopsv1alpha1.S2iMatrixCellResult, 0, len(cells))
+	var imageTags []string
+	var imageSize int64
+	for i, status := range statuses {
+		cellResult := devopsv1alpha1.S2iMatrixCellResult{
+			S2iMatrixCell:     cells[i],
+			RunState:          status.RunState,
+			KubernetesJobName: status.JobName,
+			LogURL:            status.LogURL,
+			ImageName:         GetVariantImageName(instance, *builder.Spec.Config, cells[i].Name),
+		}
+		if isBuildResultReported(status.BuildResult) {
+			cellResult.ImageID = status.BuildResult.ImageID
+			cellResult.ImageSize = status.BuildResult.ImageSize
+			imageTags = append(imageTags, cellResult.ImageName)
+			imageSize += status.BuildResult.ImageSize
+		}
+		matrixResults = append(matrixResults, cellResult)
+	}
+	instance.Status.MatrixResults = matrixResults
+	// the run has no image of its own, its result lists the images of the cells and the total size of them
+	if len(imageTags) != 0 {
+		instance.Status.S2iBuildResult = &devopsv1alpha1.S2iBuildResult{
+			ImageName:     builder.Spec.Config.ImageName,
+			ImageSize:     imageSize,
+			ImageRepoTags: imageTags,
+		}
+	}
+	return reconcile.Result{}, nil
+}
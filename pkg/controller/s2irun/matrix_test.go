@@ -0,0 +1,151 @@
+/*
+Copyright 2019 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s2irun
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
+	"github.com/kubesphere/s2ioperator/pkg/config"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// newJobReconciler returns the reconciler which creates the jobs from the job template in testdata
+func newJobReconciler(t *testing.T) *ReconcileS2iRun {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := devopsv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.NewDefaultConfig()
+	cfg.S2IRunJobTemplate = filepath.Join("testdata", "job.yaml")
+	cfg.S2IRunImage = "kubespheredev/s2irun:latest"
+	return &ReconcileS2iRun{scheme: scheme, cfg: cfg, Client: fake.NewFakeClientWithScheme(scheme)}
+}
+
+// completeJob completes the job and creates its pod, which reports the result of the build if it is not nil
+func completeJob(t *testing.T, r *ReconcileS2iRun, name string, result *devopsv1alpha1.S2iBuildResult) {
+	job := &batchv1.Job{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: name}, job); err != nil {
+		t.Fatal(err)
+	}
+	now := metav1.Now()
+	job.Status = batchv1.JobStatus{
+		Succeeded:      1,
+		CompletionTime: &now,
+		Conditions:     []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}},
+	}
+	if err := r.Update(context.TODO(), job); err != nil {
+		t.Fatal(err)
+	}
+	terminated := &corev1.ContainerStateTerminated{FinishedAt: now}
+	if result != nil {
+		message, err := json.Marshal(&buildInfo{S2iBuildResult: result})
+		if err != nil {
+			t.Fatal(err)
+		}
+		terminated.Message = string(message)
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name + "-1", Namespace: "default", Labels: map[string]string{"job-name": name}},
+		Status: corev1.PodStatus{Phase: corev1.PodSucceeded, ContainerStatuses: []corev1.ContainerStatus{
+			{Name: devopsv1alpha1.S2iRunContainerName, State: corev1.ContainerState{Terminated: terminated}},
+		}},
+	}
+	if err := r.Create(context.TODO(), pod); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMatrixNotSupported(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	for _, config := range []devopsv1alpha1.S2iConfig{
+		{ImageName: "hello/world", Platforms: []string{"linux/amd64", "linux/arm64"}},
+		{ImageName: "hello/world", PostBuildHooks: []devopsv1alpha1.S2iHook{{Name: "test"}}},
+	} {
+		r := &ReconcileS2iRun{scheme: scheme, Client: fake.NewFakeClientWithScheme(scheme)}
+		run := &devopsv1alpha1.S2iRun{
+			ObjectMeta: metav1.ObjectMeta{Name: "hello-1", Namespace: "default"},
+			Spec: devopsv1alpha1.S2iRunSpec{BuilderName: "hello", Matrix: &devopsv1alpha1.S2iBuildMatrix{
+				Parameters: []devopsv1alpha1.S2iMatrixParameter{{Key: "VERSION", Values: []string{"1", "2"}}},
+			}},
+		}
+		builder := &devopsv1alpha1.S2iBuilder{Spec: devopsv1alpha1.S2iBuilderSpec{Config: &config}}
+		if _, err := r.reconcileMatrixJobs(run, builder, &configSnapshot{Config: config}); err != nil {
+			t.Fatal(err)
+		}
+		if run.Status.RunState != devopsv1alpha1.Failed || run.Status.FailureReason != MatrixNotSupportedReason ||
+			run.Status.CompletionTime == nil {
+			t.Errorf("the run should fail if the matrix is not supported by the builder, got %+v", run.Status)
+		}
+		jobs := &batchv1.JobList{}
+		if err := r.List(context.TODO(), jobs, client.InNamespace("default")); err != nil || len(jobs.Items) != 0 {
+			t.Errorf("no job should be created, got %v, %v", jobs.Items, err)
+		}
+	}
+}
+
+func TestMatrixBuildResult(t *testing.T) {
+	r := newJobReconciler(t)
+	cfg := devopsv1alpha1.S2iConfig{ImageName: "hello/world", Tag: "latest", SourceURL: "https://github.com/hello/world.git"}
+	builder := &devopsv1alpha1.S2iBuilder{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "default"},
+		Spec:       devopsv1alpha1.S2iBuilderSpec{Config: &cfg},
+	}
+	run := &devopsv1alpha1.S2iRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello-1", Namespace: "default", UID: "1"},
+		Spec: devopsv1alpha1.S2iRunSpec{BuilderName: "hello", Matrix: &devopsv1alpha1.S2iBuildMatrix{
+			Parameters: []devopsv1alpha1.S2iMatrixParameter{{Key: "VERSION", Values: []string{"1", "2", "3"}}},
+		}},
+	}
+	snapshot := &configSnapshot{Config: cfg}
+
+	if _, err := r.reconcileMatrixJobs(run, builder, snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if result := run.Status.S2iBuildResult; result == nil || isBuildResultReported(result) {
+		t.Errorf("no result should be reported before the cells are built, got %+v", result)
+	}
+
+	completeJob(t, r, getResourceName(run, "1", "job"), &devopsv1alpha1.S2iBuildResult{ImageID: "sha256:1", ImageSize: 100})
+	completeJob(t, r, getResourceName(run, "2", "job"), &devopsv1alpha1.S2iBuildResult{ImageID: "sha256:2", ImageSize: 200})
+	completeJob(t, r, getResourceName(run, "3", "job"), nil)
+	if _, err := r.reconcileMatrixJobs(run, builder, snapshot); err != nil {
+		t.Fatal(err)
+	}
+	result := run.Status.S2iBuildResult
+	if result.ImageName != "hello/world" || result.ImageSize != 300 ||
+		!reflect.DeepEqual(result.ImageRepoTags, []string{"hello/world:latest-1", "hello/world:latest-2"}) {
+		t.Errorf("the result of the run should list the images of the cells, got %+v", result)
+	}
+}
@@ -0,0 +1,367 @@
+/*
+Copyright 2019 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s2irun
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/docker/distribution/reference"
+	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	ManifestToolContainerName = "manifest-tool"
+	DockerConfigVolumeName    = "docker-config"
+	DockerConfigMountPath     = "/etc/docker-config"
+
+	// dockerHubServerAddress is the key of Docker Hub in the docker config
+	dockerHubServerAddress = "https://index.docker.io/v1/"
+
+	// manifestLogTimeout bounds the time of reading the log of the manifest job
+	manifestLogTimeout = 30 * time.Second
+)
+
+var manifestDigestRegexp = regexp.MustCompile(`Digest: (sha256:[0-9a-f]{64})`)
+
+// platformSuffix returns the suffix of the tag and resources of a platform build, which is the
+// same as ARCHVARIANT in the manifest-tool template, e.g. linux/arm64/v8 => arm64v8
+func platformSuffix(platform string) string {
+	parts := strings.Split(platform, "/")
+	if len(parts) < 2 {
+		return platform
+	}
+	return strings.Join(parts[1:], "")
+}
+
+// platformArch returns the architecture of the platform, e.g. linux/arm64/v8 => arm64
+func platformArch(platform string) string {
+	parts := strings.Split(platform, "/")
+	if len(parts) < 2 {
+		return platform
+	}
+	return parts[1]
+}
+
+// setJobPlatform schedules the job to the nodes of the platform
+func setJobPlatform(job *batchv1.Job, platform string) {
+	if platform == "" {
+		return
+	}
+	if job.Spec.Template.Spec.NodeSelector == nil {
+		job.Spec.Template.Spec.NodeSelector = make(map[string]string)
+	}
+	job.Spec.Template.Spec.NodeSelector[corev1.LabelArchStable] = platformArch(platform)
+}
+
+// NewManifestJob returns the job which assembles the images of all platforms into a manifest list
+// and pushes it under the requested tag.
+func (r *ReconcileS2iRun) NewManifestJob(instance *devopsv1alpha1.S2iRun, config devopsv1alpha1.S2iConfig) (*batchv1.Job, error) {
+	manifestToolImage := r.cfg.Reloadable().ManifestToolImage
+	if manifestToolImage == "" {
+		return nil, fmt.Errorf("failed to get manifest-tool image, please set the flag 'manifest-tool-image'")
+	}
+	imageName := GetNewImageName(instance, config)
+	args := []string{
+		"push", "from-args",
+		"--platforms", strings.Join(config.Platforms, ","),
+		"--template", imageName + "-ARCHVARIANT",
+		"--target", imageName,
+	}
+	jobName := getResourceName(instance, "", "manifest-job")
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      jobName,
+			Namespace: instance.Namespace,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &instance.Spec.BackoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						"job-name": jobName,
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:            ManifestToolContainerName,
+							Image:           manifestToolImage,
+							ImagePullPolicy: corev1.PullIfNotPresent,
+						},
+					},
+					ServiceAccountName: r.cfg.RBAC.ServiceAccountName,
+					RestartPolicy:      corev1.RestartPolicyNever,
+				},
+			},
+		},
+	}
+	// the TTL is set after the log is archived if the log archive is enabled
+	if instance.Spec.SecondsAfterFinished > 0 && r.logArchiver == nil {
+		job.Spec.TTLSecondsAfterFinished = &instance.Spec.SecondsAfterFinished
+	}
+
+	// manifest-tool reads the registry credential from the docker config, the credential written in the
+	// builder directly is saved in the secret of NewManifestSecret, it is never passed as flags.
+	if secretName := getManifestSecretName(instance, config); secretName != "" {
+		job.Spec.Template.Spec.Volumes = append(job.Spec.Template.Spec.Volumes, corev1.Volume{
+			Name: DockerConfigVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: secretName,
+					Items: []corev1.KeyToPath{
+						{Key: corev1.DockerConfigJsonKey, Path: "config.json"},
+					},
+				},
+			},
+		})
+		container := &job.Spec.Template.Spec.Containers[0]
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      DockerConfigVolumeName,
+			MountPath: DockerConfigMountPath,
+			ReadOnly:  true,
+		})
+		args = append([]string{"--docker-cfg", DockerConfigMountPath}, args...)
+	}
+	job.Spec.Template.Spec.Containers[0].Args = args
+	return job, nil
+}
+
+// getManifestSecretName returns the name of the docker config secret mounted by the manifest job, it is
+// empty if the builder has no push credential.
+func getManifestSecretName(instance *devopsv1alpha1.S2iRun, config devopsv1alpha1.S2iConfig) string {
+	auth := config.PushAuthentication
+	switch {
+	case auth == nil:
+		return ""
+	case auth.SecretRef != nil:
+		return auth.SecretRef.Name
+	case auth.Username != "":
+		return getResourceName(instance, "", "manifest-secret")
+	}
+	return ""
+}
+
+// NewManifestSecret returns the docker config secret of the push credential written in the builder directly,
+// nil is returned if the credential is in a secret of the user already.
+func NewManifestSecret(instance *devopsv1alpha1.S2iRun, config devopsv1alpha1.S2iConfig) (*corev1.Secret, error) {
+	auth := config.PushAuthentication
+	if auth == nil || auth.SecretRef != nil || auth.Username == "" {
+		return nil, nil
+	}
+	serverAddress := auth.ServerAddress
+	if serverAddress == "" {
+		named, err := reference.ParseNormalizedNamed(config.ImageName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the registry of image %s: %v", config.ImageName, err)
+		}
+		serverAddress = reference.Domain(named)
+		if serverAddress == "docker.io" {
+			serverAddress = dockerHubServerAddress
+		}
+	}
+	data, err := json.Marshal(devopsv1alpha1.DockerConfigJson{Auths: devopsv1alpha1.DockerConfigMap{
+		serverAddress: {Username: auth.Username, Password: auth.Password, Email: auth.Email},
+	}})
+	if err != nil {
+		return nil, err
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      getManifestSecretName(instance, config),
+			Namespace: instance.Namespace,
+		},
+		Type: corev1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{corev1.DockerConfigJsonKey: data},
+	}, nil
+}
+
+// reconcileManifestSecret makes sure the docker config secret of the manifest job is up to date
+func (r *ReconcileS2iRun) reconcileManifestSecret(instance *devopsv1alpha1.S2iRun, config devopsv1alpha1.S2iConfig) error {
+	secret, err := NewManifestSecret(instance, config)
+	if err != nil || secret == nil {
+		return err
+	}
+	found := &corev1.Secret{}
+	err = r.Get(context.TODO(), types.NamespacedName{Name: secret.Name, Namespace: secret.Namespace}, found)
+	if err != nil && k8serror.IsNotFound(err) {
+		log.Info("Creating manifest Secret", "Namespace", secret.Namespace, "Name", secret.Name)
+		if err := controllerutil.SetControllerReference(instance, secret, r.scheme); err != nil {
+			return err
+		}
+		if err = r.Create(context.TODO(), secret); err != nil && !k8serror.IsAlreadyExists(err) {
+			return err
+		}
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(found.Data, secret.Data) {
+		found.Data = secret.Data
+		return r.Update(context.TODO(), found)
+	}
+	return nil
+}
+
+// reconcilePlatformJobs builds the image on every platform of the builder and pushes the manifest list
+// after all of them succeed, the run fails if the build of any platform fails.
+func (r *ReconcileS2iRun) reconcilePlatformJobs(instance *devopsv1alpha1.S2iRun, builder *devopsv1alpha1.S2iBuilder, snapshot *configSnapshot) (reconcile.Result, error) {
+	config := *builder.Spec.Config
+	variants := make([]buildVariant, 0, len(config.Platforms))
+	for _, platform := range config.Platforms {
+		variants = append(variants, buildVariant{Name: platformSuffix(platform), Platform: platform})
+	}
+	statuses, err := r.reconcileBuildVariants(instance, builder, snapshot, variants)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if statuses == nil {
+		return reconcile.Result{RequeueAfter: time.Second * 5}, nil
+	}
+	setRunStatus(instance, statuses)
+	r.setFailureStatus(instance, statuses)
+	platformResults := make([]devopsv1alpha1.S2iPlatformBuildResult, 0, len(variants))
+	var imageSize int64
+	for i, status := range statuses {
+		platformResult := devopsv1alpha1.S2iPlatformBuildResult{
+			Platform:          variants[i].Platform,
+			RunState:          status.RunState,
+			KubernetesJobName: status.JobName,
+			LogURL:            status.LogURL,
+			ImageName:         GetVariantImageName(instance, config, variants[i].Name),
+		}
+		if isBuildResultReported(status.BuildResult) {
+			platformResult.ImageID = status.BuildResult.ImageID
+			platformResult.ImageSize = status.BuildResult.ImageSize
+			imageSize += status.BuildResult.ImageSize
+		}
+		platformResults = append(platformResults, platformResult)
+	}
+	result := instance.Status.S2iBuildResult
+	result.PlatformResults = platformResults
+	if imageSize > 0 {
+		result.ImageSize = imageSize
+	}
+	if instance.Status.RunState != devopsv1alpha1.Successful {
+		return reconcile.Result{}, nil
+	}
+
+	// all platforms are built, push the manifest list
+	if err := r.reconcileManifestSecret(instance, config); err != nil {
+		log.Error(err, "Failed to reconcile the manifest secret", "Namespace", instance.Namespace, "Name", instance.Name)
+		return reconcile.Result{}, err
+	}
+	job, err := r.NewManifestJob(instance, config)
+	if err != nil {
+		log.Error(err, "Failed to initialize a manifest job")
+		return reconcile.Result{}, err
+	}
+	setJobLabelAnnotations(instance, config, builder.Spec.FromTemplate, job)
+	setJobLabelandToleration(job, config, r.cfg.Reloadable().Scheduling)
+	found := &batchv1.Job{}
+	err = r.Get(context.TODO(), types.NamespacedName{Name: job.Name, Namespace: job.Namespace}, found)
+	if err != nil && k8serror.IsNotFound(err) {
+		log.Info("Creating manifest Job", "Namespace", job.Namespace, "Name", job.Name)
+		if err := controllerutil.SetControllerReference(instance, job, r.scheme); err != nil {
+			return reconcile.Result{}, err
+		}
+		if err = r.Create(context.TODO(), job); err != nil {
+			if k8serror.IsAlreadyExists(err) {
+				log.Info("Skip creating 'Already-Exists' job", "Job-Name", job.Name)
+				return reconcile.Result{RequeueAfter: time.Second * 5}, nil
+			}
+			log.Error(err, "Failed to create manifest Job", "Namespace", job.Namespace, "Name", job.Name)
+			return reconcile.Result{}, err
+		}
+		found = job
+	} else if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	instance.Status.KubernetesJobName = found.Name
+	instance.Status.RunState = getJobRunState(found)
+	if instance.Status.RunState == devopsv1alpha1.Unknown {
+		// the manifest job is just created
+		instance.Status.RunState = devopsv1alpha1.Running
+	}
+	instance.Status.CompletionTime = nil
+	if instance.Status.RunState == devopsv1alpha1.Successful || instance.Status.RunState == devopsv1alpha1.Failed {
+		instance.Status.CompletionTime = found.Status.CompletionTime
+	}
+	logURL, err := r.GetLogURL(instance, found)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if logURL != "" {
+		instance.Status.LogURL = logURL
+	}
+
+	pod, err := r.getLatestJobPod(found)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	// the manifest list is the image of the run, its digest is printed by manifest-tool
+	if instance.Status.RunState == devopsv1alpha1.Successful && result.ImageID == "" {
+		digest := r.getManifestDigest(pod)
+		imageName := GetNewImageName(instance, config)
+		result.ImageName = imageName
+		result.ImageID = digest
+		result.ImageRepoTags = []string{imageName}
+	}
+	if err = r.reconcileLogArchive(instance, found, pod, ManifestToolContainerName); err != nil {
+		return reconcile.Result{}, err
+	}
+	return reconcile.Result{}, nil
+}
+
+// getManifestDigest returns the digest of the manifest list pushed by the pod of the manifest job, it is empty
+// if the log of the pod could not be read.
+func (r *ReconcileS2iRun) getManifestDigest(pod *corev1.Pod) string {
+	if r.kubeClient == nil || pod == nil {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), manifestLogTimeout)
+	defer cancel()
+	data, err := r.kubeClient.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+		Container: ManifestToolContainerName,
+	}).DoRaw(ctx)
+	if err != nil {
+		log.Error(err, "Failed to read log of the manifest job", "Namespace", pod.Namespace, "Pod", pod.Name)
+		return ""
+	}
+	return parseManifestDigest(string(data))
+}
+
+// parseManifestDigest returns the digest in the output of manifest-tool, e.g. Digest: sha256:... 1234
+func parseManifestDigest(output string) string {
+	if match := manifestDigestRegexp.FindStringSubmatch(output); match != nil {
+		return match[1]
+	}
+	return ""
+}
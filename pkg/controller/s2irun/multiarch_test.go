@@ -0,0 +1,153 @@
+/*
+Copyright 2019 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s2irun
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
+	"github.com/kubesphere/s2ioperator/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestManifestJobCredential(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := devopsv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	r := &ReconcileS2iRun{scheme: scheme, cfg: config.NewDefaultConfig(), Client: fake.NewFakeClientWithScheme(scheme)}
+	run := &devopsv1alpha1.S2iRun{ObjectMeta: metav1.ObjectMeta{Name: "hello-1", Namespace: "default", UID: "1"}}
+	cfg := devopsv1alpha1.S2iConfig{
+		ImageName: "hello/world",
+		Tag:       "latest",
+		Platforms: []string{"linux/amd64", "linux/arm64/v8"},
+		PushAuthentication: &devopsv1alpha1.AuthConfig{
+			Username: "user",
+			Password: "password",
+		},
+	}
+
+	job, err := r.NewManifestJob(run, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	container := job.Spec.Template.Spec.Containers[0]
+	for _, arg := range container.Args {
+		if strings.Contains(arg, "password") || arg == "--username" {
+			t.Errorf("the credential should not be in the args, got %v", container.Args)
+		}
+	}
+	volumes := job.Spec.Template.Spec.Volumes
+	if len(volumes) != 1 || volumes[0].Secret == nil || volumes[0].Secret.SecretName != getManifestSecretName(run, cfg) ||
+		len(container.VolumeMounts) != 1 || container.VolumeMounts[0].MountPath != DockerConfigMountPath {
+		t.Errorf("the generated docker config should be mounted, got %+v", job.Spec.Template.Spec)
+	}
+
+	if err = r.reconcileManifestSecret(run, cfg); err != nil {
+		t.Fatal(err)
+	}
+	secret := &corev1.Secret{}
+	key := types.NamespacedName{Namespace: "default", Name: getManifestSecretName(run, cfg)}
+	if err = r.Get(context.TODO(), key, secret); err != nil {
+		t.Fatal(err)
+	}
+	dockerConfig := &devopsv1alpha1.DockerConfigJson{}
+	if err = json.Unmarshal(secret.Data[corev1.DockerConfigJsonKey], dockerConfig); err != nil {
+		t.Fatal(err)
+	}
+	entry, ok := dockerConfig.Auths[dockerHubServerAddress]
+	if secret.Type != corev1.SecretTypeDockerConfigJson || !ok || entry.Username != "user" || entry.Password != "password" ||
+		len(secret.OwnerReferences) != 1 {
+		t.Errorf("the credential should be saved in a docker config secret of the run, got %+v", secret)
+	}
+
+	// the secret of the user is mounted directly
+	cfg.PushAuthentication = &devopsv1alpha1.AuthConfig{SecretRef: &corev1.LocalObjectReference{Name: "registry"}}
+	if secret, err = NewManifestSecret(run, cfg); err != nil || secret != nil {
+		t.Errorf("no secret should be generated for the secret of the user, got %v, %v", secret, err)
+	}
+	if job, err = r.NewManifestJob(run, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if volumes = job.Spec.Template.Spec.Volumes; len(volumes) != 1 || volumes[0].Secret.SecretName != "registry" {
+		t.Errorf("the secret of the user should be mounted, got %+v", volumes)
+	}
+}
+
+func TestPlatformBuildResult(t *testing.T) {
+	r := newJobReconciler(t)
+	cfg := devopsv1alpha1.S2iConfig{
+		ImageName: "hello/world",
+		Tag:       "latest",
+		SourceURL: "https://github.com/hello/world.git",
+		Platforms: []string{"linux/amd64", "linux/arm64"},
+	}
+	builder := &devopsv1alpha1.S2iBuilder{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "default"},
+		Spec:       devopsv1alpha1.S2iBuilderSpec{Config: &cfg},
+	}
+	run := &devopsv1alpha1.S2iRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello-1", Namespace: "default", UID: "1"},
+		Spec:       devopsv1alpha1.S2iRunSpec{BuilderName: "hello"},
+	}
+	snapshot := &configSnapshot{Config: cfg}
+
+	if _, err := r.reconcilePlatformJobs(run, builder, snapshot); err != nil {
+		t.Fatal(err)
+	}
+	completeJob(t, r, getResourceName(run, "amd64", "job"), &devopsv1alpha1.S2iBuildResult{ImageID: "sha256:1", ImageSize: 100})
+	completeJob(t, r, getResourceName(run, "arm64", "job"), &devopsv1alpha1.S2iBuildResult{ImageID: "sha256:2", ImageSize: 200})
+	if _, err := r.reconcilePlatformJobs(run, builder, snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if result := run.Status.S2iBuildResult; run.Status.RunState != devopsv1alpha1.Running || result.ImageSize != 300 ||
+		len(result.ImageRepoTags) != 0 || len(result.PlatformResults) != 2 {
+		t.Errorf("the tag should not be reported before the manifest list is pushed, got %+v", result)
+	}
+
+	completeJob(t, r, getResourceName(run, "", "manifest-job"), nil)
+	if _, err := r.reconcilePlatformJobs(run, builder, snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if result := run.Status.S2iBuildResult; run.Status.RunState != devopsv1alpha1.Successful || result.ImageName != "hello/world:latest" ||
+		result.ImageSize != 300 || !reflect.DeepEqual(result.ImageRepoTags, []string{"hello/world:latest"}) {
+		t.Errorf("the result of the run should be the manifest list, got %+v", result)
+	}
+}
+
+func TestParseManifestDigest(t *testing.T) {
+	const digest = "sha256:3f7a9c2b1e4d3f7a9c2b1e4d3f7a9c2b1e4d3f7a9c2b1e4d3f7a9c2b1e4d3f7a"
+	output := "time=\"2021-03-01T08:00:00Z\" level=info msg=\"pushing manifest list\"\nDigest: " + digest + " 741\n"
+	if got := parseManifestDigest(output); got != digest {
+		t.Errorf("the digest of the manifest list should be parsed, got %s", got)
+	}
+	if got := parseManifestDigest("fake logs"); got != "" {
+		t.Errorf("no digest should be parsed, got %s", got)
+	}
+}
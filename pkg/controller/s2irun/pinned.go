@@ -0,0 +1,103 @@
+/*
+Copyright 2019 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s2irun
+
+import (
+	"strings"
+
+	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
+)
+
+// imageWithDigest returns the reference of the image pinned to the digest, e.g. foo:latest@sha256:...
+func imageWithDigest(image, digest string) string {
+	if image == "" || digest == "" || strings.Contains(image, "@") {
+		return image
+	}
+	return image + "@" + digest
+}
+
+// trimDigest returns the reference of the image without digest
+func trimDigest(image string) string {
+	return strings.SplitN(image, "@", 2)[0]
+}
+
+// setPinnedSource makes the config build the commit and use the image digests which are resolved by
+// the run, it only takes effect on the configs created after the source is resolved. The image digests are only pinned for the run building a single
+// image, since the digests differ between platforms and the images of the matrix cells.
+func setPinnedSource(instance *devopsv1alpha1.S2iRun, config *devopsv1alpha1.S2iConfig, variant buildVariant) {
+	source := instance.Status.S2iBuildSource
+	if !instance.Spec.PinResolvedSource || source == nil {
+		return
+	}
+	if source.CommitID != "" && !config.IsBinaryURL {
+		config.RevisionId = source.CommitID
+	}
+	if variant.Name != "" {
+		return
+	}
+	if trimDigest(source.BuilderImage) == config.BuilderImage {
+		config.BuilderImage = imageWithDigest(config.BuilderImage, source.BuilderImageDigest)
+	}
+	if config.RuntimeImage != "" && trimDigest(source.RuntimeImage) == config.RuntimeImage {
+		config.RuntimeImage = imageWithDigest(config.RuntimeImage, source.RuntimeImageDigest)
+	}
+}
+
+// keepResolvedSource keeps the commit and image digests reported by the previous attempt if the latest
+// attempt has not reported them yet.
+func keepResolvedSource(source, previous *devopsv1alpha1.S2iBuildSource) {
+	if source == nil || previous == nil {
+		return
+	}
+	if source.CommitID == "" {
+		source.CommitID = previous.CommitID
+		source.CommitterName = previous.CommitterName
+		source.CommitterEmail = previous.CommitterEmail
+	}
+	if source.BuilderImageDigest == "" {
+		source.BuilderImageDigest = previous.BuilderImageDigest
+	}
+	if source.RuntimeImageDigest == "" {
+		source.RuntimeImageDigest = previous.RuntimeImageDigest
+	}
+}
+
+// setDefaultSource fills the source which is not reported by the build with the config of the builder
+func setDefaultSource(instance *devopsv1alpha1.S2iRun, config devopsv1alpha1.S2iConfig) {
+	source := instance.Status.S2iBuildSource
+	if source.BuilderImage == "" {
+		source.BuilderImage = config.BuilderImage
+	}
+	if source.RuntimeImage == "" {
+		source.RuntimeImage = config.RuntimeImage
+	}
+	if source.SourceUrl == "" {
+		source.SourceUrl = GetNewSourceURL(instance, config)
+	}
+	if source.Description == "" {
+		source.Description = config.Description
+	}
+	if source.RevisionId == "" {
+		source.RevisionId = GetNewRevisionId(instance, config)
+	}
+	if source.RevisionId == "" {
+		source.RevisionId = DefaultRevisionId
+	}
+	if instance.Status.S2iBuildResult.ImageName == "" {
+		instance.Status.S2iBuildResult.ImageName = config.ImageName
+	}
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2019 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s2irun
+
+import (
+	"testing"
+
+	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
+)
+
+func TestSetPinnedSource(t *testing.T) {
+	const digest = "sha256:3f7a9c2b1e4d3f7a9c2b1e4d3f7a9c2b1e4d3f7a9c2b1e4d3f7a9c2b1e4d3f7a"
+	instance := &devopsv1alpha1.S2iRun{
+		Spec: devopsv1alpha1.S2iRunSpec{PinResolvedSource: true},
+		Status: devopsv1alpha1.S2iRunStatus{
+			S2iBuildSource: &devopsv1alpha1.S2iBuildSource{
+				CommitID:           "e9c2f7a3b1d4",
+				BuilderImage:       "kubesphere/java-8-centos7:v2.1.0@" + digest,
+				BuilderImageDigest: digest,
+			},
+		},
+	}
+
+	config := devopsv1alpha1.S2iConfig{RevisionId: "master", BuilderImage: "kubesphere/java-8-centos7:v2.1.0"}
+	setPinnedSource(instance, &config, buildVariant{})
+	if config.RevisionId != "e9c2f7a3b1d4" {
+		t.Errorf("revision is not pinned, got %s", config.RevisionId)
+	}
+	if config.BuilderImage != "kubesphere/java-8-centos7:v2.1.0@"+digest {
+		t.Errorf("builder image is not pinned, got %s", config.BuilderImage)
+	}
+
+	config = devopsv1alpha1.S2iConfig{RevisionId: "master", BuilderImage: "kubesphere/java-8-centos7:v2.1.0"}
+	setPinnedSource(instance, &config, buildVariant{Name: "arm64"})
+	if config.RevisionId != "e9c2f7a3b1d4" || config.BuilderImage != "kubesphere/java-8-centos7:v2.1.0" {
+		t.Errorf("only the revision should be pinned for variants, got %s %s", config.RevisionId, config.BuilderImage)
+	}
+
+	instance.Spec.PinResolvedSource = false
+	config = devopsv1alpha1.S2iConfig{RevisionId: "master", BuilderImage: "kubesphere/java-8-centos7:v2.1.0"}
+	setPinnedSource(instance, &config, buildVariant{})
+	if config.RevisionId != "master" || config.BuilderImage != "kubesphere/java-8-centos7:v2.1.0" {
+		t.Errorf("source should not be pinned, got %s %s", config.RevisionId, config.BuilderImage)
+	}
+}
+
+func TestSetDefaultSource(t *testing.T) {
+	instance := &devopsv1alpha1.S2iRun{
+		Spec: devopsv1alpha1.S2iRunSpec{NewRevisionId: "release"},
+		Status: devopsv1alpha1.S2iRunStatus{
+			S2iBuildSource: &devopsv1alpha1.S2iBuildSource{},
+			S2iBuildResult: &devopsv1alpha1.S2iBuildResult{},
+		},
+	}
+	keepResolvedSource(instance.Status.S2iBuildSource, &devopsv1alpha1.S2iBuildSource{CommitID: "e9c2f7a3b1d4"})
+	setDefaultSource(instance, devopsv1alpha1.S2iConfig{RevisionId: "master", BuilderImage: "builder", ImageName: "image"})
+	source := instance.Status.S2iBuildSource
+	if source.CommitID != "e9c2f7a3b1d4" || source.RevisionId != "release" || source.BuilderImage != "builder" {
+		t.Errorf("unexpected build source %+v", source)
+	}
+
+	instance.Status.S2iBuildSource = &devopsv1alpha1.S2iBuildSource{RevisionId: "e9c2f7a3b1d4", BuilderImage: "builder@sha256:abc"}
+	setDefaultSource(instance, devopsv1alpha1.S2iConfig{RevisionId: "master", BuilderImage: "builder"})
+	source = instance.Status.S2iBuildSource
+	if source.RevisionId != "e9c2f7a3b1d4" || source.BuilderImage != "builder@sha256:abc" {
+		t.Errorf("the reported build source is overwritten %+v", source)
+	}
+}
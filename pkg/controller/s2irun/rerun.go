@@ -0,0 +1,63 @@
+/*
+Copyright 2019 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s2irun
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// reconcileRerun creates a new run with the same inputs if the rerun annotation is set on the run. The new
+// run has a name derived from the run and its resource version, so that it is created only once even if the
+// request is handled again, and the annotation is removed only after the new run is created.
+func (r *ReconcileS2iRun) reconcileRerun(instance *devopsv1alpha1.S2iRun) error {
+	value, ok := instance.Annotations[devopsv1alpha1.RerunAnnotation]
+	if !ok {
+		return nil
+	}
+	rerun := instance.NewRerun(value == devopsv1alpha1.RerunPinned)
+	rerun.GenerateName = ""
+	rerun.Name = getRerunName(instance)
+
+	log.Info("Creating rerun S2iRun", "Namespace", instance.Namespace, "Name", instance.Name)
+	if err := r.Create(context.TODO(), rerun); err != nil && !k8serror.IsAlreadyExists(err) {
+		log.Error(err, "Failed to create rerun S2iRun", "Namespace", instance.Namespace, "Name", instance.Name)
+		return err
+	}
+	log.Info("Created rerun S2iRun", "Namespace", rerun.Namespace, "Name", rerun.Name, "RerunOf", instance.Name)
+
+	// the annotation is removed by a patch, the resource version is not changed by a conflict in the meantime
+	origin := instance.DeepCopy()
+	delete(instance.Annotations, devopsv1alpha1.RerunAnnotation)
+	if err := r.Patch(context.TODO(), instance, client.MergeFrom(origin)); err != nil {
+		log.Error(err, "Failed to remove rerun annotation", "Namespace", instance.Namespace, "Name", instance.Name)
+		return err
+	}
+	return nil
+}
+
+// getRerunName returns the name of the new run which reruns the run, e.g. hello-rerun-a1cfd307. The run is
+// changed when the rerun annotation is set, so the reruns asked by setting the annotation again have other names.
+func getRerunName(instance *devopsv1alpha1.S2iRun) string {
+	sum := sha256.Sum256([]byte(string(instance.UID) + "/" + instance.ResourceVersion))
+	return instance.Name + "-rerun-" + hex.EncodeToString(sum[:4])
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2019 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s2irun
+
+import (
+	"context"
+	"testing"
+
+	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcileRerun(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := devopsv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	run := &devopsv1alpha1.S2iRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello-1", Namespace: "default", UID: "1",
+			Annotations: map[string]string{devopsv1alpha1.RerunAnnotation: devopsv1alpha1.RerunPinned}},
+		Spec:   devopsv1alpha1.S2iRunSpec{BuilderName: "hello"},
+		Status: devopsv1alpha1.S2iRunStatus{S2iBuildSource: &devopsv1alpha1.S2iBuildSource{CommitID: "e9c2f7a3b1d4"}},
+	}
+	r := &ReconcileS2iRun{scheme: scheme, Client: fake.NewFakeClientWithScheme(scheme, run)}
+
+	instance := &devopsv1alpha1.S2iRun{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "hello-1"}, instance); err != nil {
+		t.Fatal(err)
+	}
+	// the request handled again before the annotation is removed reruns the run only once
+	stale := instance.DeepCopy()
+	name := getRerunName(instance)
+	if err := r.reconcileRerun(instance); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.reconcileRerun(stale); err != nil {
+		t.Fatal(err)
+	}
+
+	runs := &devopsv1alpha1.S2iRunList{}
+	if err := r.List(context.TODO(), runs); err != nil {
+		t.Fatal(err)
+	}
+	if len(runs.Items) != 2 {
+		t.Fatalf("the run should be rerun once, got %d runs", len(runs.Items))
+	}
+	rerun := &devopsv1alpha1.S2iRun{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: name}, rerun); err != nil {
+		t.Fatal(err)
+	}
+	if rerun.Annotations[devopsv1alpha1.RerunOfAnnotation] != "hello-1" || rerun.Spec.NewRevisionId != "e9c2f7a3b1d4" {
+		t.Errorf("the rerun should pin the commit of the run, got %+v", rerun)
+	}
+	if err := r.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "hello-1"}, instance); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := instance.Annotations[devopsv1alpha1.RerunAnnotation]; ok {
+		t.Errorf("the rerun annotation should be removed, got %v", instance.Annotations)
+	}
+	if getRerunName(instance) == name {
+		t.Errorf("the run should be rerun with another name after it is changed")
+	}
+}
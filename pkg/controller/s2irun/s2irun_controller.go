@@ -21,6 +21,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"sort"
 	"time"
 
 	"github.com/kubesphere/s2ioperator/pkg/config"
@@ -28,14 +29,16 @@ import (
 
 	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
 	loghandler "github.com/kubesphere/s2ioperator/pkg/handler/log"
-	"github.com/kubesphere/s2ioperator/pkg/util/reflectutils"
-	v1 "k8s.io/api/apps/v1"
+	"github.com/kubesphere/s2ioperator/pkg/metrics"
+	"github.com/kubesphere/s2ioperator/pkg/workload"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/kubernetes"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
@@ -52,9 +55,6 @@ const (
 	S2iRunBuilderLabel       = "labels.devops.kubesphere.io/builder-name"
 	AnnotationBuildResultKey = "s2iBuildResult"
 	AnnotationBuildSourceKey = "s2iBuildSource"
-	RegularServiceAccount    = "s2irun"
-	RegularRoleName          = "s2i-regular-role"
-	RegularRoleBinding       = "s2i-regular-rolebinding"
 	DefaultRevisionId        = "master"
 )
 
@@ -66,15 +66,26 @@ const (
 // Add creates a new S2iRun Controller and adds it to the Manager with default RBAC. The Manager will set fields on the Controller
 // and Start it when the Manager is Started.
 func Add(mgr manager.Manager, cfg *config.Config) error {
-	return add(mgr, newReconciler(mgr, cfg))
+	logURLGetter, err := loghandler.NewLogURLGetter(cfg.LogURL)
+	if err != nil {
+		return err
+	}
+	logArchiver, err := loghandler.NewLogArchiver(cfg.LogArchive, mgr.GetClient())
+	if err != nil {
+		return err
+	}
+	return add(mgr, newReconciler(mgr, cfg, logURLGetter, logArchiver))
 }
 
 // newReconciler returns a new reconcile.Reconciler
-func newReconciler(mgr manager.Manager, cfg *config.Config) reconcile.Reconciler {
+func newReconciler(mgr manager.Manager, cfg *config.Config, logURLGetter loghandler.LogURLGetter, logArchiver loghandler.LogArchiver) reconcile.Reconciler {
 	return &ReconcileS2iRun{
-		Client: mgr.GetClient(),
-		scheme: mgr.GetScheme(),
-		cfg:    cfg,
+		Client:       mgr.GetClient(),
+		kubeClient:   kubernetes.NewForConfigOrDie(mgr.GetConfig()),
+		logURLGetter: logURLGetter,
+		logArchiver:  logArchiver,
+		scheme:       mgr.GetScheme(),
+		cfg:          cfg,
 	}
 }
 
@@ -106,17 +117,53 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 	if err != nil {
 		return err
 	}
+	// pods are watched to find out the build which can not pull the image, the job is not changed in this case
+	err = c.Watch(&source.Kind{Type: &corev1.Pod{}}, handler.EnqueueRequestsFromMapFunc(func(obj client.Object) []reconcile.Request {
+		return podToS2iRun(mgr.GetClient(), obj)
+	}))
+	if err != nil {
+		return err
+	}
 
 	return nil
 }
 
+// podToS2iRun returns the s2irun which owns the job of the pod
+func podToS2iRun(c client.Client, obj client.Object) []reconcile.Request {
+	jobName, ok := obj.GetLabels()["job-name"]
+	if !ok {
+		return nil
+	}
+	job := &batchv1.Job{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Namespace: obj.GetNamespace(), Name: jobName}, job); err != nil {
+		return nil
+	}
+	owner := metav1.GetControllerOf(job)
+	if owner == nil || owner.Kind != devopsv1alpha1.ResourceKindS2iRun {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: job.Namespace, Name: owner.Name}}}
+}
+
 var _ reconcile.Reconciler = &ReconcileS2iRun{}
 
+// buildInfo is written by s2irun to the termination message of its container after the build
+type buildInfo struct {
+	S2iBuildResult *devopsv1alpha1.S2iBuildResult `json:"s2iBuildResult,omitempty"`
+	S2iBuildSource *devopsv1alpha1.S2iBuildSource `json:"s2iBuildSource,omitempty"`
+}
+
 // ReconcileS2iRun reconciles a S2iRun object
 type ReconcileS2iRun struct {
 	client.Client
-	scheme *runtime.Scheme
-	cfg    *config.Config
+	// kubeClient reads the log of the build pods
+	kubeClient kubernetes.Interface
+	// logURLGetter returns the url of the log of the build pods
+	logURLGetter loghandler.LogURLGetter
+	// logArchiver archives the log of the pods of the run after the jobs are finished, it is nil if the log is not archived
+	logArchiver loghandler.LogArchiver
+	scheme      *runtime.Scheme
+	cfg         *config.Config
 }
 
 // Reconcile reads that state of the cluster for a S2iRun object and makes changes based on the state read
@@ -127,14 +174,19 @@ type ReconcileS2iRun struct {
 // +kubebuilder:rbac:groups=devops.kubesphere.io,resources=s2iruns,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=devops.kubesphere.io,resources=s2iruns/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=devops.kubesphere.io,resources=s2ibuildertemplates,verbs=get;list;watch
-// +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=pods/log,verbs=get
 // +kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch;create;update;patch
 // +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch
 // +kubebuilder:rbac:groups=extensions,resources=deployments,verbs=get;list;watch;create;update;patch
-// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get
+// +kubebuilder:rbac:groups=apps,resources=daemonsets,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=batch,resources=cronjobs,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=serving.knative.dev,resources=services,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=argoproj.io,resources=rollouts,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;create;update
 // +kubebuilder:rbac:groups=core,resources=serviceaccounts,verbs=get;list;watch;create
 // +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs=get;list;watch;create
-// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles,verbs=get;list;watch;create
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles,verbs=get;list;watch;create;update
 // +kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
 
 func (r *ReconcileS2iRun) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
@@ -145,16 +197,25 @@ func (r *ReconcileS2iRun) Reconcile(ctx context.Context, request reconcile.Reque
 	if err != nil {
 		if k8serror.IsNotFound(err) {
 			// Object not found, return.  Created objects are automatically garbage collected.
+			metrics.ForgetRun(request.NamespacedName)
 			return reconcile.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
 		return reconcile.Result{}, err
 	}
+	if err = r.reconcileRerun(instance); err != nil {
+		return reconcile.Result{}, err
+	}
 	origin := instance.DeepCopy()
+	instance.Status.RerunOf = instance.Annotations[devopsv1alpha1.RerunOfAnnotation]
 	//configmap setup
 	builder := &devopsv1alpha1.S2iBuilder{}
 	if err = r.Get(context.TODO(), types.NamespacedName{Name: instance.Spec.BuilderName, Namespace: instance.Namespace}, builder); err != nil {
 		if k8serror.IsNotFound(err) {
+			if instance.Status.RunState == devopsv1alpha1.Successful || instance.Status.RunState == devopsv1alpha1.Failed {
+				// the s2irun is orphaned by the deletion policy of its s2ibuilder
+				return reconcile.Result{}, nil
+			}
 			log.Info("Waiting for creating s2ibuilder", "Name", instance.Spec.BuilderName)
 			return reconcile.Result{RequeueAfter: time.Second * 15}, nil
 		}
@@ -172,70 +233,43 @@ func (r *ReconcileS2iRun) Reconcile(ctx context.Context, request reconcile.Reque
 		}
 	}
 
-	configmap, err := r.NewConfigMap(instance, *builder.Spec.Config, builder.Spec.FromTemplate)
-	if err != nil {
-		log.Error(err, "Failed to initialize a configmap")
-		return reconcile.Result{}, err
-	}
-	setConfigMapLabelAnnotations(instance, *builder.Spec.Config, builder.Spec.FromTemplate, configmap)
-	foundcm := &corev1.ConfigMap{}
-	err = r.Get(context.TODO(), types.NamespacedName{Name: configmap.Name, Namespace: configmap.Namespace}, foundcm)
-	if err != nil && k8serror.IsNotFound(err) {
-		log.Info("Creating ConfigMap", "Namespace", configmap.Namespace, "name", configmap.Name)
-		if err := controllerutil.SetControllerReference(instance, configmap, r.scheme); err != nil {
-			return reconcile.Result{}, err
-		}
-		err = r.Create(context.TODO(), configmap)
-		if err != nil {
-			if k8serror.IsAlreadyExists(err) {
-				log.Info("Skip creating 'Already-Exists' cm", "ConfigMap-Name", configmap.Name)
-				return reconcile.Result{RequeueAfter: time.Second * 5}, nil
-			}
-			log.Error(err, "Create configmap failed", "Namespace", configmap.Namespace, "name", configmap.Name)
-			return reconcile.Result{}, err
-		}
-	} else if err != nil {
-		return reconcile.Result{}, err
-	} else {
-		if !reflect.DeepEqual(configmap.Data, foundcm.Data) {
-			foundcm.Data = configmap.Data
-			log.Info("Updating job config", "Namespace", foundcm.Namespace, "Name", foundcm.Name)
-			err = r.Update(context.TODO(), foundcm)
-			if err != nil {
-				log.Error(err, "Failed to updating job config", "Namespace", foundcm.Namespace, "Name", foundcm.Name)
-				return reconcile.Result{}, err
-			}
-		}
-	}
-
 	//set Role
 	cr := &v12.Role{}
-	err = r.Get(context.TODO(), types.NamespacedName{Namespace: instance.Namespace, Name: RegularRoleName}, cr)
+	err = r.Get(context.TODO(), types.NamespacedName{Namespace: instance.Namespace, Name: r.cfg.RBAC.RoleName}, cr)
 	if err != nil && k8serror.IsNotFound(err) {
-		cr := r.NewRegularRole(RegularRoleName, instance.Namespace)
+		cr := r.NewRegularRole(r.cfg.RBAC.RoleName, instance.Namespace)
 		log.Info("Creating Role", "name", cr.Name)
 		err = r.Create(context.TODO(), cr)
 		if err != nil {
 			if k8serror.IsAlreadyExists(err) {
-				log.Info("Skip creating 'Already-Exists' Role", "Role-Name", RegularRoleName)
+				log.Info("Skip creating 'Already-Exists' Role", "Role-Name", r.cfg.RBAC.RoleName)
 				return reconcile.Result{RequeueAfter: time.Second * 5}, nil
 			}
 			log.Error(err, "Create Role failed", "name", cr.Name)
 			return reconcile.Result{}, err
 		}
 		log.Info("Creating Role", "name", cr.Name, "success")
+	} else if err != nil {
+		return reconcile.Result{}, err
+	} else if expected := r.NewRegularRole(r.cfg.RBAC.RoleName, instance.Namespace); !reflect.DeepEqual(cr.Rules, expected.Rules) {
+		// the role created by the old version grants more permissions than s2irun needs
+		cr.Rules = expected.Rules
+		log.Info("Updating Role", "Namespace", cr.Namespace, "name", cr.Name)
+		if err = r.Update(context.TODO(), cr); err != nil {
+			return reconcile.Result{}, err
+		}
 	}
 
 	//set service account
 	sa := &corev1.ServiceAccount{}
-	err = r.Get(context.TODO(), types.NamespacedName{Namespace: instance.Namespace, Name: RegularServiceAccount}, sa)
+	err = r.Get(context.TODO(), types.NamespacedName{Namespace: instance.Namespace, Name: r.cfg.RBAC.ServiceAccountName}, sa)
 	if err != nil && k8serror.IsNotFound(err) {
-		sa := r.NewServiceAccount(RegularServiceAccount, instance.Namespace)
+		sa := r.NewServiceAccount(r.cfg.RBAC.ServiceAccountName, instance.Namespace)
 		log.Info("Creating ServiceAccount", "Namespace", sa.Namespace, "name", sa.Name)
 		err = r.Create(context.TODO(), sa)
 		if err != nil {
 			if k8serror.IsAlreadyExists(err) {
-				log.Info("Skip creating 'Already-Exists' sa", "ServiceAccount-Name", RegularServiceAccount)
+				log.Info("Skip creating 'Already-Exists' sa", "ServiceAccount-Name", r.cfg.RBAC.ServiceAccountName)
 				return reconcile.Result{RequeueAfter: time.Second * 5}, nil
 			}
 			log.Error(err, "Create ServiceAccount failed", "Namespace", sa.Namespace, "name", sa.Name)
@@ -246,9 +280,9 @@ func (r *ReconcileS2iRun) Reconcile(ctx context.Context, request reconcile.Reque
 
 	//set RoleBinding
 	crb := &v12.RoleBinding{}
-	err = r.Get(context.TODO(), types.NamespacedName{Namespace: instance.Namespace, Name: RegularRoleBinding}, crb)
+	err = r.Get(context.TODO(), types.NamespacedName{Namespace: instance.Namespace, Name: r.cfg.RBAC.RoleBindingName}, crb)
 	if err != nil && k8serror.IsNotFound(err) {
-		crb := r.NewRoleBinding(RegularRoleBinding, RegularRoleName, sa.Name, sa.Namespace)
+		crb := r.NewRoleBinding(r.cfg.RBAC.RoleBindingName, r.cfg.RBAC.RoleName, r.cfg.RBAC.ServiceAccountName, instance.Namespace)
 		log.Info("Creating RoleBinding", "Namespace", crb.Namespace, "name", crb.Name)
 		err = r.Create(context.TODO(), crb)
 		if err != nil {
@@ -262,143 +296,296 @@ func (r *ReconcileS2iRun) Reconcile(ctx context.Context, request reconcile.Reque
 		log.Info("Creating RoleBinding", "Namespace", crb.Namespace, "name", crb.Name, "success")
 	}
 
-	//job set up
-	job, err := r.GenerateNewJob(instance, r.cfg.S2IRunJobTemplate)
+	// the run is built with the config of the builder when it is reconciled the first time
+	snapshot, err := r.reconcileConfigSnapshot(instance, builder)
 	if err != nil {
-		log.Error(err, "Failed to initialize a job")
 		return reconcile.Result{}, err
 	}
+	if snapshot == nil {
+		return reconcile.Result{RequeueAfter: time.Second * 5}, nil
+	}
+	builder = builder.DeepCopy()
+	builder.Spec.Config = &snapshot.Config
+
+	if instance.Spec.Matrix != nil {
+		result, err := r.reconcileMatrixJobs(instance, builder, snapshot)
+		if err != nil || !result.IsZero() {
+			return result, err
+		}
+	} else if len(builder.Spec.Config.Platforms) != 0 {
+		result, err := r.reconcilePlatformJobs(instance, builder, snapshot)
+		if err != nil || !result.IsZero() {
+			return result, err
+		}
+	} else {
+		//configmap and job set up
+		statuses, err := r.reconcileBuildVariants(instance, builder, snapshot, []buildVariant{{}})
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		if statuses == nil {
+			return reconcile.Result{RequeueAfter: time.Second * 5}, nil
+		}
+		setRunStatus(instance, statuses)
+		r.setFailureStatus(instance, statuses)
+		instance.Status.KubernetesJobName = statuses[0].JobName
+		instance.Status.Attempts = statuses[0].Attempts
+		instance.Status.AttemptResults = statuses[0].AttemptResults
+		if isBuildResultReported(statuses[0].BuildResult) {
+			instance.Status.S2iBuildResult = statuses[0].BuildResult
+		}
+	}
+
+	hookFailed := hasFailedHook(instance)
+	if instance.Status.RunState == devopsv1alpha1.Successful && len(builder.Spec.Config.PostBuildHooks) != 0 {
+		result, err := r.reconcilePostBuildJob(instance, builder)
+		if err != nil || !result.IsZero() {
+			return result, err
+		}
+		hookFailed = instance.Status.RunState == devopsv1alpha1.Failed
+	}
+
+	//set default info in resource S2IRun's status, the source reported by the build is not overwritten.
+	keepResolvedSource(instance.Status.S2iBuildSource, origin.Status.S2iBuildSource)
+	setDefaultSource(instance, *builder.Spec.Config)
+
+	// if job finished, scale workloads
+	if (instance.Status.RunState == devopsv1alpha1.Successful || instance.Status.RunState == devopsv1alpha1.Failed) && !hookFailed {
+		err = r.ScaleWorkLoads(instance, builder)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
+	if !reflect.DeepEqual(instance.Status, origin.Status) {
+		err = r.Status().Update(context.Background(), instance)
+		if err != nil {
+			log.Error(nil, "Failed to update s2irun status", "Namespace", instance.Namespace, "Name", instance.Name)
+			return reconcile.Result{}, err
+		}
+	}
+	metrics.RecordRunStatus(origin, instance)
+	return reconcile.Result{}, nil
+}
+
+// reconcileBuildJob makes sure the configmap and job which build the image of the variant exist. A nil
+// job is returned if the job or configmap exists in apiserver but not in cache, in this case the request
+// should be requeued.
+func (r *ReconcileS2iRun) reconcileBuildJob(instance *devopsv1alpha1.S2iRun, builder *devopsv1alpha1.S2iBuilder, snapshot *configSnapshot, variant buildVariant) (job *batchv1.Job, created bool, err error) {
+	// the config is frozen once it is created, the changes of the credentials and secrets of the builder and the
+	// source resolved by the build are not written to the config of the running build
+	configmap := &corev1.ConfigMap{}
+	err = r.Get(context.TODO(), types.NamespacedName{Name: getResourceName(instance, variant.Name, "configmap"), Namespace: instance.Namespace}, configmap)
+	if err != nil && k8serror.IsNotFound(err) {
+		configmap, err = r.NewConfigMap(instance, snapshot, variant)
+		if err != nil {
+			log.Error(err, "Failed to initialize a configmap")
+			return nil, false, err
+		}
+		setConfigMapLabelAnnotations(instance, *builder.Spec.Config, builder.Spec.FromTemplate, configmap)
+		log.Info("Creating ConfigMap", "Namespace", configmap.Namespace, "name", configmap.Name)
+		if err := controllerutil.SetControllerReference(instance, configmap, r.scheme); err != nil {
+			return nil, false, err
+		}
+		err = r.Create(context.TODO(), configmap)
+		if err != nil {
+			if k8serror.IsAlreadyExists(err) {
+				log.Info("Skip creating 'Already-Exists' cm", "ConfigMap-Name", configmap.Name)
+				return nil, false, nil
+			}
+			log.Error(err, "Create configmap failed", "Namespace", configmap.Namespace, "name", configmap.Name)
+			return nil, false, err
+		}
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	reloadable := r.cfg.Reloadable()
+	job, err = r.GenerateNewJob(instance, reloadable.S2IRunJobTemplate, variant.Name)
+	if err != nil {
+		log.Error(err, "Failed to initialize a job")
+		return nil, false, err
+	}
 	setJobLabelAnnotations(instance, *builder.Spec.Config, builder.Spec.FromTemplate, job)
-	setJobLabelandToleration(job, *builder.Spec.Config)
+	setJobLabelandToleration(job, *builder.Spec.Config, reloadable.Scheduling)
+	setJobPlatform(job, variant.Platform)
+	setJobPreBuildHooks(job, *builder.Spec.Config, configmap.Name, reloadable.GitCloneImage)
+	setJobBuildCache(job, builder)
 	found := &batchv1.Job{}
 	err = r.Get(context.TODO(), types.NamespacedName{Name: job.Name, Namespace: job.Namespace}, found)
 	if err != nil && k8serror.IsNotFound(err) {
 		log.Info("Creating Job", "Namespace", job.Namespace, "Name", job.Name)
 		if err := controllerutil.SetControllerReference(instance, job, r.scheme); err != nil {
-			return reconcile.Result{}, err
+			return nil, false, err
 		}
 		err = r.Create(context.TODO(), job)
 		if err != nil {
 			//in some situation we cannot find job in cache, however it does exist in apiserver, in this case we just requeue
 			if k8serror.IsAlreadyExists(err) {
 				log.Info("Skip creating 'Already-Exists' job", "Job-Name", job.Name)
-				return reconcile.Result{RequeueAfter: time.Second * 5}, nil
+				return nil, false, nil
 			}
 			log.Error(err, "Failed to create Job", "Namespace", job.Namespace, "Name", job.Name)
-			return reconcile.Result{}, err
-		} else {
-			return reconcile.Result{}, nil
+			return nil, false, err
 		}
+		return job, true, nil
 	} else if err != nil {
-		return reconcile.Result{}, err
+		return nil, false, err
 	}
-	instance.Status.KubernetesJobName = found.Name
-	instance.Status.StartTime = found.Status.StartTime
-	if found.Status.Active == 1 {
-		log.Info("Job is running", "start time", found.Status.StartTime)
-		instance.Status.RunState = devopsv1alpha1.Running
-		logURL, err := r.GetLogURL(found)
-		if err != nil {
-			return reconcile.Result{}, err
-		}
-		instance.Status.LogURL = logURL
-	} else if found.Status.Failed == 1 {
-		log.Info("Job failed")
-		instance.Status.RunState = devopsv1alpha1.Failed
-		instance.Status.CompletionTime = found.Status.CompletionTime
-		logURL, err := r.GetLogURL(found)
-		if err != nil {
-			return reconcile.Result{}, err
+	return found, false, nil
+}
+
+// getJobRunState returns the run state according to the conditions of the job. A job which failed but
+// will be retried is still running.
+func getJobRunState(job *batchv1.Job) devopsv1alpha1.RunState {
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
 		}
-		instance.Status.LogURL = logURL
-	} else if found.Status.Succeeded == 1 {
-		log.Info("Job completed", "time", found.Status.CompletionTime)
-		instance.Status.RunState = devopsv1alpha1.Successful
-		instance.Status.CompletionTime = found.Status.CompletionTime
-		logURL, err := r.GetLogURL(found)
-		if err != nil {
-			return reconcile.Result{}, err
+		switch condition.Type {
+		case batchv1.JobComplete:
+			return devopsv1alpha1.Successful
+		case batchv1.JobFailed:
+			return devopsv1alpha1.Failed
 		}
-		instance.Status.LogURL = logURL
-	} else {
-		instance.Status.RunState = devopsv1alpha1.Unknown
 	}
+	if job.Status.Active > 0 || job.Status.Failed > 0 {
+		return devopsv1alpha1.Running
+	}
+	return devopsv1alpha1.Unknown
+}
+
+// getJobAttempts returns the number of pods started by the job
+func getJobAttempts(job *batchv1.Job) int32 {
+	return job.Status.Active + job.Status.Failed + job.Status.Succeeded
+}
+
+// getPodRunState returns the run state according to the phase of the pod
+func getPodRunState(pod *corev1.Pod) devopsv1alpha1.RunState {
+	switch pod.Status.Phase {
+	case corev1.PodPending:
+		return devopsv1alpha1.NotRunning
+	case corev1.PodRunning:
+		return devopsv1alpha1.Running
+	case corev1.PodSucceeded:
+		return devopsv1alpha1.Successful
+	case corev1.PodFailed:
+		return devopsv1alpha1.Failed
+	}
+	return devopsv1alpha1.Unknown
+}
 
-	// set s2irun status
+// listJobPods returns the pods of the job ordered by the time they are created, the last one
+// is the latest attempt.
+func (r *ReconcileS2iRun) listJobPods(job *batchv1.Job) ([]corev1.Pod, error) {
 	pods := &corev1.PodList{}
-	err = r.List(context.TODO(), pods, client.InNamespace(found.Namespace), client.MatchingLabels(map[string]string{
-		"job-name": found.Name,
+	err := r.List(context.TODO(), pods, client.InNamespace(job.Namespace), client.MatchingLabels(map[string]string{
+		"job-name": job.Name,
 	}))
 	if err != nil {
-		log.Error(nil, "Error in get pod of job")
-		return reconcile.Result{}, nil
+		return nil, err
 	}
-	if len(pods.Items) == 0 {
-		return reconcile.Result{}, fmt.Errorf("cannot find any pod of the job %s", found.Name)
+	sort.Slice(pods.Items, func(i, j int) bool {
+		ti, tj := pods.Items[i].CreationTimestamp, pods.Items[j].CreationTimestamp
+		if ti.Equal(&tj) {
+			return pods.Items[i].Name < pods.Items[j].Name
+		}
+		return ti.Before(&tj)
+	})
+	return pods.Items, nil
+}
+
+// getLatestJobPod returns the pod of the latest attempt of the job, nil is returned if the job
+// has no pod yet.
+func (r *ReconcileS2iRun) getLatestJobPod(job *batchv1.Job) (*corev1.Pod, error) {
+	pods, err := r.listJobPods(job)
+	if err != nil || len(pods) == 0 {
+		return nil, err
 	}
-	foundPod := pods.Items[0]
+	return &pods[len(pods)-1], nil
+}
 
-	s2iBuildSource := &devopsv1alpha1.S2iBuildSource{}
-	if buildSource := foundPod.Annotations[AnnotationBuildSourceKey]; buildSource != "" {
-		err = json.Unmarshal([]byte(buildSource), s2iBuildSource)
-		if err != nil {
-			return reconcile.Result{}, err
-		}
+// getPodLogURL returns the url of the log of the pod which runs the job of the run
+func (r *ReconcileS2iRun) getPodLogURL(instance *devopsv1alpha1.S2iRun, pod *corev1.Pod) (string, error) {
+	if r.logURLGetter == nil {
+		return "", nil
 	}
-	instance.Status.S2iBuildSource = s2iBuildSource
+	podLog := loghandler.PodLog{
+		Namespace: pod.Namespace,
+		Pod:       pod.Name,
+		Job:       pod.Labels["job-name"],
+		S2iRun:    instance.Name,
+	}
+	if len(pod.Spec.Containers) != 0 {
+		podLog.Container = pod.Spec.Containers[0].Name
+	}
+	return r.logURLGetter.GetURLOfPodLog(podLog)
+}
 
-	s2iBuildResult := &devopsv1alpha1.S2iBuildResult{}
-	if buildResult := foundPod.Annotations[AnnotationBuildResultKey]; buildResult != "" {
-		err = json.Unmarshal([]byte(buildResult), s2iBuildResult)
+// getAttemptResults returns the outcome of every pod of the job
+func (r *ReconcileS2iRun) getAttemptResults(instance *devopsv1alpha1.S2iRun, pods []corev1.Pod) ([]devopsv1alpha1.S2iRunAttempt, error) {
+	var attempts []devopsv1alpha1.S2iRunAttempt
+	for i := range pods {
+		logURL, err := r.getPodLogURL(instance, &pods[i])
 		if err != nil {
-			return reconcile.Result{}, err
+			return nil, err
+		}
+		attempt := devopsv1alpha1.S2iRunAttempt{
+			PodName:   pods[i].Name,
+			RunState:  getPodRunState(&pods[i]),
+			LogURL:    logURL,
+			StartTime: pods[i].Status.StartTime,
+		}
+		for _, status := range pods[i].Status.ContainerStatuses {
+			if status.Name == devopsv1alpha1.S2iRunContainerName && status.State.Terminated != nil {
+				finishedAt := status.State.Terminated.FinishedAt
+				attempt.CompletionTime = &finishedAt
+			}
 		}
+		attempts = append(attempts, attempt)
 	}
-	instance.Status.S2iBuildResult = s2iBuildResult
+	return attempts, nil
+}
 
-	//set default info in resource S2IRun's status.
-	instance.Status.S2iBuildSource.BuilderImage = builder.Spec.Config.BuilderImage
-	instance.Status.S2iBuildSource.SourceUrl = builder.Spec.Config.SourceURL
-	instance.Status.S2iBuildSource.Description = builder.Spec.Config.Description
-	instance.Status.S2iBuildResult.ImageName = builder.Spec.Config.ImageName
-	if builder.Spec.Config.RevisionId == "" {
-		instance.Status.S2iBuildSource.RevisionId = DefaultRevisionId
-	} else {
-		instance.Status.S2iBuildSource.RevisionId = builder.Spec.Config.RevisionId
+// getBuildInfoFromPod returns the build source and result which s2irun writes in the termination message
+// of its container. The annotations of the pod are read if the pod is created by an old s2irun image.
+func getBuildInfoFromPod(pod *corev1.Pod) (*devopsv1alpha1.S2iBuildSource, *devopsv1alpha1.S2iBuildResult) {
+	info := &buildInfo{}
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name != devopsv1alpha1.S2iRunContainerName || status.State.Terminated == nil || status.State.Terminated.Message == "" {
+			continue
+		}
+		if err := json.Unmarshal([]byte(status.State.Terminated.Message), info); err != nil {
+			log.Info("Failed to read build result from termination message", "Namespace", pod.Namespace, "Pod", pod.Name, "Error", err.Error())
+		}
 	}
 
-	// if job finished, scale workloads
-	if instance.Status.RunState == devopsv1alpha1.Successful || instance.Status.RunState == devopsv1alpha1.Failed {
-		err = r.ScaleWorkLoads(instance, builder)
-		if err != nil {
-			return reconcile.Result{}, err
+	if info.S2iBuildSource == nil {
+		info.S2iBuildSource = &devopsv1alpha1.S2iBuildSource{}
+		if buildSource := pod.Annotations[AnnotationBuildSourceKey]; buildSource != "" {
+			if err := json.Unmarshal([]byte(buildSource), info.S2iBuildSource); err != nil {
+				log.Info("Failed to read build source from annotation", "Namespace", pod.Namespace, "Pod", pod.Name, "Error", err.Error())
+			}
 		}
 	}
-
-	if !reflect.DeepEqual(instance.Status, origin.Status) {
-		err = r.Status().Update(context.Background(), instance)
-		if err != nil {
-			log.Error(nil, "Failed to update s2irun status", "Namespace", instance.Namespace, "Name", instance.Name)
-			return reconcile.Result{}, err
+	if info.S2iBuildResult == nil {
+		info.S2iBuildResult = &devopsv1alpha1.S2iBuildResult{}
+		if buildResult := pod.Annotations[AnnotationBuildResultKey]; buildResult != "" {
+			if err := json.Unmarshal([]byte(buildResult), info.S2iBuildResult); err != nil {
+				log.Info("Failed to read build result from annotation", "Namespace", pod.Namespace, "Pod", pod.Name, "Error", err.Error())
+			}
 		}
 	}
-	return reconcile.Result{}, nil
+	return info.S2iBuildSource, info.S2iBuildResult
 }
 
-func (r *ReconcileS2iRun) GetLogURL(job *batchv1.Job) (string, error) {
-	pods := &corev1.PodList{}
-
-	err := r.List(context.TODO(), pods, client.InNamespace(job.Namespace), client.MatchingLabels(map[string]string{
-		"job-name": job.Name,
-	}))
-	if err != nil {
-		log.Error(nil, "Error in get pod of job")
-		return "", nil
+// GetLogURL returns the log url of the latest pod of the job, it is empty if the job has no pod yet.
+func (r *ReconcileS2iRun) GetLogURL(instance *devopsv1alpha1.S2iRun, job *batchv1.Job) (string, error) {
+	pod, err := r.getLatestJobPod(job)
+	if err != nil || pod == nil {
+		return "", err
 	}
-	if len(pods.Items) == 0 {
-		return "", fmt.Errorf("cannot find any pod of the job %s", job.Name)
-	}
-	return loghandler.GetKubesphereLogger().GetURLOfPodLog(pods.Items[0].Namespace, pods.Items[0].Name)
+	return r.getPodLogURL(instance, pod)
 }
 
 func GetNewImageName(instance *devopsv1alpha1.S2iRun, config devopsv1alpha1.S2iConfig) string {
@@ -409,6 +596,15 @@ func GetNewImageName(instance *devopsv1alpha1.S2iRun, config devopsv1alpha1.S2iC
 	}
 }
 
+// GetVariantImageName returns the image pushed by the build job of a variant, the tag of the image has
+// the variant name as suffix, e.g. hello/world:latest-arm64v8
+func GetVariantImageName(instance *devopsv1alpha1.S2iRun, config devopsv1alpha1.S2iConfig, variantName string) string {
+	if variantName == "" {
+		return GetNewImageName(instance, config)
+	}
+	return GetNewImageName(instance, config) + "-" + variantName
+}
+
 func GetNewRevisionId(instance *devopsv1alpha1.S2iRun, config devopsv1alpha1.S2iConfig) string {
 	if instance.Spec.NewRevisionId != "" {
 		return instance.Spec.NewRevisionId
@@ -425,186 +621,123 @@ func GetNewSourceURL(instance *devopsv1alpha1.S2iRun, config devopsv1alpha1.S2iC
 	}
 }
 
-// ScaleWorkLoads will auto scale workloads define in s2ibuilder's annotations
+// ScaleWorkLoads will auto scale workloads define in s2ibuilder's spec.deployTargets. The runs with a build
+// matrix are not deployed, since the cells push their own tags rather than the tag of the run.
 func (r *ReconcileS2iRun) ScaleWorkLoads(instance *devopsv1alpha1.S2iRun, builder *devopsv1alpha1.S2iBuilder) error {
-	if _, ok := instance.Annotations[devopsv1alpha1.S2iRunDoNotAutoScaleAnnotations]; !ok {
-		if annotation, ok := builder.Annotations[devopsv1alpha1.AutoScaleAnnotations]; ok {
-			log.Info("Start AutoScale Workloads")
-			if instance.Annotations == nil {
-				instance.Annotations = make(map[string]string)
-			}
-			origin := instance.DeepCopy()
-			s2iAutoScale := make([]devopsv1alpha1.S2iAutoScale, 0)
-			completedScaleWorkloads := make([]devopsv1alpha1.S2iAutoScale, 0)
-			if err := json.Unmarshal([]byte(annotation), &s2iAutoScale); err != nil {
-				return err
-			}
-			errs := make([]error, 0)
-			if completedScaleAnnotations, ok := instance.Annotations[devopsv1alpha1.S2irCompletedScaleAnnotations]; ok {
-				if err := json.Unmarshal([]byte(completedScaleAnnotations), &completedScaleWorkloads); err != nil {
-					return err
-				}
+	if _, ok := instance.Annotations[devopsv1alpha1.S2iRunDoNotAutoScaleAnnotations]; ok {
+		return nil
+	}
+	if instance.Spec.Matrix != nil {
+		return nil
+	}
+	targets, err := builder.GetDeployTargets()
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+	log.Info("Start AutoScale Workloads")
+	if instance.Annotations == nil {
+		instance.Annotations = make(map[string]string)
+	}
+	origin := instance.DeepCopy()
+	completedScaleWorkloads := make([]devopsv1alpha1.S2iDeployTarget, 0)
+	if completedScaleAnnotations, ok := instance.Annotations[devopsv1alpha1.S2irCompletedScaleAnnotations]; ok {
+		if err := json.Unmarshal([]byte(completedScaleAnnotations), &completedScaleWorkloads); err != nil {
+			return err
+		}
+	}
+	errs := make([]error, 0)
+	for _, target := range targets {
+		hasScaled := false
+		for _, completedScale := range completedScaleWorkloads {
+			if reflect.DeepEqual(target, completedScale) {
+				hasScaled = true
+				break
 			}
-			for _, scale := range s2iAutoScale {
-				hasScaled := false
-				for _, completedScale := range completedScaleWorkloads {
-					if reflect.DeepEqual(scale, completedScale) {
-						hasScaled = true
-						break
-					}
-				}
-				if hasScaled {
-					continue
-				}
-				switch scale.Kind {
-
-				case devopsv1alpha1.KindDeployment:
-					deploy := &v1.Deployment{}
-					err := r.Get(context.TODO(), types.NamespacedName{Namespace: instance.Namespace, Name: scale.Name}, deploy)
-					if err != nil && k8serror.IsNotFound(err) {
-						log.Info("Workload not found", "ns", instance.Namespace, "deploy", deploy.Name)
-						continue
-					} else if err != nil {
-						return err
-					}
-
-					log.Info("Autoscale Deployment", "ns", instance.Namespace, "deploy", deploy.Name)
-
-					//Check if initialization is required
-					if deploy.Annotations == nil || deploy.Annotations[devopsv1alpha1.WorkLoadCompletedInitAnnotations] == "" {
-						//If replicas == 0, set replicas to InitReplicas
-						if deploy.Annotations == nil {
-							deploy.Annotations = make(map[string]string)
-						}
-						if instance.Status.RunState == devopsv1alpha1.Successful {
-							if *deploy.Spec.Replicas == 0 {
-								if scale.InitReplicas != nil {
-									*deploy.Spec.Replicas = *scale.InitReplicas
-								} else {
-									*deploy.Spec.Replicas = 1
-								}
-							}
-							deploy.Annotations[devopsv1alpha1.WorkLoadCompletedInitAnnotations] = devopsv1alpha1.Successful
-						} else if instance.Status.RunState == devopsv1alpha1.Failed {
-							deploy.Annotations[devopsv1alpha1.WorkLoadCompletedInitAnnotations] = devopsv1alpha1.Failed
-						}
-					}
-					newImageName := GetNewImageName(instance, *builder.Spec.Config)
-					// if only one container update containers image config
-					if len(deploy.Spec.Template.Spec.Containers) == 1 {
-						if deploy.Spec.Template.Spec.Containers[0].Image == newImageName {
-							deploy.Spec.Template.Spec.Containers[0].ImagePullPolicy = corev1.PullAlways
-						} else {
-							deploy.Spec.Template.Spec.Containers[0].Image = newImageName
-						}
-					} else {
-						for _, container := range deploy.Spec.Template.Spec.Containers {
-							if reflectutils.Contains(container.Name, scale.Containers) {
-								if container.Image == newImageName {
-									container.ImagePullPolicy = corev1.PullAlways
-								} else {
-									container.Image = newImageName
-								}
-							}
-						}
-					}
-					if deploy.Spec.Template.Labels == nil {
-						deploy.Spec.Template.Labels = make(map[string]string)
-					}
-
-					deploy.Spec.Template.Labels[devopsv1alpha1.S2iRunLabel] = instance.Name
-
-					log.Info("Update deployment", "ns", deploy.Namespace, "deploy", deploy.Name)
-					err = r.Update(context.TODO(), deploy)
-					if err != nil && k8serror.IsNotFound(err) {
-						errs = append(errs, err)
-						continue
-					} else if err != nil {
-						return err
-					}
-					completedScaleWorkloads = append(completedScaleWorkloads, scale)
-
-				case devopsv1alpha1.KindStatefulSet:
-					statefulSet := &v1.StatefulSet{}
-					err := r.Get(context.TODO(), types.NamespacedName{Namespace: instance.Namespace, Name: scale.Name}, statefulSet)
-					if err != nil {
-						log.Info("Workload not found", "ns", instance.Namespace, "deploy", scale.Name)
-						continue
-					}
-					log.Info("Autoscale StatefulSet", "ns", instance.Namespace, "statefulSet", statefulSet.Name)
-
-					//Check if initialization is required
-					if statefulSet.Annotations == nil || statefulSet.Annotations[devopsv1alpha1.WorkLoadCompletedInitAnnotations] == "" {
-						if statefulSet.Annotations == nil {
-							statefulSet.Annotations = make(map[string]string)
-						}
-						if instance.Status.RunState == devopsv1alpha1.Successful {
-							//If replicas == 0, set replicas to InitReplicas
-							if *statefulSet.Spec.Replicas == 0 {
-								if scale.InitReplicas != nil {
-									*statefulSet.Spec.Replicas = *scale.InitReplicas
-								} else {
-									*statefulSet.Spec.Replicas = 1
-								}
-							}
-							statefulSet.Annotations[devopsv1alpha1.WorkLoadCompletedInitAnnotations] = devopsv1alpha1.Successful
-						} else {
-							statefulSet.Annotations[devopsv1alpha1.WorkLoadCompletedInitAnnotations] = devopsv1alpha1.Failed
-						}
-					}
-
-					newImageName := GetNewImageName(instance, *builder.Spec.Config)
-					if len(statefulSet.Spec.Template.Spec.Containers) == 1 {
-						if statefulSet.Spec.Template.Spec.Containers[0].Image == newImageName {
-							statefulSet.Spec.Template.Spec.Containers[0].ImagePullPolicy = corev1.PullAlways
-						} else {
-							statefulSet.Spec.Template.Spec.Containers[0].Image = newImageName
-						}
-					} else {
-						for _, container := range statefulSet.Spec.Template.Spec.Containers {
-							if reflectutils.Contains(container.Name, scale.Containers) {
-								if container.Image == newImageName {
-									container.ImagePullPolicy = corev1.PullAlways
-								} else {
-									container.Image = newImageName
-								}
-							}
-						}
-					}
-					if statefulSet.Spec.Template.Labels == nil {
-						statefulSet.Spec.Template.Labels = make(map[string]string)
-					}
-
-					statefulSet.Spec.Template.Labels[devopsv1alpha1.S2iRunLabel] = instance.Name
-
-					log.Info("Update statefulSet", "ns", statefulSet.Namespace, "statefulSet", statefulSet.Name)
-					err = r.Update(context.TODO(), statefulSet)
-					if err != nil && k8serror.IsNotFound(err) {
-						errs = append(errs, err)
-						continue
-					} else if err != nil {
-						return err
-					}
-					completedScaleWorkloads = append(completedScaleWorkloads, scale)
-				default:
-					errs = append(errs, fmt.Errorf("unsupport workload Kind [%s], name [%s]", scale.Kind, scale.Name))
+		}
+		if hasScaled {
+			continue
+		}
+		scaled, err := r.scaleWorkLoad(instance, builder, target)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to update %s [%s]: %v", target.Kind, target.Name, err))
+			continue
+		}
+		if scaled {
+			completedScaleWorkloads = append(completedScaleWorkloads, target)
+		}
+	}
+	if completedScaleAnnotation, err := json.Marshal(completedScaleWorkloads); err != nil {
+		return err
+	} else {
+		instance.Annotations[devopsv1alpha1.S2irCompletedScaleAnnotations] = string(completedScaleAnnotation)
+	}
+	if !reflect.DeepEqual(origin, instance) {
+		if err := r.Update(context.TODO(), instance); err != nil {
+			return err
+		}
+	}
+	if len(errs) != 0 {
+		return errors.NewAggregate(errs)
+	}
+	return nil
+}
+
+// scaleWorkLoad updates the image of the pod template of the target to the image built by the s2irun, and sets
+// the replicas of the workload of 0 replicas after the first successful s2irun. It returns false if the workload
+// is not found.
+func (r *ReconcileS2iRun) scaleWorkLoad(instance *devopsv1alpha1.S2iRun, builder *devopsv1alpha1.S2iBuilder,
+	target devopsv1alpha1.S2iDeployTarget) (bool, error) {
+	updater, err := workload.For(target.APIVersion, target.Kind, target.ContainersPath)
+	if err != nil {
+		return false, err
+	}
+	w, err := updater.Get(context.TODO(), r.Client, types.NamespacedName{Namespace: instance.Namespace, Name: target.Name})
+	if err != nil {
+		if k8serror.IsNotFound(err) {
+			log.Info("Workload not found", "kind", target.Kind, "ns", instance.Namespace, "name", target.Name)
+			return false, nil
+		}
+		return false, err
+	}
+	log.Info("Autoscale workload", "kind", target.Kind, "ns", instance.Namespace, "name", target.Name)
+
+	//Check if initialization is required
+	annotations := w.GetAnnotations()
+	if annotations[devopsv1alpha1.WorkLoadCompletedInitAnnotations] == "" {
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+		if instance.Status.RunState == devopsv1alpha1.Successful {
+			//If replicas == 0, set replicas to InitReplicas
+			if replicas, found := w.Replicas(); found && replicas == 0 {
+				initReplicas := int64(1)
+				if target.InitReplicas != nil {
+					initReplicas = int64(*target.InitReplicas)
 				}
-			}
-			if completedScaleAnnotation, err := json.Marshal(completedScaleWorkloads); err != nil {
-				return err
-			} else {
-				instance.Annotations[devopsv1alpha1.S2irCompletedScaleAnnotations] = string(completedScaleAnnotation)
-			}
-			if !reflect.DeepEqual(origin, instance) {
-				if err := r.Update(context.TODO(), instance); err != nil {
-					return err
+				if err := w.SetReplicas(initReplicas); err != nil {
+					return false, err
 				}
 			}
-			if len(errs) != 0 {
-				return errors.NewAggregate(errs)
-			}
+			annotations[devopsv1alpha1.WorkLoadCompletedInitAnnotations] = devopsv1alpha1.Successful
+		} else if instance.Status.RunState == devopsv1alpha1.Failed {
+			annotations[devopsv1alpha1.WorkLoadCompletedInitAnnotations] = devopsv1alpha1.Failed
 		}
-		return nil
+		w.SetAnnotations(annotations)
 	}
-	return nil
+
+	if err := w.SetImage(target.Containers, GetNewImageName(instance, *builder.Spec.Config)); err != nil {
+		return false, err
+	}
+	if err := w.SetTemplateLabel(devopsv1alpha1.S2iRunLabel, instance.Name); err != nil {
+		return false, err
+	}
+
+	log.Info("Update workload", "kind", target.Kind, "ns", instance.Namespace, "name", target.Name)
+	if err := updater.Update(context.TODO(), r.Client, w); err != nil {
+		return false, err
+	}
+	return true, nil
 }
@@ -141,4 +141,103 @@ var _ = Describe("Test reconcile", func() {
 		Eventually(func() error { return c.Get(context.TODO(), cmKey, cm) }, timeout).
 			ShouldNot(Succeed())
 	})
+	It("Should create a job for each platform", func() {
+		instance := &devopsv1alpha1.S2iRun{ObjectMeta: metav1.ObjectMeta{Name: "foo2", Namespace: "default"},
+			Spec: devopsv1alpha1.S2iRunSpec{
+				BuilderName: "foo2",
+			},
+		}
+		s2ibuilder := &devopsv1alpha1.S2iBuilder{
+			ObjectMeta: metav1.ObjectMeta{Name: "foo2", Namespace: "default"},
+			Spec: devopsv1alpha1.S2iBuilderSpec{
+				Config: &devopsv1alpha1.S2iConfig{
+					ImageName: "hello/world",
+					Tag:       "latest",
+					Platforms: []string{"linux/amd64", "linux/arm64/v8"},
+				},
+			},
+		}
+		err := c.Create(context.TODO(), s2ibuilder)
+		Expect(err).NotTo(HaveOccurred())
+		defer c.Delete(context.TODO(), s2ibuilder)
+
+		err = c.Create(context.TODO(), instance)
+		Expect(err).NotTo(HaveOccurred())
+		defer c.Delete(context.TODO(), instance)
+
+		createdInstance := &devopsv1alpha1.S2iRun{}
+		Eventually(func() error {
+			return c.Get(context.TODO(), types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace}, createdInstance)
+		}, timeout).Should(Succeed())
+		instanceUidSlice := strings.Split(string(createdInstance.UID), "-")
+
+		for suffix, arch := range map[string]string{"amd64": "amd64", "arm64v8": "arm64"} {
+			var jobKey = types.NamespacedName{Name: instance.Name + fmt.Sprintf("-%s-%s", instanceUidSlice[len(instanceUidSlice)-1], suffix) + "-job", Namespace: "default"}
+			var cmKey = types.NamespacedName{Name: instance.Name + fmt.Sprintf("-%s-%s", instanceUidSlice[len(instanceUidSlice)-1], suffix) + "-configmap", Namespace: "default"}
+			cm := &corev1.ConfigMap{}
+			Eventually(func() error { return c.Get(context.TODO(), cmKey, cm) }, timeout).
+				Should(Succeed())
+			Expect(cm.Data[ConfigDataKey]).To(ContainSubstring("hello/world:latest-" + suffix))
+			defer c.Delete(context.TODO(), cm)
+
+			job := &batchv1.Job{}
+			Eventually(func() error { return c.Get(context.TODO(), jobKey, job) }, timeout).
+				Should(Succeed())
+			Expect(job.Spec.Template.Spec.NodeSelector).To(HaveKeyWithValue(corev1.LabelArchStable, arch))
+			defer c.Delete(context.TODO(), job)
+		}
+	})
+	It("Should run pre-build hooks as init containers", func() {
+		instance := &devopsv1alpha1.S2iRun{ObjectMeta: metav1.ObjectMeta{Name: "foo3", Namespace: "default"},
+			Spec: devopsv1alpha1.S2iRunSpec{
+				BuilderName: "foo3",
+			},
+		}
+		s2ibuilder := &devopsv1alpha1.S2iBuilder{
+			ObjectMeta: metav1.ObjectMeta{Name: "foo3", Namespace: "default"},
+			Spec: devopsv1alpha1.S2iBuilderSpec{
+				Config: &devopsv1alpha1.S2iConfig{
+					ImageName:  "hello/world",
+					Tag:        "latest",
+					SourceURL:  "https://github.com/kubesphere/devops-java-sample",
+					RevisionId: "master",
+					PreBuildHooks: []devopsv1alpha1.S2iHook{
+						{Name: "unit-test", Image: "maven:3-jdk-8", Command: []string{"mvn", "test"}},
+					},
+				},
+			},
+		}
+		err := c.Create(context.TODO(), s2ibuilder)
+		Expect(err).NotTo(HaveOccurred())
+		defer c.Delete(context.TODO(), s2ibuilder)
+
+		err = c.Create(context.TODO(), instance)
+		Expect(err).NotTo(HaveOccurred())
+		defer c.Delete(context.TODO(), instance)
+
+		createdInstance := &devopsv1alpha1.S2iRun{}
+		Eventually(func() error {
+			return c.Get(context.TODO(), types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace}, createdInstance)
+		}, timeout).Should(Succeed())
+		instanceUidSlice := strings.Split(string(createdInstance.UID), "-")
+
+		var jobKey = types.NamespacedName{Name: instance.Name + fmt.Sprintf("-%s", instanceUidSlice[len(instanceUidSlice)-1]) + "-job", Namespace: "default"}
+		var cmKey = types.NamespacedName{Name: instance.Name + fmt.Sprintf("-%s", instanceUidSlice[len(instanceUidSlice)-1]) + "-configmap", Namespace: "default"}
+		cm := &corev1.ConfigMap{}
+		Eventually(func() error { return c.Get(context.TODO(), cmKey, cm) }, timeout).
+			Should(Succeed())
+		Expect(cm.Data).To(HaveKeyWithValue(ConfigSourceURLKey, "https://github.com/kubesphere/devops-java-sample"))
+		Expect(cm.Data[ConfigDataKey]).To(ContainSubstring("file://" + WorkspaceSourcePath))
+		defer c.Delete(context.TODO(), cm)
+
+		job := &batchv1.Job{}
+		Eventually(func() error { return c.Get(context.TODO(), jobKey, job) }, timeout).
+			Should(Succeed())
+		initContainers := job.Spec.Template.Spec.InitContainers
+		Expect(initContainers).To(HaveLen(2))
+		Expect(initContainers[0].Name).To(Equal(GitCloneContainerName))
+		Expect(initContainers[1].Name).To(Equal(PreBuildContainerPrefix + "unit-test"))
+		Expect(initContainers[1].WorkingDir).To(Equal(WorkspaceSourcePath))
+		defer c.Delete(context.TODO(), job)
+	})
 })
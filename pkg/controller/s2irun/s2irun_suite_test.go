@@ -3,13 +3,14 @@ package s2irun
 import (
 	"context"
 	stdlog "log"
-	"os"
 	"path/filepath"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/kubesphere/s2ioperator/pkg/apis"
+	"github.com/kubesphere/s2ioperator/pkg/config"
+	loghandler "github.com/kubesphere/s2ioperator/pkg/handler/log"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"k8s.io/client-go/kubernetes/scheme"
@@ -49,17 +50,18 @@ var _ = BeforeSuite(func() {
 	mgr, err = manager.New(cfg, manager.Options{})
 	Expect(err).NotTo(HaveOccurred())
 	c = mgr.GetClient()
-	os.Setenv("S2IIMAGENAME", "S2IIMAGENAME/S2IIMAGENAME")
 
 	// Setup the Manager and Controller.  Wrap the Controller Reconcile function so it writes each request to a
 	// channel when it is finished.
-	recFn, requests = SetupTestReconcile(newReconciler(mgr))
+	s2iConfig := config.NewDefaultConfig()
+	s2iConfig.S2IRunJobTemplate = filepath.Join("testdata", "job.yaml")
+	s2iConfig.S2IRunImage = "S2IIMAGENAME/S2IIMAGENAME"
+	recFn, requests = SetupTestReconcile(newReconciler(mgr, s2iConfig, loghandler.GetKubesphereLogger(), nil))
 	Expect(add(mgr, recFn)).NotTo(HaveOccurred())
 	stopMgr, mgrStopped = StartTestManager(mgr)
 })
 
 var _ = AfterSuite(func() {
-	os.Unsetenv("S2IIMAGENAME")
 	close(stopMgr)
 	mgrStopped.Wait()
 })
@@ -80,8 +82,8 @@ func SetupTestReconcile(inner reconcile.Reconciler) (reconcile.Reconciler, chan
 func StartTestManager(mgr manager.Manager) (chan struct{}, *sync.WaitGroup) {
 	stop := make(chan struct{})
 	wg := &sync.WaitGroup{}
+	wg.Add(1)
 	go func() {
-		wg.Add(1)
 		Expect(mgr.Start(context.Background())).NotTo(HaveOccurred())
 		wg.Done()
 	}()
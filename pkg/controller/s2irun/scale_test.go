@@ -0,0 +1,115 @@
+/*
+Copyright 2019 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s2irun
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestScaleWorkLoads(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := devopsv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	replicas := int32(0)
+	initReplicas := int32(2)
+	template := corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "hello", Image: "hello:v1"}}}}
+	builder := &devopsv1alpha1.S2iBuilder{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "default"},
+		Spec: devopsv1alpha1.S2iBuilderSpec{
+			Config: &devopsv1alpha1.S2iConfig{ImageName: "hello", Tag: "v2"},
+			DeployTargets: []devopsv1alpha1.S2iDeployTarget{
+				{Kind: devopsv1alpha1.KindDeployment, Name: "hello", InitReplicas: &initReplicas},
+				{Kind: "DaemonSet", Name: "hello"},
+				{Kind: devopsv1alpha1.KindStatefulSet, Name: "missing"},
+			},
+		},
+	}
+	run := &devopsv1alpha1.S2iRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello-1", Namespace: "default"},
+		Spec:       devopsv1alpha1.S2iRunSpec{BuilderName: "hello"},
+		Status:     devopsv1alpha1.S2iRunStatus{RunState: devopsv1alpha1.Successful},
+	}
+	r := &ReconcileS2iRun{scheme: scheme, Client: fake.NewFakeClientWithScheme(scheme, builder, run,
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "default"},
+			Spec:       appsv1.DeploymentSpec{Replicas: &replicas, Template: template},
+		},
+		&appsv1.DaemonSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "default"},
+			Spec:       appsv1.DaemonSetSpec{Template: template},
+		},
+	)}
+
+	// the image of the run is not pushed by the cells of the matrix
+	matrixRun := run.DeepCopy()
+	matrixRun.Spec.Matrix = &devopsv1alpha1.S2iBuildMatrix{
+		Parameters: []devopsv1alpha1.S2iMatrixParameter{{Key: "VERSION", Values: []string{"1", "2"}}},
+	}
+	if err := r.ScaleWorkLoads(matrixRun, builder); err != nil {
+		t.Fatal(err)
+	}
+	key := types.NamespacedName{Namespace: "default", Name: "hello"}
+	deploy := &appsv1.Deployment{}
+	if err := r.Get(context.TODO(), key, deploy); err != nil {
+		t.Fatal(err)
+	}
+	if deploy.Spec.Template.Spec.Containers[0].Image != "hello:v1" || matrixRun.Annotations[devopsv1alpha1.S2irCompletedScaleAnnotations] != "" {
+		t.Errorf("the workloads should not be scaled by the matrix run, got %+v", deploy.Spec.Template)
+	}
+
+	if err := r.ScaleWorkLoads(run, builder); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Get(context.TODO(), key, deploy); err != nil {
+		t.Fatal(err)
+	}
+	if deploy.Spec.Template.Spec.Containers[0].Image != "hello:v2" || *deploy.Spec.Replicas != 2 ||
+		deploy.Spec.Template.Labels[devopsv1alpha1.S2iRunLabel] != "hello-1" ||
+		deploy.Annotations[devopsv1alpha1.WorkLoadCompletedInitAnnotations] != devopsv1alpha1.Successful {
+		t.Errorf("the deployment should be updated and initialized, got %+v", deploy)
+	}
+	daemonset := &appsv1.DaemonSet{}
+	if err := r.Get(context.TODO(), key, daemonset); err != nil {
+		t.Fatal(err)
+	}
+	if daemonset.Spec.Template.Spec.Containers[0].Image != "hello:v2" || daemonset.Spec.Template.Labels[devopsv1alpha1.S2iRunLabel] != "hello-1" {
+		t.Errorf("the daemonset should be updated, got %+v", daemonset.Spec.Template)
+	}
+
+	completed := make([]devopsv1alpha1.S2iDeployTarget, 0)
+	if err := json.Unmarshal([]byte(run.Annotations[devopsv1alpha1.S2irCompletedScaleAnnotations]), &completed); err != nil {
+		t.Fatal(err)
+	}
+	if len(completed) != 2 {
+		t.Errorf("the missing workload should not be completed, got %+v", completed)
+	}
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2019 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s2irun
+
+import (
+	"context"
+	"encoding/json"
+
+	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// configSnapshot is the effective config of a run, which is frozen the first time the run is reconciled
+type configSnapshot struct {
+	// Config is the config of the builder after the template is expanded and the run overrides are applied
+	Config devopsv1alpha1.S2iConfig `json:"config"`
+	// ContainerInfo are the builder images of the template, they are used to find the runtime images of
+	// the builder images in the build matrix.
+	ContainerInfo []devopsv1alpha1.ContainerInfo `json:"containerInfo,omitempty"`
+}
+
+// newConfigSnapshot returns the effective config of the run, the credentials in the config are removed.
+func (r *ReconcileS2iRun) newConfigSnapshot(instance *devopsv1alpha1.S2iRun, builder *devopsv1alpha1.S2iBuilder) (*configSnapshot, error) {
+	snapshot := &configSnapshot{Config: *builder.Spec.Config.DeepCopy()}
+	config := &snapshot.Config
+	if template := builder.Spec.FromTemplate; template != nil {
+		t := &devopsv1alpha1.S2iBuilderTemplate{}
+		err := r.Get(context.TODO(), types.NamespacedName{Name: template.Name}, t)
+		if err != nil {
+			return nil, err
+		}
+		snapshot.ContainerInfo = t.Spec.ContainerInfo
+		if template.BuilderImage != "" {
+			config.BuilderImage = template.BuilderImage
+		} else {
+			config.BuilderImage = t.Spec.DefaultBaseImage
+		}
+		for _, p := range template.Parameters {
+			e := p.ToEnvironment()
+			if e != nil {
+				config.Environment = append(config.Environment, *e)
+			}
+		}
+	}
+	config.RevisionId = GetNewRevisionId(instance, *config)
+	config.SourceURL = GetNewSourceURL(instance, *config)
+
+	config.SecretCode = ""
+	for _, auth := range []*devopsv1alpha1.AuthConfig{config.PushAuthentication, config.PullAuthentication,
+		config.IncrementalAuthentication, config.RuntimeAuthentication} {
+		if auth != nil {
+			auth.Password = ""
+		}
+	}
+	return snapshot, nil
+}
+
+// setSnapshotCredentials sets the credentials which are written in the builder directly, they are not
+// kept in the snapshot.
+func setSnapshotCredentials(config *devopsv1alpha1.S2iConfig, builderConfig *devopsv1alpha1.S2iConfig) {
+	pairs := [][2]*devopsv1alpha1.AuthConfig{
+		{config.PushAuthentication, builderConfig.PushAuthentication},
+		{config.PullAuthentication, builderConfig.PullAuthentication},
+		{config.IncrementalAuthentication, builderConfig.IncrementalAuthentication},
+		{config.RuntimeAuthentication, builderConfig.RuntimeAuthentication},
+	}
+	for _, pair := range pairs {
+		auth, builderAuth := pair[0], pair[1]
+		if auth == nil || builderAuth == nil || auth.SecretRef != nil || auth.Username != builderAuth.Username {
+			continue
+		}
+		auth.Password = builderAuth.Password
+	}
+}
+
+// reconcileConfigSnapshot returns the snapshot of the effective config of the run, the snapshot is saved in
+// an immutable configmap the first time the run is reconciled, so that the changes of the builder do not
+// affect the run. A nil snapshot is returned if the request should be requeued.
+func (r *ReconcileS2iRun) reconcileConfigSnapshot(instance *devopsv1alpha1.S2iRun, builder *devopsv1alpha1.S2iBuilder) (*configSnapshot, error) {
+	name := getResourceName(instance, "", "snapshot")
+	snapshot := &configSnapshot{}
+	found := &corev1.ConfigMap{}
+	err := r.Get(context.TODO(), types.NamespacedName{Namespace: instance.Namespace, Name: name}, found)
+	if err != nil && k8serror.IsNotFound(err) {
+		snapshot, err = r.newConfigSnapshot(instance, builder)
+		if err != nil {
+			log.Error(err, "Failed to initialize the config snapshot")
+			return nil, err
+		}
+		data, err := json.Marshal(snapshot)
+		if err != nil {
+			return nil, err
+		}
+		immutable := true
+		configmap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: instance.Namespace,
+			},
+			Immutable: &immutable,
+			Data: map[string]string{
+				ConfigDataKey: string(data),
+			},
+		}
+		setConfigMapLabelAnnotations(instance, *builder.Spec.Config, builder.Spec.FromTemplate, configmap)
+		if err := controllerutil.SetControllerReference(instance, configmap, r.scheme); err != nil {
+			return nil, err
+		}
+		log.Info("Creating config snapshot", "Namespace", configmap.Namespace, "Name", configmap.Name)
+		if err = r.Create(context.TODO(), configmap); err != nil {
+			if k8serror.IsAlreadyExists(err) {
+				log.Info("Skip creating 'Already-Exists' config snapshot", "ConfigMap-Name", configmap.Name)
+				return nil, nil
+			}
+			log.Error(err, "Failed to create config snapshot", "Namespace", configmap.Namespace, "Name", configmap.Name)
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	} else if err = json.Unmarshal([]byte(found.Data[ConfigDataKey]), snapshot); err != nil {
+		log.Error(err, "Failed to read config snapshot", "Namespace", found.Namespace, "Name", found.Name)
+		return nil, err
+	}
+	instance.Status.ConfigSnapshotName = name
+	setSnapshotCredentials(&snapshot.Config, builder.Spec.Config)
+	return snapshot, nil
+}
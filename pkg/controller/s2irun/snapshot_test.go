@@ -0,0 +1,123 @@
+/*
+Copyright 2019 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s2irun
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
+	"github.com/kubesphere/s2ioperator/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestNewConfigSnapshot(t *testing.T) {
+	builder := &devopsv1alpha1.S2iBuilder{
+		Spec: devopsv1alpha1.S2iBuilderSpec{
+			Config: &devopsv1alpha1.S2iConfig{
+				SourceURL:  "https://github.com/kubesphere/devops-java-sample.git",
+				RevisionId: "master",
+				SecretCode: "secretCode",
+				PushAuthentication: &devopsv1alpha1.AuthConfig{
+					Username: "admin",
+					Password: "password",
+				},
+			},
+		},
+	}
+	instance := &devopsv1alpha1.S2iRun{
+		Spec: devopsv1alpha1.S2iRunSpec{NewRevisionId: "release"},
+	}
+
+	r := &ReconcileS2iRun{}
+	snapshot, err := r.newConfigSnapshot(instance, builder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Config.RevisionId != "release" {
+		t.Errorf("the revision of the run is not applied, got %s", snapshot.Config.RevisionId)
+	}
+	if snapshot.Config.SecretCode != "" || snapshot.Config.PushAuthentication.Password != "" {
+		t.Errorf("the credentials should not be kept in the snapshot")
+	}
+	if builder.Spec.Config.RevisionId != "master" || builder.Spec.Config.PushAuthentication.Password != "password" {
+		t.Errorf("the builder should not be modified")
+	}
+
+	setSnapshotCredentials(&snapshot.Config, builder.Spec.Config)
+	if snapshot.Config.PushAuthentication.Password != "password" {
+		t.Errorf("the credentials of the builder are not set")
+	}
+}
+
+func TestReconcileBuildJobConfigFrozen(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := devopsv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.NewDefaultConfig()
+	cfg.S2IRunJobTemplate = filepath.Join("testdata", "job.yaml")
+	cfg.S2IRunImage = "kubespheredev/s2irun:latest"
+	r := &ReconcileS2iRun{scheme: scheme, cfg: cfg, Client: fake.NewFakeClientWithScheme(scheme)}
+	run := &devopsv1alpha1.S2iRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello-1", Namespace: "default", UID: "1"},
+		Spec:       devopsv1alpha1.S2iRunSpec{BuilderName: "hello", PinResolvedSource: true},
+	}
+	builder := &devopsv1alpha1.S2iBuilder{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "default"},
+		Spec: devopsv1alpha1.S2iBuilderSpec{Config: &devopsv1alpha1.S2iConfig{
+			ImageName:  "hello/world",
+			Tag:        "latest",
+			SourceURL:  "https://github.com/hello/world.git",
+			RevisionId: "master",
+		}},
+	}
+	snapshot := &configSnapshot{Config: *builder.Spec.Config}
+
+	if _, _, err := r.reconcileBuildJob(run, builder, snapshot, buildVariant{}); err != nil {
+		t.Fatal(err)
+	}
+	key := types.NamespacedName{Namespace: "default", Name: getResourceName(run, "", "configmap")}
+	configmap := &corev1.ConfigMap{}
+	if err := r.Get(context.TODO(), key, configmap); err != nil {
+		t.Fatal(err)
+	}
+
+	// the source resolved by the first attempt and the changes of the builder are not written to the config
+	run.Status.S2iBuildSource = &devopsv1alpha1.S2iBuildSource{CommitID: "e9c2f7a3b1d4"}
+	snapshot.Config.PushAuthentication = &devopsv1alpha1.AuthConfig{Username: "user", Password: "password"}
+	if _, _, err := r.reconcileBuildJob(run, builder, snapshot, buildVariant{}); err != nil {
+		t.Fatal(err)
+	}
+	frozen := &corev1.ConfigMap{}
+	if err := r.Get(context.TODO(), key, frozen); err != nil {
+		t.Fatal(err)
+	}
+	if frozen.ResourceVersion != configmap.ResourceVersion || strings.Contains(frozen.Data[ConfigDataKey], "e9c2f7a3b1d4") {
+		t.Errorf("the config of the running build should not be changed, got %v", frozen.Data)
+	}
+}
@@ -0,0 +1,176 @@
+/*
+Copyright 2019 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s2irun
+
+import (
+	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var logPriority = map[devopsv1alpha1.RunState]int{
+	devopsv1alpha1.Failed:  2,
+	devopsv1alpha1.Running: 1,
+}
+
+// buildVariant is one of the images built by a run, such as the image of a platform or a matrix cell.
+// A run which builds a single image has only the empty variant.
+type buildVariant struct {
+	// Name is the suffix of the tag and the resources of the build
+	Name string
+	// Platform is the target platform of the build
+	Platform string
+	// BuilderImage and RuntimeImage override the images of the builder if set
+	BuilderImage string
+	RuntimeImage string
+	// Environment overrides the template parameters and environments of the builder
+	Environment []devopsv1alpha1.EnvironmentSpec
+}
+
+// buildVariantStatus is the observed state of the job of a build variant
+type buildVariantStatus struct {
+	RunState       devopsv1alpha1.RunState
+	JobName        string
+	LogURL         string
+	StartTime      *metav1.Time
+	CompletionTime *metav1.Time
+	BuildSource    *devopsv1alpha1.S2iBuildSource
+	BuildResult    *devopsv1alpha1.S2iBuildResult
+	HookResults    []devopsv1alpha1.S2iHookResult
+	Attempts       int32
+	AttemptResults []devopsv1alpha1.S2iRunAttempt
+	// Pod is the latest pod of the job
+	Pod *corev1.Pod
+}
+
+// reconcileBuildVariants makes sure the jobs of all variants exist and returns their status in the same
+// order, a nil slice is returned if the request should be requeued.
+func (r *ReconcileS2iRun) reconcileBuildVariants(instance *devopsv1alpha1.S2iRun, builder *devopsv1alpha1.S2iBuilder, snapshot *configSnapshot, variants []buildVariant) ([]buildVariantStatus, error) {
+	statuses := make([]buildVariantStatus, 0, len(variants))
+	for _, variant := range variants {
+		job, _, err := r.reconcileBuildJob(instance, builder, snapshot, variant)
+		if err != nil {
+			return nil, err
+		}
+		if job == nil {
+			return nil, nil
+		}
+		status := buildVariantStatus{
+			RunState:  getJobRunState(job),
+			JobName:   job.Name,
+			StartTime: job.Status.StartTime,
+		}
+		if status.RunState == devopsv1alpha1.Successful || status.RunState == devopsv1alpha1.Failed {
+			status.CompletionTime = job.Status.CompletionTime
+		}
+		// pods may not be found if the job is just created
+		pods, err := r.listJobPods(job)
+		if err != nil {
+			return nil, err
+		}
+		status.Attempts = getJobAttempts(job)
+		status.AttemptResults, err = r.getAttemptResults(instance, pods)
+		if err != nil {
+			return nil, err
+		}
+		if len(pods) != 0 {
+			pod := &pods[len(pods)-1]
+			status.Pod = pod
+			status.LogURL = status.AttemptResults[len(pods)-1].LogURL
+			status.BuildSource, status.BuildResult = getBuildInfoFromPod(pod)
+			status.HookResults = getHookResults(pod, job.Name)
+		}
+		if err = r.reconcileLogArchive(instance, job, status.Pod, devopsv1alpha1.S2iRunContainerName); err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// setRunStatus aggregates the status of all variants into the run. The run is finished after all the
+// jobs are finished, and it succeeds only if all of them succeed.
+func setRunStatus(instance *devopsv1alpha1.S2iRun, statuses []buildVariantStatus) {
+	var running, finished, failed int
+	var startTime, completionTime *metav1.Time
+	logIndex := -1
+	buildSource := &devopsv1alpha1.S2iBuildSource{}
+	var hookResults []devopsv1alpha1.S2iHookResult
+	for i, status := range statuses {
+		// the log of the first failed job is the most interesting one, then the running one
+		if logIndex == -1 || logPriority[status.RunState] > logPriority[statuses[logIndex].RunState] {
+			logIndex = i
+		}
+		switch status.RunState {
+		case devopsv1alpha1.Running:
+			running++
+		case devopsv1alpha1.Failed:
+			failed++
+			finished++
+		case devopsv1alpha1.Successful:
+			finished++
+		}
+		if status.StartTime != nil && (startTime == nil || status.StartTime.Before(startTime)) {
+			startTime = status.StartTime
+		}
+		if status.CompletionTime != nil && (completionTime == nil || completionTime.Before(status.CompletionTime)) {
+			completionTime = status.CompletionTime
+		}
+		if buildSource.CommitID == "" && status.BuildSource != nil {
+			buildSource = status.BuildSource.DeepCopy()
+		}
+		hookResults = append(hookResults, status.HookResults...)
+	}
+
+	switch {
+	case finished == len(statuses) && failed > 0:
+		instance.Status.RunState = devopsv1alpha1.Failed
+		instance.Status.CompletionTime = completionTime
+	case finished == len(statuses):
+		instance.Status.RunState = devopsv1alpha1.Successful
+		instance.Status.CompletionTime = completionTime
+	case running > 0:
+		instance.Status.RunState = devopsv1alpha1.Running
+	default:
+		instance.Status.RunState = devopsv1alpha1.Unknown
+	}
+	instance.Status.StartTime = startTime
+	if logIndex != -1 {
+		instance.Status.LogURL = statuses[logIndex].LogURL
+	}
+	instance.Status.S2iBuildSource = buildSource
+	if instance.Status.S2iBuildResult == nil {
+		instance.Status.S2iBuildResult = &devopsv1alpha1.S2iBuildResult{}
+	}
+	instance.Status.HookResults = hookResults
+}
+
+// isBuildResultReported returns true if the build job reported the result of the image it built
+func isBuildResultReported(result *devopsv1alpha1.S2iBuildResult) bool {
+	return result != nil && (result.ImageName != "" || result.ImageID != "" || result.ImageSize > 0)
+}
+
+// setEnvironment sets the environment in config, the one with the same name is overridden
+func setEnvironment(config *devopsv1alpha1.S2iConfig, env devopsv1alpha1.EnvironmentSpec) {
+	for i := range config.Environment {
+		if config.Environment[i].Name == env.Name {
+			config.Environment[i].Value = env.Value
+			return
+		}
+	}
+	config.Environment = append(config.Environment, env)
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/emicklei/go-restful"
+	"github.com/emicklei/go-restful-openapi"
+	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
+)
+
+// RootPath is the path of the web service, other routes of s2i resources should be added to its web service
+const RootPath = "/s2i/v1alpha1"
+
+var tags = []string{"s2i_api"}
+
+func (h *Handler) WebService() *restful.WebService {
+	ws := new(restful.WebService)
+	ws.Path(RootPath).Produces(restful.MIME_JSON)
+
+	listParams := func(rb *restful.RouteBuilder) {
+		rb.Param(ws.QueryParameter("name", "filter by the name containing the value")).
+			Param(ws.QueryParameter("labelSelector", "filter by the label selector")).
+			Param(ws.QueryParameter("page", "the page of the items, starts from 1").DataType("integer").DefaultValue("1")).
+			Param(ws.QueryParameter("limit", "the number of items in a page, no more than 100").DataType("integer").DefaultValue("10"))
+	}
+	stateParams := func(rb *restful.RouteBuilder) {
+		rb.Param(ws.QueryParameter("state", "filter by the comma separated run states, e.g. Running,Failed")).
+			Param(ws.QueryParameter("since", "filter by the creation time no earlier than the time in RFC3339")).
+			Param(ws.QueryParameter("until", "filter by the creation time earlier than the time in RFC3339"))
+	}
+
+	ws.Route(ws.GET("/namespaces/{namespace}/s2ibuilders").
+		To(h.ListBuilders).
+		Filter(h.Authorizer.Filter(devopsv1alpha1.ResourcePluralS2iBuilder, "")).
+		Doc("list the s2ibuilders, the state filters by the state of the last run").
+		Param(ws.PathParameter("namespace", "namespace")).
+		Do(listParams, stateParams).
+		Returns(http.StatusOK, "the s2ibuilders", BuilderList{}).
+		Metadata(restfulspec.KeyOpenAPITags, tags))
+
+	ws.Route(ws.GET("/namespaces/{namespace}/s2ibuilders/{s2ibuilder}").
+		To(h.GetBuilder).
+		Filter(h.Authorizer.Filter(devopsv1alpha1.ResourcePluralS2iBuilder, "s2ibuilder")).
+		Doc("get the s2ibuilder").
+		Param(ws.PathParameter("namespace", "namespace")).
+		Param(ws.PathParameter("s2ibuilder", "the name of s2ibuilder")).
+		Returns(http.StatusOK, "the s2ibuilder", Builder{}).
+		Metadata(restfulspec.KeyOpenAPITags, tags))
+
+	ws.Route(ws.GET("/namespaces/{namespace}/s2iruns").
+		To(h.ListRuns).
+		Filter(h.Authorizer.Filter(devopsv1alpha1.ResourcePluralS2iRun, "")).
+		Doc("list the s2iruns, the latest s2irun comes first").
+		Param(ws.PathParameter("namespace", "namespace")).
+		Param(ws.QueryParameter("builder", "filter by the name of s2ibuilder")).
+		Do(listParams, stateParams).
+		Returns(http.StatusOK, "the s2iruns", RunList{}).
+		Metadata(restfulspec.KeyOpenAPITags, tags))
+
+	ws.Route(ws.GET("/namespaces/{namespace}/s2iruns/{s2irun}").
+		To(h.GetRun).
+		Filter(h.Authorizer.Filter(devopsv1alpha1.ResourcePluralS2iRun, "s2irun")).
+		Doc("get the s2irun").
+		Param(ws.PathParameter("namespace", "namespace")).
+		Param(ws.PathParameter("s2irun", "the name of s2irun")).
+		Returns(http.StatusOK, "the s2irun", Run{}).
+		Metadata(restfulspec.KeyOpenAPITags, tags))
+
+	ws.Route(ws.GET("/s2ibuildertemplates").
+		To(h.ListTemplates).
+		Filter(h.Authorizer.Filter(devopsv1alpha1.ResourcePluralS2iBuilderTemplate, "")).
+		Doc("list the s2ibuildertemplates").
+		Do(listParams).
+		Returns(http.StatusOK, "the s2ibuildertemplates", TemplateList{}).
+		Metadata(restfulspec.KeyOpenAPITags, tags))
+
+	ws.Route(ws.GET("/s2ibuildertemplates/{s2ibuildertemplate}").
+		To(h.GetTemplate).
+		Filter(h.Authorizer.Filter(devopsv1alpha1.ResourcePluralS2iBuilderTemplate, "s2ibuildertemplate")).
+		Doc("get the s2ibuildertemplate").
+		Param(ws.PathParameter("s2ibuildertemplate", "the name of s2ibuildertemplate")).
+		Returns(http.StatusOK, "the s2ibuildertemplate", Template{}).
+		Metadata(restfulspec.KeyOpenAPITags, tags))
+
+	return ws
+}
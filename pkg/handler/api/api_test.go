@@ -0,0 +1,168 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/emicklei/go-restful"
+	"github.com/emicklei/go-restful-openapi"
+	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	k8stesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const namespace = "s2i"
+
+var _ = Describe("Testing s2i api", func() {
+	var container *restful.Container
+	now := time.Now()
+
+	newRunObject := func(name, builder string, state devopsv1alpha1.RunState, age time.Duration) *devopsv1alpha1.S2iRun {
+		start := metav1.NewTime(now.Add(-age))
+		return &devopsv1alpha1.S2iRun{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, CreationTimestamp: start},
+			Spec:       devopsv1alpha1.S2iRunSpec{BuilderName: builder},
+			Status:     devopsv1alpha1.S2iRunStatus{RunState: state, StartTime: &start},
+		}
+	}
+
+	BeforeEach(func() {
+		s := runtime.NewScheme()
+		Expect(scheme.AddToScheme(s)).To(Succeed())
+		Expect(devopsv1alpha1.AddToScheme(s)).To(Succeed())
+		c := fake.NewFakeClientWithScheme(s,
+			&devopsv1alpha1.S2iBuilder{
+				ObjectMeta: metav1.ObjectMeta{Name: "java-builder", Namespace: namespace},
+				Spec: devopsv1alpha1.S2iBuilderSpec{Config: &devopsv1alpha1.S2iConfig{
+					SourceURL: "https://github.com/kubesphere/devops-java-sample",
+					ImageName: "kubesphere/java-sample",
+				}},
+				Status: devopsv1alpha1.S2iBuilderStatus{RunCount: 3, LastRunState: devopsv1alpha1.Failed},
+			},
+			newRunObject("java-run-1", "java-builder", devopsv1alpha1.Successful, 3*time.Hour),
+			newRunObject("java-run-2", "java-builder", devopsv1alpha1.Failed, 2*time.Hour),
+			newRunObject("java-run-3", "java-builder", devopsv1alpha1.Running, time.Hour),
+			newRunObject("nodejs-run-1", "nodejs-builder", devopsv1alpha1.Successful, time.Minute),
+			&devopsv1alpha1.S2iBuilderTemplate{
+				ObjectMeta: metav1.ObjectMeta{Name: "java"},
+				Spec: devopsv1alpha1.S2iBuilderTemplateSpec{
+					DefaultBaseImage: "kubesphere/java-8-centos7",
+					ContainerInfo:    []devopsv1alpha1.ContainerInfo{{BuilderImage: "kubesphere/java-8-centos7"}},
+				},
+			},
+		)
+
+		kubeClient := kubefake.NewSimpleClientset()
+		kubeClient.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+			review.Status.Authenticated = true
+			review.Status.User = authenticationv1.UserInfo{Username: review.Spec.Token}
+			return true, review, nil
+		})
+		kubeClient.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+			// the guest can only get the resources
+			review.Status.Allowed = review.Spec.User == "developer" || review.Spec.ResourceAttributes.Verb == "get"
+			return true, review, nil
+		})
+
+		container = restful.NewContainer()
+		container.Add(NewHandler(c, kubeClient).WebService())
+		container.Add(restfulspec.NewOpenAPIService(restfulspec.Config{
+			WebServices: container.RegisteredWebServices(),
+			APIPath:     "/apidocs.json",
+		}))
+	})
+
+	get := func(url, token string, result interface{}) int {
+		request := httptest.NewRequest(http.MethodGet, url, nil)
+		if token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+		recorder := httptest.NewRecorder()
+		container.ServeHTTP(recorder, request)
+		if result != nil && recorder.Code == http.StatusOK {
+			Expect(json.Unmarshal(recorder.Body.Bytes(), result)).To(Succeed())
+		}
+		return recorder.Code
+	}
+
+	It("Should authorize the requests", func() {
+		Expect(get(RootPath+"/namespaces/s2i/s2iruns", "", nil)).To(Equal(http.StatusUnauthorized))
+		Expect(get(RootPath+"/namespaces/s2i/s2iruns", "guest", nil)).To(Equal(http.StatusForbidden))
+		Expect(get(RootPath+"/namespaces/s2i/s2iruns/java-run-1", "guest", nil)).To(Equal(http.StatusOK))
+	})
+
+	It("Should list the s2iruns with filters and paging", func() {
+		runs := &RunList{}
+		Expect(get(RootPath+"/namespaces/s2i/s2iruns?builder=java-builder&limit=2", "developer", runs)).To(Equal(http.StatusOK))
+		Expect(runs.TotalCount).To(Equal(3))
+		Expect(runs.Items).To(HaveLen(2))
+		Expect(runs.Items[0].Name).To(Equal("java-run-3"))
+		Expect(runs.Items[0].DurationSeconds).To(BeNumerically(">=", 3600))
+		Expect(runs.Items[1].Name).To(Equal("java-run-2"))
+
+		Expect(get(RootPath+"/namespaces/s2i/s2iruns?builder=java-builder&limit=2&page=2", "developer", runs)).To(Equal(http.StatusOK))
+		Expect(runs.Items).To(HaveLen(1))
+		Expect(runs.Items[0].Name).To(Equal("java-run-1"))
+
+		Expect(get(RootPath+"/namespaces/s2i/s2iruns?state=Successful,Failed", "developer", runs)).To(Equal(http.StatusOK))
+		Expect(runs.TotalCount).To(Equal(3))
+
+		since := now.Add(-90 * time.Minute).UTC().Format(time.RFC3339)
+		until := now.Add(-10 * time.Minute).UTC().Format(time.RFC3339)
+		Expect(get(RootPath+"/namespaces/s2i/s2iruns?since="+since+"&until="+until, "developer", runs)).To(Equal(http.StatusOK))
+		Expect(runs.TotalCount).To(Equal(1))
+		Expect(runs.Items[0].Name).To(Equal("java-run-3"))
+
+		Expect(get(RootPath+"/namespaces/s2i/s2iruns?since=yesterday", "developer", nil)).To(Equal(http.StatusBadRequest))
+		Expect(get(RootPath+"/namespaces/s2i/s2iruns?page=0", "developer", nil)).To(Equal(http.StatusBadRequest))
+	})
+
+	It("Should get the s2irun", func() {
+		run := &Run{}
+		Expect(get(RootPath+"/namespaces/s2i/s2iruns/java-run-2", "developer", run)).To(Equal(http.StatusOK))
+		Expect(run.Builder).To(Equal("java-builder"))
+		Expect(run.RunState).To(Equal(devopsv1alpha1.RunState(devopsv1alpha1.Failed)))
+		Expect(get(RootPath+"/namespaces/s2i/s2iruns/not-exist", "developer", nil)).To(Equal(http.StatusNotFound))
+	})
+
+	It("Should list and get the s2ibuilders", func() {
+		builders := &BuilderList{}
+		Expect(get(RootPath+"/namespaces/s2i/s2ibuilders?state=Failed", "developer", builders)).To(Equal(http.StatusOK))
+		Expect(builders.TotalCount).To(Equal(1))
+		Expect(builders.Items[0].SourceURL).To(Equal("https://github.com/kubesphere/devops-java-sample"))
+		Expect(get(RootPath+"/namespaces/s2i/s2ibuilders?state=Successful", "developer", builders)).To(Equal(http.StatusOK))
+		Expect(builders.Items).To(BeEmpty())
+
+		builder := &Builder{}
+		Expect(get(RootPath+"/namespaces/s2i/s2ibuilders/java-builder", "developer", builder)).To(Equal(http.StatusOK))
+		Expect(builder.RunCount).To(Equal(3))
+	})
+
+	It("Should list and get the s2ibuildertemplates", func() {
+		templates := &TemplateList{}
+		Expect(get(RootPath+"/s2ibuildertemplates", "developer", templates)).To(Equal(http.StatusOK))
+		Expect(templates.TotalCount).To(Equal(1))
+
+		template := &Template{}
+		Expect(get(RootPath+"/s2ibuildertemplates/java", "developer", template)).To(Equal(http.StatusOK))
+		Expect(template.BuilderImages).To(ConsistOf("kubesphere/java-8-centos7"))
+	})
+
+	It("Should serve the openapi document", func() {
+		doc := map[string]interface{}{}
+		Expect(get("/apidocs.json", "", &doc)).To(Equal(http.StatusOK))
+		Expect(doc["paths"]).To(HaveKey(RootPath + "/namespaces/{namespace}/s2iruns"))
+	})
+})
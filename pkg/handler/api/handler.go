@@ -0,0 +1,326 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emicklei/go-restful"
+	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
+	"github.com/kubesphere/s2ioperator/pkg/handler/auth"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	log "k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	DefaultLimit = 10
+	MaxLimit     = 100
+)
+
+// Handler serves the read-only API of s2ibuilders, s2iruns and s2ibuildertemplates, the users are authorized
+// by the filters of the routes with their permissions in the cluster.
+type Handler struct {
+	KubeClientSet client.Client
+	Authorizer    *auth.Authorizer
+}
+
+func NewHandler(client client.Client, kubeClient kubernetes.Interface) *Handler {
+	return &Handler{
+		KubeClientSet: client,
+		Authorizer:    auth.NewAuthorizer(kubeClient),
+	}
+}
+
+// query is the filter and paging of the list requests
+type query struct {
+	name     string
+	builder  string
+	states   map[string]bool
+	since    *time.Time
+	until    *time.Time
+	selector labels.Selector
+	page     int
+	limit    int
+}
+
+func parseQuery(request *restful.Request) (*query, error) {
+	q := &query{
+		name:     request.QueryParameter("name"),
+		builder:  request.QueryParameter("builder"),
+		selector: labels.Everything(),
+		page:     1,
+		limit:    DefaultLimit,
+	}
+	if state := request.QueryParameter("state"); state != "" {
+		q.states = make(map[string]bool)
+		for _, s := range strings.Split(state, ",") {
+			q.states[strings.TrimSpace(s)] = true
+		}
+	}
+	for param, t := range map[string]**time.Time{"since": &q.since, "until": &q.until} {
+		if value := request.QueryParameter(param); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %s, it should be in RFC3339", param, value)
+			}
+			*t = &parsed
+		}
+	}
+	if selector := request.QueryParameter("labelSelector"); selector != "" {
+		parsed, err := labels.Parse(selector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid labelSelector %s: %v", selector, err)
+		}
+		q.selector = parsed
+	}
+	for param, value := range map[string]*int{"page": &q.page, "limit": &q.limit} {
+		if s := request.QueryParameter(param); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid %s %s, it should be a positive integer", param, s)
+			}
+			*value = n
+		}
+	}
+	if q.limit > MaxLimit {
+		q.limit = MaxLimit
+	}
+	return q, nil
+}
+
+func (q *query) matchState(state devopsv1alpha1.RunState) bool {
+	return q.states == nil || q.states[string(state)]
+}
+
+func (q *query) matchTime(t metav1.Time) bool {
+	return (q.since == nil || !t.Time.Before(*q.since)) && (q.until == nil || t.Time.Before(*q.until))
+}
+
+// paging returns the range of the items in the page
+func (q *query) paging(total int) (int, int) {
+	start := (q.page - 1) * q.limit
+	if start > total {
+		start = total
+	}
+	end := start + q.limit
+	if end > total {
+		end = total
+	}
+	return start, end
+}
+
+func writeGetError(response *restful.Response, err error) {
+	if errors.IsNotFound(err) {
+		response.WriteHeader(http.StatusNotFound)
+	} else {
+		log.Error(err, "Failed to get resource.")
+		response.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) ListBuilders(request *restful.Request, response *restful.Response) {
+	q, err := parseQuery(request)
+	if err != nil {
+		response.WriteErrorString(http.StatusBadRequest, err.Error())
+		return
+	}
+	builders := &devopsv1alpha1.S2iBuilderList{}
+	err = h.KubeClientSet.List(context.TODO(), builders, client.InNamespace(request.PathParameter("namespace")),
+		client.MatchingLabelsSelector{Selector: q.selector})
+	if err != nil {
+		log.Error(err, "Can not list S2IBuilders.")
+		response.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	items := make([]Builder, 0)
+	for i := range builders.Items {
+		builder := &builders.Items[i]
+		if !strings.Contains(builder.Name, q.name) || !q.matchState(builder.Status.LastRunState) ||
+			!q.matchTime(builder.CreationTimestamp) {
+			continue
+		}
+		items = append(items, newBuilder(builder))
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].CreationTime.Equal(&items[j].CreationTime) {
+			return items[i].Name < items[j].Name
+		}
+		return items[j].CreationTime.Before(&items[i].CreationTime)
+	})
+	start, end := q.paging(len(items))
+	response.WriteAsJson(BuilderList{Items: items[start:end], TotalCount: len(items)})
+}
+
+func (h *Handler) GetBuilder(request *restful.Request, response *restful.Response) {
+	builder := &devopsv1alpha1.S2iBuilder{}
+	key := types.NamespacedName{Namespace: request.PathParameter("namespace"), Name: request.PathParameter("s2ibuilder")}
+	if err := h.KubeClientSet.Get(context.TODO(), key, builder); err != nil {
+		writeGetError(response, err)
+		return
+	}
+	response.WriteAsJson(newBuilder(builder))
+}
+
+func (h *Handler) ListRuns(request *restful.Request, response *restful.Response) {
+	q, err := parseQuery(request)
+	if err != nil {
+		response.WriteErrorString(http.StatusBadRequest, err.Error())
+		return
+	}
+	runs := &devopsv1alpha1.S2iRunList{}
+	err = h.KubeClientSet.List(context.TODO(), runs, client.InNamespace(request.PathParameter("namespace")),
+		client.MatchingLabelsSelector{Selector: q.selector})
+	if err != nil {
+		log.Error(err, "Can not list S2IRuns.")
+		response.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	items := make([]Run, 0)
+	for i := range runs.Items {
+		run := &runs.Items[i]
+		if (q.builder != "" && run.Spec.BuilderName != q.builder) || !strings.Contains(run.Name, q.name) ||
+			!q.matchState(run.Status.RunState) || !q.matchTime(run.CreationTimestamp) {
+			continue
+		}
+		items = append(items, newRun(run, now))
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].CreationTime.Equal(&items[j].CreationTime) {
+			return items[i].Name < items[j].Name
+		}
+		return items[j].CreationTime.Before(&items[i].CreationTime)
+	})
+	start, end := q.paging(len(items))
+	response.WriteAsJson(RunList{Items: items[start:end], TotalCount: len(items)})
+}
+
+func (h *Handler) GetRun(request *restful.Request, response *restful.Response) {
+	run := &devopsv1alpha1.S2iRun{}
+	key := types.NamespacedName{Namespace: request.PathParameter("namespace"), Name: request.PathParameter("s2irun")}
+	if err := h.KubeClientSet.Get(context.TODO(), key, run); err != nil {
+		writeGetError(response, err)
+		return
+	}
+	response.WriteAsJson(newRun(run, time.Now()))
+}
+
+func (h *Handler) ListTemplates(request *restful.Request, response *restful.Response) {
+	q, err := parseQuery(request)
+	if err != nil {
+		response.WriteErrorString(http.StatusBadRequest, err.Error())
+		return
+	}
+	templates := &devopsv1alpha1.S2iBuilderTemplateList{}
+	err = h.KubeClientSet.List(context.TODO(), templates, client.MatchingLabelsSelector{Selector: q.selector})
+	if err != nil {
+		log.Error(err, "Can not list S2IBuilderTemplates.")
+		response.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	items := make([]Template, 0)
+	for i := range templates.Items {
+		template := &templates.Items[i]
+		if !strings.Contains(template.Name, q.name) {
+			continue
+		}
+		items = append(items, newTemplate(template))
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Name < items[j].Name
+	})
+	start, end := q.paging(len(items))
+	response.WriteAsJson(TemplateList{Items: items[start:end], TotalCount: len(items)})
+}
+
+func (h *Handler) GetTemplate(request *restful.Request, response *restful.Response) {
+	template := &devopsv1alpha1.S2iBuilderTemplate{}
+	if err := h.KubeClientSet.Get(context.TODO(), types.NamespacedName{Name: request.PathParameter("s2ibuildertemplate")}, template); err != nil {
+		writeGetError(response, err)
+		return
+	}
+	response.WriteAsJson(newTemplate(template))
+}
+
+func newBuilder(builder *devopsv1alpha1.S2iBuilder) Builder {
+	b := Builder{
+		Name:             builder.Name,
+		Namespace:        builder.Namespace,
+		CreationTime:     builder.CreationTimestamp,
+		RunCount:         builder.Status.RunCount,
+		LastRunState:     builder.Status.LastRunState,
+		LastRunStartTime: builder.Status.LastRunStartTime,
+	}
+	if builder.Status.LastRunName != nil {
+		b.LastRunName = *builder.Status.LastRunName
+	}
+	if builder.Spec.FromTemplate != nil {
+		b.Template = builder.Spec.FromTemplate.Name
+		b.BuilderImage = builder.Spec.FromTemplate.BuilderImage
+	}
+	if config := builder.Spec.Config; config != nil {
+		if config.BuilderImage != "" {
+			b.BuilderImage = config.BuilderImage
+		}
+		b.SourceURL = config.SourceURL
+		b.RevisionId = config.RevisionId
+		b.ImageName = config.ImageName
+		b.Tag = config.Tag
+	}
+	return b
+}
+
+func newRun(run *devopsv1alpha1.S2iRun, now time.Time) Run {
+	r := Run{
+		Name:           run.Name,
+		Namespace:      run.Namespace,
+		Builder:        run.Spec.BuilderName,
+		CreationTime:   run.CreationTimestamp,
+		RunState:       run.Status.RunState,
+		StartTime:      run.Status.StartTime,
+		CompletionTime: run.Status.CompletionTime,
+		Attempts:       run.Status.Attempts,
+		RerunOf:        run.Status.RerunOf,
+		LogURL:         run.Status.LogURL,
+		Source:         run.Status.S2iBuildSource,
+		Result:         run.Status.S2iBuildResult,
+		FailureReason:  run.Status.FailureReason,
+		FailureStage:   run.Status.FailureStage,
+		FailureMessage: run.Status.FailureMessage,
+	}
+	if run.Status.StartTime != nil {
+		if run.Status.CompletionTime != nil {
+			r.DurationSeconds = int64(run.Status.CompletionTime.Sub(run.Status.StartTime.Time).Seconds())
+		} else if run.Status.RunState == devopsv1alpha1.Running {
+			r.DurationSeconds = int64(now.Sub(run.Status.StartTime.Time).Seconds())
+		}
+	}
+	return r
+}
+
+func newTemplate(template *devopsv1alpha1.S2iBuilderTemplate) Template {
+	t := Template{
+		Name:             template.Name,
+		Description:      template.Spec.Description,
+		Version:          template.Spec.Version,
+		CodeFramework:    template.Spec.CodeFramework,
+		IconPath:         template.Spec.IconPath,
+		DefaultBaseImage: template.Spec.DefaultBaseImage,
+	}
+	for _, info := range template.Spec.ContainerInfo {
+		t.BuilderImages = append(t.BuilderImages, info.BuilderImage)
+	}
+	return t
+}
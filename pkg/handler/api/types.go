@@ -0,0 +1,70 @@
+package api
+
+import (
+	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Builder is the summary of a s2ibuilder shown in dashboards
+type Builder struct {
+	Name             string                  `json:"name"`
+	Namespace        string                  `json:"namespace"`
+	CreationTime     metav1.Time             `json:"creationTime"`
+	Template         string                  `json:"template,omitempty"`
+	BuilderImage     string                  `json:"builderImage,omitempty"`
+	SourceURL        string                  `json:"sourceUrl,omitempty"`
+	RevisionId       string                  `json:"revisionId,omitempty"`
+	ImageName        string                  `json:"imageName,omitempty"`
+	Tag              string                  `json:"tag,omitempty"`
+	RunCount         int                     `json:"runCount"`
+	LastRunState     devopsv1alpha1.RunState `json:"lastRunState,omitempty"`
+	LastRunName      string                  `json:"lastRunName,omitempty"`
+	LastRunStartTime *metav1.Time            `json:"lastRunStartTime,omitempty"`
+}
+
+// Run is the summary of a s2irun shown in dashboards
+type Run struct {
+	Name           string                  `json:"name"`
+	Namespace      string                  `json:"namespace"`
+	Builder        string                  `json:"builder"`
+	CreationTime   metav1.Time             `json:"creationTime"`
+	RunState       devopsv1alpha1.RunState `json:"runState,omitempty"`
+	StartTime      *metav1.Time            `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time            `json:"completionTime,omitempty"`
+	// DurationSeconds is the duration of the build, it is the time since the start if the build is running
+	DurationSeconds int64                          `json:"durationSeconds,omitempty"`
+	Attempts        int32                          `json:"attempts,omitempty"`
+	RerunOf         string                         `json:"rerunOf,omitempty"`
+	LogURL          string                         `json:"logURL,omitempty"`
+	Source          *devopsv1alpha1.S2iBuildSource `json:"source,omitempty"`
+	Result          *devopsv1alpha1.S2iBuildResult `json:"result,omitempty"`
+	FailureReason   string                         `json:"failureReason,omitempty"`
+	FailureStage    devopsv1alpha1.BuildStage      `json:"failureStage,omitempty"`
+	FailureMessage  string                         `json:"failureMessage,omitempty"`
+}
+
+// Template is the summary of a s2ibuildertemplate shown in dashboards
+type Template struct {
+	Name             string                       `json:"name"`
+	Description      string                       `json:"description,omitempty"`
+	Version          string                       `json:"version,omitempty"`
+	CodeFramework    devopsv1alpha1.CodeFramework `json:"codeFramework,omitempty"`
+	IconPath         string                       `json:"iconPath,omitempty"`
+	DefaultBaseImage string                       `json:"defaultBaseImage,omitempty"`
+	BuilderImages    []string                     `json:"builderImages,omitempty"`
+}
+
+type BuilderList struct {
+	Items      []Builder `json:"items"`
+	TotalCount int       `json:"totalCount"`
+}
+
+type RunList struct {
+	Items      []Run `json:"items"`
+	TotalCount int   `json:"totalCount"`
+}
+
+type TemplateList struct {
+	Items      []Template `json:"items"`
+	TotalCount int        `json:"totalCount"`
+}
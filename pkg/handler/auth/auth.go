@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/emicklei/go-restful"
+	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	log "k8s.io/klog"
+)
+
+// UserAttribute is the attribute of the request which keeps the authenticated user
+const UserAttribute = "s2i.user"
+
+// Authorizer authenticates the bearer token of the request by TokenReview, and authorizes the user by
+// SubjectAccessReview, so that the users have the same permissions as they have in the cluster.
+
+// +kubebuilder:rbac:groups=authentication.k8s.io,resources=tokenreviews,verbs=create
+// +kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+
+type Authorizer struct {
+	KubeClient kubernetes.Interface
+}
+
+func NewAuthorizer(kubeClient kubernetes.Interface) *Authorizer {
+	return &Authorizer{
+		KubeClient: kubeClient,
+	}
+}
+
+// Authenticate returns the user of the bearer token, nil is returned if the token is invalid.
+func (a *Authorizer) Authenticate(ctx context.Context, authorization string) (*authenticationv1.UserInfo, error) {
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		return nil, nil
+	}
+	token := strings.TrimSpace(strings.TrimPrefix(authorization, "Bearer "))
+	if token == "" {
+		return nil, nil
+	}
+	review, err := a.KubeClient.AuthenticationV1().TokenReviews().Create(ctx, &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if !review.Status.Authenticated {
+		return nil, nil
+	}
+	return &review.Status.User, nil
+}
+
+// Authorize returns true if the user can do the action on the resource
+func (a *Authorizer) Authorize(ctx context.Context, user *authenticationv1.UserInfo, attributes authorizationv1.ResourceAttributes) (bool, error) {
+	extra := make(map[string]authorizationv1.ExtraValue, len(user.Extra))
+	for k, v := range user.Extra {
+		extra[k] = authorizationv1.ExtraValue(v)
+	}
+	review, err := a.KubeClient.AuthorizationV1().SubjectAccessReviews().Create(ctx, &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			ResourceAttributes: &attributes,
+			User:               user.Username,
+			Groups:             user.Groups,
+			UID:                user.UID,
+			Extra:              extra,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return false, err
+	}
+	return review.Status.Allowed, nil
+}
+
+// Filter returns the route filter which requires the user to get the resource named by the path parameter
+// nameParameter, or to list the resource in the namespace of the path if nameParameter is empty. The
+// authenticated user is kept in the attribute UserAttribute of the request.
+func (a *Authorizer) Filter(resource, nameParameter string) restful.FilterFunction {
+	return func(request *restful.Request, response *restful.Response, chain *restful.FilterChain) {
+		ctx := request.Request.Context()
+		user, err := a.Authenticate(ctx, request.HeaderParameter("Authorization"))
+		if err != nil {
+			log.Error(err, "Failed to authenticate")
+			response.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if user == nil {
+			response.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		attributes := authorizationv1.ResourceAttributes{
+			Namespace: request.PathParameter("namespace"),
+			Verb:      "list",
+			Group:     devopsv1alpha1.SchemeGroupVersion.Group,
+			Resource:  resource,
+		}
+		if nameParameter != "" {
+			attributes.Verb = "get"
+			attributes.Name = request.PathParameter(nameParameter)
+		}
+		allowed, err := a.Authorize(ctx, user, attributes)
+		if err != nil {
+			log.Error(err, "Failed to authorize")
+			response.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if !allowed {
+			response.WriteHeader(http.StatusForbidden)
+			return
+		}
+		request.SetAttribute(UserAttribute, user)
+		chain.ProcessFilter(request, response)
+	}
+}
@@ -1,8 +1,11 @@
 package general
 
 import (
+	"net/http"
+
 	"github.com/emicklei/go-restful"
 	"github.com/emicklei/go-restful-openapi"
+	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
 )
 
 var tags = []string{"s2i_general_trigger"}
@@ -34,5 +37,22 @@ func (t Trigger) WebService() *restful.WebService {
 			DataFormat("secretCode=%s")).
 		Metadata(restfulspec.KeyOpenAPITags, tags))
 
+	// rerun a s2irun with the same inputs
+	ws.Route(ws.POST("/namespaces/{namespace}/s2ibuilders/{s2ibuilder}/s2iruns/{s2irun}/rerun").
+		To(t.Rerun).
+		Doc("rerun the s2irun as a new s2irun").
+		Produces(restful.MIME_JSON).
+		Param(ws.PathParameter("namespace", "namespace")).
+		Param(ws.PathParameter("s2ibuilder", "the name of the s2ibuilder of the s2irun")).
+		Param(ws.PathParameter("s2irun", "the name of s2irun")).
+		Param(ws.QueryParameter("secretCode", "use secret code of the s2ibuilder to authorizing").
+			Required(true).
+			DataFormat("secretCode=%s")).
+		Param(ws.QueryParameter("pinRevision", "build the commit which is built by the s2irun").
+			DataType("boolean").
+			DataFormat("pinRevision=%t")).
+		Returns(http.StatusCreated, "the new s2irun", devopsv1alpha1.S2iRun{}).
+		Metadata(restfulspec.KeyOpenAPITags, tags))
+
 	return ws
 }
@@ -2,10 +2,12 @@ package general
 
 import (
 	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
-	"github.com/kubesphere/s2ioperator/pkg/client/clientset/versioned/scheme"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"testing"
 )
@@ -17,6 +19,7 @@ var (
 const (
 	defaultUrl = "http://127.0.0.1:8000/s2itrigger/v1alpha1/general/namespaces/" + namespace + "/s2ibuilders/" + s2ibName
 	s2ibName   = "s2i-b"
+	refName    = "s2i-ref"
 	namespace  = "s2i"
 )
 
@@ -39,9 +42,32 @@ var _ = BeforeSuite(func() {
 		},
 	}
 
-	scheme := scheme.Scheme
-	c := fake.NewFakeClientWithScheme(scheme, s2ib)
+	ref := &devopsv1alpha1.S2iBuilder{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      refName,
+			Namespace: namespace,
+		},
+		Spec: devopsv1alpha1.S2iBuilderSpec{
+			Config: &devopsv1alpha1.S2iConfig{
+				RevisionId: "master",
+				TriggerSecretRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "trigger"},
+					Key:                  "codes",
+				},
+			},
+		},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "trigger",
+			Namespace: namespace,
+		},
+		Data: map[string][]byte{"codes": []byte("old-code\nnew-code\n")},
+	}
+
+	scheme := runtime.NewScheme()
+	Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+	Expect(devopsv1alpha1.AddToScheme(scheme)).To(Succeed())
+	c := fake.NewFakeClientWithScheme(scheme, s2ib, ref, secret)
 	t.KubeClientSet = c
-	t.Namespace = s2ib.Namespace
-	t.S2iBuilderName = s2ib.Name
 })
@@ -2,23 +2,29 @@ package general
 
 import (
 	"context"
+	"crypto/subtle"
+	"fmt"
 	"github.com/emicklei/go-restful"
 	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	log "k8s.io/klog"
 	"net/http"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strconv"
+	"strings"
 )
 
 const (
 	defaultCreater = "auto-trigger"
 )
 
+// Trigger creates s2iruns of the s2ibuilders in the requests, it is shared by all the requests, so that the
+// s2ibuilder of a request is kept in the request.
 type Trigger struct {
-	KubeClientSet  client.Client
-	S2iBuilderName string
-	Namespace      string
+	KubeClientSet client.Client
 }
 
 func NewTrigger(client client.Client) *Trigger {
@@ -30,11 +36,10 @@ func NewTrigger(client client.Client) *Trigger {
 func (g *Trigger) Serve(request *restful.Request, response *restful.Response) {
 
 	reqSecretCode := request.QueryParameter("secretCode")
-	g.S2iBuilderName = request.PathParameter("s2ibuilder")
-	g.Namespace = request.PathParameter("namespace")
+	namespace, s2iBuilderName := request.PathParameter("namespace"), request.PathParameter("s2ibuilder")
 
 	// Authentication
-	res, err := g.Authentication(reqSecretCode)
+	res, err := g.Authentication(namespace, s2iBuilderName, reqSecretCode)
 	if err != nil {
 		log.Error(err, "Failed to handle event")
 		response.WriteHeader(http.StatusInternalServerError)
@@ -48,7 +53,7 @@ func (g *Trigger) Serve(request *restful.Request, response *restful.Response) {
 	}
 
 	// create resource
-	err = g.Action()
+	err = g.Action(namespace, s2iBuilderName)
 	if err != nil {
 		log.Error(err, "Failed to handle event")
 		response.WriteHeader(http.StatusInternalServerError)
@@ -57,29 +62,142 @@ func (g *Trigger) Serve(request *restful.Request, response *restful.Response) {
 	response.WriteHeader(http.StatusCreated)
 }
 
-func (g *Trigger) Authentication(reqSecretCode string) (bool, error) {
+// Authentication returns true if the secret code authorizes the triggers of the s2ibuilder
+func (g *Trigger) Authentication(namespace, s2iBuilderName, reqSecretCode string) (bool, error) {
 	s2ibuilder := &devopsv1alpha1.S2iBuilder{}
 	namespaceName := types.NamespacedName{
-		Name:      g.S2iBuilderName,
-		Namespace: g.Namespace}
+		Name:      s2iBuilderName,
+		Namespace: namespace}
 	err := g.KubeClientSet.Get(context.TODO(), namespaceName, s2ibuilder)
 	if err != nil {
 		log.Error(err, "Can not get S2IBuilder.")
 		return false, err
 	}
 
-	if s2ibuilder.Spec.Config.SecretCode == reqSecretCode {
-		return true, nil
-	} else {
-		return false, nil
+	codes, err := SecretCodes(g.KubeClientSet, s2ibuilder)
+	if err != nil {
+		if _, ok := err.(*TriggerSecretError); ok {
+			// the triggers are denied rather than authorized without a secret code
+			log.Error(err)
+			return false, nil
+		}
+		return false, err
+	}
+	if len(codes) == 0 {
+		// the s2ibuilder without any secret code only accepts the triggers without a secret code
+		return reqSecretCode == "", nil
+	}
+	authorized := 0
+	for _, code := range codes {
+		// all of the codes are compared, so that the time does not tell which one matches
+		authorized |= subtle.ConstantTimeCompare([]byte(code), []byte(reqSecretCode))
+	}
+	return authorized == 1, nil
+}
+
+// TriggerSecretError is returned if the trigger secret of the s2ibuilder could not be resolved into any code
+type TriggerSecretError struct {
+	s2ibuilder *devopsv1alpha1.S2iBuilder
+	reason     string
+}
+
+func (e *TriggerSecretError) Error() string {
+	return fmt.Sprintf("the trigger secret of S2IBuilder %s/%s is unavailable: %s", e.s2ibuilder.Namespace,
+		e.s2ibuilder.Name, e.reason)
+}
+
+// SecretCodes returns the active secret codes of the s2ibuilder, which are the inline secret code and the
+// codes in the key of the referenced secret, one per line. A *TriggerSecretError is returned if the secret is
+// referenced but it is not found or has no code.
+func SecretCodes(kubeClient client.Client, s2ibuilder *devopsv1alpha1.S2iBuilder) ([]string, error) {
+	codes := make([]string, 0)
+	if s2ibuilder.Spec.Config.SecretCode != "" {
+		codes = append(codes, s2ibuilder.Spec.Config.SecretCode)
+	}
+	ref := s2ibuilder.Spec.Config.TriggerSecretRef
+	if ref == nil {
+		return codes, nil
+	}
+	secret := &corev1.Secret{}
+	err := kubeClient.Get(context.TODO(), types.NamespacedName{Namespace: s2ibuilder.Namespace, Name: ref.Name}, secret)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, &TriggerSecretError{s2ibuilder: s2ibuilder, reason: fmt.Sprintf("secret %s not found", ref.Name)}
+		}
+		return nil, err
+	}
+	value, ok := secret.Data[ref.Key]
+	if !ok {
+		return nil, &TriggerSecretError{s2ibuilder: s2ibuilder,
+			reason: fmt.Sprintf("key %s not found in secret %s", ref.Key, ref.Name)}
+	}
+	refCodes := 0
+	for _, line := range strings.Split(string(value), "\n") {
+		if code := strings.TrimSpace(line); code != "" {
+			codes = append(codes, code)
+			refCodes++
+		}
+	}
+	if refCodes == 0 {
+		return nil, &TriggerSecretError{s2ibuilder: s2ibuilder,
+			reason: fmt.Sprintf("no code in key %s of secret %s", ref.Key, ref.Name)}
+	}
+	return codes, nil
+}
+
+// Rerun creates a new s2irun with the same inputs as the s2irun in the request, the request is authorized
+// by the secret code of the s2ibuilder in the request before the s2irun is read.
+func (g *Trigger) Rerun(request *restful.Request, response *restful.Response) {
+	reqSecretCode := request.QueryParameter("secretCode")
+	pinRevision, _ := strconv.ParseBool(request.QueryParameter("pinRevision"))
+	namespace, s2iBuilderName := request.PathParameter("namespace"), request.PathParameter("s2ibuilder")
+
+	// Authentication
+	res, err := g.Authentication(namespace, s2iBuilderName, reqSecretCode)
+	if err != nil {
+		log.Error(err, "Failed to handle event")
+		response.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if !res {
+		log.Error(err, "Unauthorized")
+		response.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	s2irun := &devopsv1alpha1.S2iRun{}
+	err = g.KubeClientSet.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: request.PathParameter("s2irun")}, s2irun)
+	if err != nil {
+		log.Error(err, "Can not get S2IRun.")
+		if errors.IsNotFound(err) {
+			response.WriteHeader(http.StatusNotFound)
+		} else {
+			response.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+	// the code of a s2ibuilder only authorizes the reruns of its own s2iruns
+	if s2irun.Spec.BuilderName != s2iBuilderName {
+		response.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	rerun := s2irun.NewRerun(pinRevision)
+	rerun.Annotations["kubesphere.io/creator"] = defaultCreater
+	if err = g.KubeClientSet.Create(context.TODO(), rerun); err != nil {
+		log.Error(err, "Can not create S2IRun.")
+		response.WriteHeader(http.StatusInternalServerError)
+		return
 	}
+	response.WriteHeaderAndEntity(http.StatusCreated, rerun)
 }
 
 // do something when handler be triggered.
-func (g *Trigger) Action() error {
+func (g *Trigger) Action(namespace, s2iBuilderName string) error {
 
 	// generate s2irun resource
-	s2irun := g.GenerateNewS2Irun()
+	s2irun := g.GenerateNewS2Irun(namespace, s2iBuilderName)
 	err := g.KubeClientSet.Create(context.TODO(), s2irun)
 	if err != nil {
 		log.Error(err, "Can not create S2IRun.")
@@ -90,17 +208,18 @@ func (g *Trigger) Action() error {
 }
 
 // generate S2Irun yaml.
-func (g *Trigger) GenerateNewS2Irun() *devopsv1alpha1.S2iRun {
+func (g *Trigger) GenerateNewS2Irun(namespace, s2iBuilderName string) *devopsv1alpha1.S2iRun {
 	s2irun := &devopsv1alpha1.S2iRun{
 		ObjectMeta: v1.ObjectMeta{
-			GenerateName: g.S2iBuilderName,
-			Namespace:    g.Namespace,
+			GenerateName: s2iBuilderName,
+			Namespace:    namespace,
 			Annotations: map[string]string{
-				"kubesphere.io/creator": defaultCreater,
+				"kubesphere.io/creator":                defaultCreater,
+				devopsv1alpha1.TriggerSourceAnnotation: devopsv1alpha1.TriggerSourceGeneral,
 			},
 		},
 		Spec: devopsv1alpha1.S2iRunSpec{
-			BuilderName: g.S2iBuilderName,
+			BuilderName: s2iBuilderName,
 		},
 	}
 
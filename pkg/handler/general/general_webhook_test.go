@@ -7,9 +7,14 @@ import (
 	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"net/http"
 	"net/http/httptest"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
 
 var _ = Describe("Test general webhook", func() {
@@ -37,7 +42,7 @@ var _ = Describe("Test general webhook", func() {
 
 		s2iruns := &devopsv1alpha1.S2iRunList{}
 
-		err := t.KubeClientSet.List(context.TODO(), s2iruns, client.InNamespace(t.Namespace))
+		err := t.KubeClientSet.List(context.TODO(), s2iruns, client.InNamespace(namespace))
 		Expect(err).NotTo(HaveOccurred(), "Can not get s2irun after general webhook triggered")
 
 		instance := s2iruns.Items[0]
@@ -54,4 +59,134 @@ var _ = Describe("Test general webhook", func() {
 
 		Expect(httpWriter.Code).To(Equal(http.StatusUnauthorized))
 	})
+
+	It("Should create a new s2irun pinned to the built commit after rerun", func() {
+		origin := &devopsv1alpha1.S2iRun{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "s2ir",
+				Namespace: namespace,
+			},
+			Spec: devopsv1alpha1.S2iRunSpec{
+				BuilderName: s2ibName,
+				NewTag:      "v1",
+			},
+			Status: devopsv1alpha1.S2iRunStatus{
+				RunState: devopsv1alpha1.Failed,
+				S2iBuildSource: &devopsv1alpha1.S2iBuildSource{
+					CommitID: "e9c2f7a3b1d4",
+				},
+			},
+		}
+		Expect(t.KubeClientSet.Create(context.TODO(), origin)).To(Succeed())
+		defer t.KubeClientSet.Delete(context.TODO(), origin)
+
+		container := restful.NewContainer()
+		container.Add(t.WebService())
+		reqUrl := "http://127.0.0.1:8000/s2itrigger/v1alpha1/general/namespaces/" + namespace + "/s2ibuilders/" + s2ibName + "/s2iruns/s2ir/rerun"
+		httpRequest, _ := http.NewRequest("POST", reqUrl+"?secretCode=secretCode&pinRevision=true", nil)
+		httpWriter := httptest.NewRecorder()
+		container.ServeHTTP(httpWriter, httpRequest)
+		Expect(httpWriter.Code).To(Equal(http.StatusCreated))
+
+		s2iruns := &devopsv1alpha1.S2iRunList{}
+		Expect(t.KubeClientSet.List(context.TODO(), s2iruns, client.InNamespace(namespace))).To(Succeed())
+		Expect(s2iruns.Items).To(HaveLen(2))
+		for _, instance := range s2iruns.Items {
+			if instance.Name == origin.Name {
+				continue
+			}
+			Expect(instance.Annotations[devopsv1alpha1.RerunOfAnnotation]).To(Equal(origin.Name))
+			Expect(instance.Spec.NewTag).To(Equal("v1"))
+			Expect(instance.Spec.NewRevisionId).To(Equal("e9c2f7a3b1d4"))
+			t.KubeClientSet.Delete(context.TODO(), &instance)
+		}
+
+		httpRequest, _ = http.NewRequest("POST", reqUrl+"?secretCode=wrong", nil)
+		httpWriter = httptest.NewRecorder()
+		container.ServeHTTP(httpWriter, httpRequest)
+		Expect(httpWriter.Code).To(Equal(http.StatusUnauthorized))
+
+		// the code of another s2ibuilder does not authorize the rerun of the s2irun
+		otherUrl := "http://127.0.0.1:8000/s2itrigger/v1alpha1/general/namespaces/" + namespace + "/s2ibuilders/" + refName + "/s2iruns/s2ir/rerun"
+		httpRequest, _ = http.NewRequest("POST", otherUrl+"?secretCode=new-code", nil)
+		httpWriter = httptest.NewRecorder()
+		container.ServeHTTP(httpWriter, httpRequest)
+		Expect(httpWriter.Code).To(Equal(http.StatusNotFound))
+
+		// the requests are authorized before the s2iruns are read
+		httpRequest, _ = http.NewRequest("POST", otherUrl+"?secretCode=secretCode", nil)
+		httpWriter = httptest.NewRecorder()
+		container.ServeHTTP(httpWriter, httpRequest)
+		Expect(httpWriter.Code).To(Equal(http.StatusUnauthorized))
+
+		Expect(t.KubeClientSet.List(context.TODO(), s2iruns, client.InNamespace(namespace))).To(Succeed())
+		Expect(s2iruns.Items).To(HaveLen(1))
+	})
+
+	It("Should authorize the triggers with any code in the referenced secret", func() {
+		ref := &Trigger{KubeClientSet: t.KubeClientSet}
+		for _, code := range []string{"old-code", "new-code"} {
+			res, err := ref.Authentication(namespace, refName, code)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res).To(BeTrue(), code)
+		}
+		for _, code := range []string{"", "wrong", "old-code\nnew-code"} {
+			res, err := ref.Authentication(namespace, refName, code)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res).To(BeFalse(), code)
+		}
+
+		// the inline secret code keeps working
+		res, err := t.Authentication(namespace, s2ibName, "secretCode")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res).To(BeTrue())
+	})
+
+	It("Should deny the triggers if the referenced secret could not be resolved", func() {
+		newBuilder := func(name string, ref *corev1.SecretKeySelector) *devopsv1alpha1.S2iBuilder {
+			return &devopsv1alpha1.S2iBuilder{
+				ObjectMeta: v1.ObjectMeta{Name: name, Namespace: namespace},
+				Spec: devopsv1alpha1.S2iBuilderSpec{Config: &devopsv1alpha1.S2iConfig{
+					// the inline secret code does not authorize the triggers either
+					SecretCode:       "secretCode",
+					TriggerSecretRef: ref,
+				}},
+			}
+		}
+		missingSecret := newBuilder("missing-secret", &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "missing"}, Key: "codes"})
+		missingKey := newBuilder("missing-key", &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "trigger"}, Key: "missing"})
+		emptyKey := newBuilder("empty-key", &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "trigger"}, Key: "empty"})
+		secret := &corev1.Secret{
+			ObjectMeta: v1.ObjectMeta{Name: "trigger", Namespace: namespace},
+			Data:       map[string][]byte{"codes": []byte("code"), "empty": []byte("\n")},
+		}
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(devopsv1alpha1.AddToScheme(scheme)).To(Succeed())
+		c := fake.NewFakeClientWithScheme(scheme, missingSecret, missingKey, emptyKey, secret)
+
+		container := restful.NewContainer()
+		container.Add(NewTrigger(c).WebService())
+		for _, builder := range []string{missingSecret.Name, missingKey.Name, emptyKey.Name} {
+			trigger := &Trigger{KubeClientSet: c}
+			for _, code := range []string{"", "secretCode"} {
+				res, err := trigger.Authentication(namespace, builder, code)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(res).To(BeFalse(), builder+" "+code)
+			}
+
+			reqUrl := "http://127.0.0.1:8000/s2itrigger/v1alpha1/general/namespaces/" + namespace + "/s2ibuilders/" + builder
+			httpRequest, _ := http.NewRequest("GET", reqUrl, nil)
+			httpWriter := httptest.NewRecorder()
+			container.ServeHTTP(httpWriter, httpRequest)
+			Expect(httpWriter.Code).To(Equal(http.StatusUnauthorized), builder)
+		}
+
+		s2iruns := &devopsv1alpha1.S2iRunList{}
+		Expect(c.List(context.TODO(), s2iruns)).To(Succeed())
+		Expect(s2iruns.Items).To(BeEmpty())
+	})
 })
@@ -18,11 +18,16 @@ package github
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"github.com/emicklei/go-restful"
 	"github.com/google/go-github/github"
 	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
+	"github.com/kubesphere/s2ioperator/pkg/handler/general"
 	"io/ioutil"
+	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	log "k8s.io/klog"
@@ -35,12 +40,15 @@ import (
 const (
 	s2irunCreatorPre = "trigger-"
 	pushEvent        = "push"
+	// signatureHeader keeps the HMAC hex digest of the payload, which is signed with the secret of the github webhook
+	signatureHeader = "X-Hub-Signature-256"
+	signaturePrefix = "sha256="
 )
 
+// Trigger creates s2iruns of the s2ibuilders from the events of github webhooks, it is shared by all the requests,
+// so that the s2ibuilder of a request is kept in the request.
 type Trigger struct {
-	KubeClientSet  client.Client
-	S2iBuilderName string
-	Namespace      string
+	KubeClientSet client.Client
 }
 
 func NewTrigger(client client.Client) *Trigger {
@@ -50,8 +58,7 @@ func NewTrigger(client client.Client) *Trigger {
 }
 
 func (g *Trigger) Serve(request *restful.Request, response *restful.Response) {
-	g.S2iBuilderName = request.PathParameter("s2ibuilder")
-	g.Namespace = request.PathParameter("namespace")
+	namespace, s2iBuilderName := request.PathParameter("namespace"), request.PathParameter("s2ibuilder")
 
 	eventType := github.WebHookType(request.Request)
 	if eventType == "ping" {
@@ -66,29 +73,85 @@ func (g *Trigger) Serve(request *restful.Request, response *restful.Response) {
 		return
 	}
 
+	// Authentication
+	res, err := g.Authentication(namespace, s2iBuilderName, request.HeaderParameter(signatureHeader), eventPayload)
+	if err != nil {
+		log.Error(err, "Failed to handle event")
+		if errors.IsNotFound(err) {
+			response.WriteHeader(http.StatusNotFound)
+			return
+		}
+		response.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if !res {
+		log.Errorf("Unauthorized event for S2IBuilder %s in namespace %s", s2iBuilderName, namespace)
+		response.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
 	// validate payload
-	payload, err := g.ValidateTrigger(eventType, eventPayload)
+	payload, err := g.ValidateTrigger(namespace, s2iBuilderName, eventType, eventPayload)
 	if err != nil {
-		log.Error("Failed to validate event")
+		log.Error(err, "Failed to validate event")
 		response.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	err = g.Action(eventType, payload)
+	err = g.Action(namespace, s2iBuilderName, eventType, payload)
 	if err != nil {
 		log.Error(err, "Failed to handle event")
 		response.WriteHeader(http.StatusInternalServerError)
+		return
 	}
 	response.WriteHeader(http.StatusCreated)
-	log.Infof("Github handing event with S2IBuilder name %s in namespace %s", g.S2iBuilderName, g.Namespace)
+	log.Infof("Github handing event with S2IBuilder name %s in namespace %s", s2iBuilderName, namespace)
+}
 
+// Authentication returns true if the payload is signed with a secret code of the s2ibuilder, the s2ibuilder
+// without any secret code only accepts the unsigned payloads. The events are denied if the trigger secret
+// of the s2ibuilder could not be resolved.
+func (g *Trigger) Authentication(namespace, s2iBuilderName, signature string, payload []byte) (bool, error) {
+	s2ibuilder := &devopsv1alpha1.S2iBuilder{}
+	err := g.KubeClientSet.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: s2iBuilderName}, s2ibuilder)
+	if err != nil {
+		log.Error(err, "Can not get S2IBuilder.")
+		return false, err
+	}
+
+	codes, err := general.SecretCodes(g.KubeClientSet, s2ibuilder)
+	if err != nil {
+		if _, ok := err.(*general.TriggerSecretError); ok {
+			log.Error(err)
+			return false, nil
+		}
+		return false, err
+	}
+	if len(codes) == 0 {
+		return signature == "", nil
+	}
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return false, nil
+	}
+	digest, err := hex.DecodeString(strings.TrimPrefix(signature, signaturePrefix))
+	if err != nil {
+		return false, nil
+	}
+	authorized := false
+	for _, code := range codes {
+		mac := hmac.New(sha256.New, []byte(code))
+		mac.Write(payload)
+		// all of the codes are compared, so that the time does not tell which one matches
+		authorized = hmac.Equal(mac.Sum(nil), digest) || authorized
+	}
+	return authorized, nil
 }
 
-func (g *Trigger) ValidateTrigger(eventType string, payload []byte) ([]byte, error) {
+func (g *Trigger) ValidateTrigger(namespace, s2iBuilderName, eventType string, payload []byte) ([]byte, error) {
 	instance := &devopsv1alpha1.S2iBuilder{}
-	namespacedName := &types.NamespacedName{Namespace: g.Namespace, Name: g.S2iBuilderName}
+	namespacedName := &types.NamespacedName{Namespace: namespace, Name: s2iBuilderName}
 	err := g.KubeClientSet.Get(context.TODO(), *namespacedName, instance)
 	if err != nil {
-		log.Errorf("Failed to get S2IBuilder: %s, in namespace %s, with error: %s", g.S2iBuilderName, g.Namespace, err)
+		log.Errorf("Failed to get S2IBuilder: %s, in namespace %s, with error: %s", s2iBuilderName, namespace, err)
 		return nil, err
 	}
 
@@ -99,6 +162,9 @@ func (g *Trigger) ValidateTrigger(eventType string, payload []byte) ([]byte, err
 
 	// Can not get branch name directly.
 	event, err := github.ParseWebHook(eventType, payload)
+	if err != nil {
+		return nil, err
+	}
 	pushEvent := event.(*github.PushEvent)
 	gitref := pushEvent.Ref
 	branchName := strings.SplitAfterN(*gitref, "/", 3)[2]
@@ -122,11 +188,14 @@ func (g *Trigger) ValidateTrigger(eventType string, payload []byte) ([]byte, err
 }
 
 // do something when handler be triggered.
-func (g *Trigger) Action(eventType string, payload []byte) (err error) {
+func (g *Trigger) Action(namespace, s2iBuilderName, eventType string, payload []byte) (err error) {
 	event, err := github.ParseWebHook(eventType, payload)
+	if err != nil {
+		return err
+	}
 	switch eventType {
 	case pushEvent:
-		err = g.actionWithPushEvent(*event.(*github.PushEvent))
+		err = g.actionWithPushEvent(namespace, s2iBuilderName, *event.(*github.PushEvent))
 	case "PullRequestEvent":
 		err = g.actionWithPullRequestEvent(event.(github.PullRequestEvent))
 	default:
@@ -136,12 +205,16 @@ func (g *Trigger) Action(eventType string, payload []byte) (err error) {
 	return err
 }
 
-func (g *Trigger) actionWithPushEvent(event github.PushEvent) error {
+func (g *Trigger) actionWithPushEvent(namespace, s2iBuilderName string, event github.PushEvent) error {
+	// the push events which delete a branch have no head commit
+	if event.HeadCommit == nil {
+		return fmt.Errorf("push event of %s has no head commit", event.GetRef())
+	}
 	revisionId := event.HeadCommit.ID
 	creater := s2irunCreatorPre + *event.HeadCommit.Committer.Name
 
 	// create s2irun resource
-	s2irun := g.GenerateNewS2Irun(creater, *revisionId)
+	s2irun := g.GenerateNewS2Irun(namespace, s2iBuilderName, creater, *revisionId)
 	err := g.KubeClientSet.Create(context.TODO(), s2irun)
 	if err != nil {
 		log.Error(err, "Can not create S2IRun.")
@@ -150,17 +223,18 @@ func (g *Trigger) actionWithPushEvent(event github.PushEvent) error {
 	return nil
 }
 
-func (g *Trigger) GenerateNewS2Irun(creator, revisionId string) *devopsv1alpha1.S2iRun {
+func (g *Trigger) GenerateNewS2Irun(namespace, s2iBuilderName, creator, revisionId string) *devopsv1alpha1.S2iRun {
 	s2irun := &devopsv1alpha1.S2iRun{
 		ObjectMeta: v1.ObjectMeta{
-			GenerateName: g.S2iBuilderName,
-			Namespace:    g.Namespace,
+			GenerateName: s2iBuilderName,
+			Namespace:    namespace,
 			Annotations: map[string]string{
-				"kubesphere.io/creator": creator,
+				"kubesphere.io/creator":                creator,
+				devopsv1alpha1.TriggerSourceAnnotation: devopsv1alpha1.TriggerSourceGithub,
 			},
 		},
 		Spec: devopsv1alpha1.S2iRunSpec{
-			BuilderName:   g.S2iBuilderName,
+			BuilderName:   s2iBuilderName,
 			NewRevisionId: revisionId,
 		},
 	}
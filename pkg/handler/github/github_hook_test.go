@@ -3,9 +3,18 @@ package github
 import (
 	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/emicklei/go-restful"
 	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
 	"github.com/kubesphere/s2ioperator/pkg/client/clientset/versioned/scheme"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"net/http"
+	"net/http/httptest"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"testing"
 )
@@ -52,8 +61,7 @@ func TestValidateTrigger(t *testing.T) {
 	githubSink := NewTrigger(fakeKubeClient)
 
 	for _, v := range data {
-		githubSink.S2iBuilderName = v.S2ib.Name
-		res, err := githubSink.ValidateTrigger(pushEvent, v.PayLoad)
+		res, err := githubSink.ValidateTrigger(v.S2ib.Namespace, v.S2ib.Name, pushEvent, v.PayLoad)
 		if v.Result {
 			if !bytes.Equal(v.PayLoad, res) {
 				t.Fatalf("Get err %s", err)
@@ -108,9 +116,8 @@ func TestAction(t *testing.T) {
 	scheme := scheme.Scheme
 	fakeKubeClient := fake.NewFakeClientWithScheme(scheme, s2ib)
 	githubSink := NewTrigger(fakeKubeClient)
-	githubSink.S2iBuilderName = s2ib.Name
 
-	err := githubSink.Action(pushEvent, aPayLoad)
+	err := githubSink.Action(s2ib.Namespace, s2ib.Name, pushEvent, aPayLoad)
 	if err != nil {
 		t.Fatalf("Get err %s", err)
 	}
@@ -125,3 +132,112 @@ func TestAction(t *testing.T) {
 	}
 
 }
+
+func sign(code string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(code))
+	mac.Write(payload)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestAuthentication(t *testing.T) {
+	newBuilder := func(name string, ref *corev1.SecretKeySelector) *devopsv1alpha1.S2iBuilder {
+		return &devopsv1alpha1.S2iBuilder{
+			ObjectMeta: v1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: devopsv1alpha1.S2iBuilderSpec{
+				Config: &devopsv1alpha1.S2iConfig{RevisionId: "master", TriggerSecretRef: ref},
+			},
+		}
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: v1.ObjectMeta{Name: "trigger", Namespace: "default"},
+		Data:       map[string][]byte{"codes": []byte("old-code\nnew-code\n")},
+	}
+	s := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(s); err != nil {
+		t.Fatalf("Get err %s", err)
+	}
+	if err := devopsv1alpha1.AddToScheme(s); err != nil {
+		t.Fatalf("Get err %s", err)
+	}
+	fakeKubeClient := fake.NewFakeClientWithScheme(s, secret,
+		newBuilder("signed", &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "trigger"}, Key: "codes"}),
+		newBuilder("missing", &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "missing"}, Key: "codes"}),
+		newBuilder("unsigned", nil))
+	githubSink := NewTrigger(fakeKubeClient)
+	payload := []byte(`{"ref": "refs/heads/master"}`)
+
+	for _, test := range []struct {
+		builder   string
+		signature string
+		result    bool
+	}{
+		{builder: "signed", signature: sign("old-code", payload), result: true},
+		{builder: "signed", signature: sign("new-code", payload), result: true},
+		{builder: "signed", signature: sign("other-code", payload)},
+		{builder: "signed", signature: "sha256=not-hex"},
+		{builder: "signed"},
+		// the events are denied if the secret of the builder is not found
+		{builder: "missing", signature: sign("old-code", payload)},
+		{builder: "unsigned", result: true},
+		{builder: "unsigned", signature: sign("old-code", payload)},
+	} {
+		res, err := githubSink.Authentication("default", test.builder, test.signature, payload)
+		if err != nil {
+			t.Fatalf("Get err %s", err)
+		}
+		if res != test.result {
+			t.Fatalf("Authentication of builder %s with signature %q should be %t", test.builder, test.signature, test.result)
+		}
+	}
+}
+
+func TestServe(t *testing.T) {
+	s2ib := &devopsv1alpha1.S2iBuilder{
+		ObjectMeta: v1.ObjectMeta{Name: "s2i-a", Namespace: "default"},
+		Spec: devopsv1alpha1.S2iBuilderSpec{
+			Config: &devopsv1alpha1.S2iConfig{
+				RevisionId: "master",
+				SecretCode: "code",
+			},
+		},
+	}
+	fakeKubeClient := fake.NewFakeClientWithScheme(scheme.Scheme, s2ib)
+	container := restful.NewContainer()
+	container.Add(NewTrigger(fakeKubeClient).WebService())
+
+	serve := func(builder string, payload []byte, signature string) int {
+		httpRequest, _ := http.NewRequest("POST",
+			"/s2itrigger/v1alpha1/github/namespaces/default/s2ibuilders/"+builder, bytes.NewReader(payload))
+		httpRequest.Header.Set("Content-Type", "application/json")
+		httpRequest.Header.Set("X-GitHub-Event", pushEvent)
+		httpRequest.Header.Set(signatureHeader, signature)
+		httpWriter := httptest.NewRecorder()
+		container.ServeHTTP(httpWriter, httpRequest)
+		return httpWriter.Code
+	}
+
+	payload := []byte(`{"ref": "refs/heads/master", "head_commit": {"id": "1cb224cd", "committer": {"name": "foo"}}}`)
+	if code := serve("s2i-a", payload, sign("other-code", payload)); code != http.StatusUnauthorized {
+		t.Fatalf("The event with a wrong signature should be unauthorized, get %d", code)
+	}
+	if code := serve("s2i-b", payload, sign("code", payload)); code != http.StatusNotFound {
+		t.Fatalf("The event of a missing builder should not be found, get %d", code)
+	}
+	// the s2irun is not created without the head commit
+	invalid := []byte(`{"ref": "refs/heads/master"}`)
+	if code := serve("s2i-a", invalid, sign("code", invalid)); code != http.StatusInternalServerError {
+		t.Fatalf("The failed event should not be created, get %d", code)
+	}
+	if code := serve("s2i-a", payload, sign("code", payload)); code != http.StatusCreated {
+		t.Fatalf("The signed event should be created, get %d", code)
+	}
+	res := &devopsv1alpha1.S2iRunList{}
+	if err := fakeKubeClient.List(context.TODO(), res); err != nil {
+		t.Fatalf("Get err %s", err)
+	}
+	if len(res.Items) != 1 || res.Items[0].Spec.NewRevisionId != "1cb224cd" {
+		t.Fatalf("The s2irun should be created from the signed event, get %+v", res.Items)
+	}
+}
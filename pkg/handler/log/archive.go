@@ -0,0 +1,222 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
+	"github.com/kubesphere/s2ioperator/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	SinkNone      = "none"
+	SinkConfigMap = "ConfigMap"
+	SinkSecret    = "Secret"
+	SinkPVC       = "PVC"
+	SinkS3        = "S3"
+
+	// ArchiveDataKey is the key of the log in the ConfigMap or Secret
+	ArchiveDataKey = "log"
+	// DefaultArchiveMaxBytes keeps the ConfigMap or Secret of the log below the size limit of etcd, it also bounds
+	// the memory used to upload the log to S3
+	DefaultArchiveMaxBytes = 512 * 1024
+)
+
+// LogArchiver saves the log of the build pod after the job is finished, so that the log is still available
+// after the job is deleted.
+type LogArchiver interface {
+	// Archive saves the log of the pod of the job which is run by the S2iRun
+	Archive(ctx context.Context, run *devopsv1alpha1.S2iRun, pod *corev1.Pod, log io.Reader) (*devopsv1alpha1.S2iLogArchive, error)
+	// Open returns the archived log
+	Open(ctx context.Context, run *devopsv1alpha1.S2iRun, archive devopsv1alpha1.S2iLogArchive) (io.ReadCloser, error)
+}
+
+// NewLogArchiver returns the LogArchiver of the sink in the config, nil is returned if the log is not archived.
+func NewLogArchiver(cfg config.LogArchiveConfig, c client.Client) (LogArchiver, error) {
+	maxBytes := cfg.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultArchiveMaxBytes
+	}
+	switch strings.ToLower(cfg.Sink) {
+	case "", SinkNone:
+		return nil, nil
+	case strings.ToLower(SinkConfigMap):
+		return &objectArchiver{client: c, maxBytes: maxBytes}, nil
+	case strings.ToLower(SinkSecret):
+		return &objectArchiver{client: c, maxBytes: maxBytes, secret: true}, nil
+	case strings.ToLower(SinkPVC):
+		if cfg.Directory == "" {
+			return nil, fmt.Errorf("the directory of the volume is required by log archive sink %s", cfg.Sink)
+		}
+		return &directoryArchiver{directory: cfg.Directory}, nil
+	case strings.ToLower(SinkS3):
+		return newS3Archiver(cfg.S3, maxBytes)
+	}
+	return nil, fmt.Errorf("unknown log archive sink %s, it should be one of %s", cfg.Sink,
+		strings.Join([]string{SinkNone, SinkConfigMap, SinkSecret, SinkPVC, SinkS3}, ", "))
+}
+
+// archiveName returns the name of the archived log of the pod, which is unique in the namespace
+func archiveName(pod *corev1.Pod) string {
+	if job := pod.Labels["job-name"]; job != "" {
+		return job + "-log"
+	}
+	return pod.Name + "-log"
+}
+
+func newArchive(sink, location string, pod *corev1.Pod) *devopsv1alpha1.S2iLogArchive {
+	now := metav1.Now()
+	return &devopsv1alpha1.S2iLogArchive{
+		KubernetesJobName: pod.Labels["job-name"],
+		PodName:           pod.Name,
+		Sink:              sink,
+		Location:          location,
+		ArchiveTime:       &now,
+	}
+}
+
+// readTail returns the last maxBytes of the reader, and whether the data is truncated
+func readTail(r io.Reader, maxBytes int) ([]byte, bool, error) {
+	var data []byte
+	truncated := false
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		data = append(data, buf[:n]...)
+		if len(data) > 2*maxBytes {
+			data = append([]byte{}, data[len(data)-maxBytes:]...)
+			truncated = true
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, false, err
+		}
+	}
+	if len(data) > maxBytes {
+		data = data[len(data)-maxBytes:]
+		truncated = true
+	}
+	return data, truncated, nil
+}
+
+// objectArchiver saves the tail of the log in a ConfigMap or Secret owned by the S2iRun
+type objectArchiver struct {
+	client   client.Client
+	maxBytes int
+	secret   bool
+}
+
+func (o *objectArchiver) Archive(ctx context.Context, run *devopsv1alpha1.S2iRun, pod *corev1.Pod, log io.Reader) (*devopsv1alpha1.S2iLogArchive, error) {
+	data, truncated, err := readTail(log, o.maxBytes)
+	if err != nil {
+		return nil, err
+	}
+	meta := metav1.ObjectMeta{
+		Name:      archiveName(pod),
+		Namespace: run.Namespace,
+		Labels: map[string]string{
+			devopsv1alpha1.S2iRunLabel: run.Name,
+		},
+		OwnerReferences: []metav1.OwnerReference{
+			{
+				APIVersion: devopsv1alpha1.SchemeGroupVersion.String(),
+				Kind:       devopsv1alpha1.ResourceKindS2iRun,
+				Name:       run.Name,
+				UID:        run.UID,
+			},
+		},
+	}
+	var obj client.Object
+	sink := SinkConfigMap
+	if o.secret {
+		sink = SinkSecret
+		obj = &corev1.Secret{ObjectMeta: meta, Data: map[string][]byte{ArchiveDataKey: data}}
+	} else {
+		obj = &corev1.ConfigMap{ObjectMeta: meta, BinaryData: map[string][]byte{ArchiveDataKey: data}}
+	}
+	if err = o.client.Create(ctx, obj); err != nil {
+		if !k8serror.IsAlreadyExists(err) {
+			return nil, err
+		}
+		if err = o.client.Update(ctx, obj); err != nil {
+			return nil, err
+		}
+	}
+	archive := newArchive(sink, meta.Name, pod)
+	archive.Truncated = truncated
+	return archive, nil
+}
+
+func (o *objectArchiver) Open(ctx context.Context, run *devopsv1alpha1.S2iRun, archive devopsv1alpha1.S2iLogArchive) (io.ReadCloser, error) {
+	key := types.NamespacedName{Namespace: run.Namespace, Name: archive.Location}
+	var data []byte
+	switch archive.Sink {
+	case SinkSecret:
+		secret := &corev1.Secret{}
+		if err := o.client.Get(ctx, key, secret); err != nil {
+			return nil, err
+		}
+		data = secret.Data[ArchiveDataKey]
+	case SinkConfigMap:
+		cm := &corev1.ConfigMap{}
+		if err := o.client.Get(ctx, key, cm); err != nil {
+			return nil, err
+		}
+		data = cm.BinaryData[ArchiveDataKey]
+	default:
+		return nil, fmt.Errorf("the log is archived in %s, but the sink is %s", archive.Sink, SinkConfigMap)
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+// directoryArchiver saves the log in a directory, which is usually a PVC mounted in the operator
+type directoryArchiver struct {
+	directory string
+}
+
+func (d *directoryArchiver) Archive(ctx context.Context, run *devopsv1alpha1.S2iRun, pod *corev1.Pod, log io.Reader) (*devopsv1alpha1.S2iLogArchive, error) {
+	dir := filepath.Join(d.directory, run.Namespace, run.Name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, archiveName(pod))
+	// write to a temporary file first, so that a partial log is never seen
+	f, err := ioutil.TempFile(dir, ".log-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	if _, err = io.Copy(f, log); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err = f.Close(); err != nil {
+		return nil, err
+	}
+	if err = os.Rename(f.Name(), path); err != nil {
+		return nil, err
+	}
+	return newArchive(SinkPVC, path, pod), nil
+}
+
+func (d *directoryArchiver) Open(ctx context.Context, run *devopsv1alpha1.S2iRun, archive devopsv1alpha1.S2iLogArchive) (io.ReadCloser, error) {
+	// the location is written by the operator, but make sure it is still in the directory
+	path := filepath.Clean(archive.Location)
+	if archive.Sink != SinkPVC || !strings.HasPrefix(path, filepath.Clean(d.directory)+string(filepath.Separator)) {
+		return nil, fmt.Errorf("the log is not archived in %s", d.directory)
+	}
+	return os.Open(path)
+}
@@ -0,0 +1,121 @@
+package log_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+
+	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
+	"github.com/kubesphere/s2ioperator/pkg/config"
+	"github.com/kubesphere/s2ioperator/pkg/handler/log"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Testing log archive", func() {
+	run := &devopsv1alpha1.S2iRun{ObjectMeta: metav1.ObjectMeta{Name: "run-1", Namespace: "default", UID: "uid-1"}}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:      "job-1-abcde",
+		Namespace: "default",
+		Labels:    map[string]string{"job-name": "job-1"},
+	}}
+	content := "Cloning source\nPushing image\nBuild completed successfully\n"
+
+	It("Should keep the tail of the log in a ConfigMap", func() {
+		c := fake.NewFakeClientWithScheme(scheme.Scheme)
+		archiver, err := log.NewLogArchiver(config.LogArchiveConfig{Sink: log.SinkConfigMap, MaxBytes: 29}, c)
+		Expect(err).ShouldNot(HaveOccurred())
+		archive, err := archiver.Archive(context.TODO(), run, pod, strings.NewReader(content))
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(archive.Location).To(Equal("job-1-log"))
+		Expect(archive.Truncated).To(BeTrue())
+
+		cm := &corev1.ConfigMap{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "job-1-log"}, cm)).To(Succeed())
+		Expect(cm.OwnerReferences).To(HaveLen(1))
+		Expect(string(cm.BinaryData[log.ArchiveDataKey])).To(Equal("Build completed successfully\n"))
+
+		reader, err := archiver.Open(context.TODO(), run, *archive)
+		Expect(err).ShouldNot(HaveOccurred())
+		data, _ := ioutil.ReadAll(reader)
+		Expect(string(data)).To(Equal("Build completed successfully\n"))
+	})
+
+	It("Should save the log in the directory", func() {
+		dir, err := ioutil.TempDir("", "s2i-logs")
+		Expect(err).ShouldNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+		archiver, err := log.NewLogArchiver(config.LogArchiveConfig{Sink: log.SinkPVC, Directory: dir}, nil)
+		Expect(err).ShouldNot(HaveOccurred())
+		archive, err := archiver.Archive(context.TODO(), run, pod, strings.NewReader(content))
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(archive.Truncated).To(BeFalse())
+
+		reader, err := archiver.Open(context.TODO(), run, *archive)
+		Expect(err).ShouldNot(HaveOccurred())
+		data, _ := ioutil.ReadAll(reader)
+		reader.Close()
+		Expect(string(data)).To(Equal(content))
+
+		_, err = archiver.Open(context.TODO(), run, devopsv1alpha1.S2iLogArchive{Sink: log.SinkPVC, Location: "/etc/passwd"})
+		Expect(err).Should(HaveOccurred())
+	})
+
+	It("Should upload the signed log to the object store", func() {
+		objects := map[string]string{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=access/") {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			switch r.Method {
+			case http.MethodPut:
+				data, _ := ioutil.ReadAll(r.Body)
+				objects[r.URL.Path] = string(data)
+			case http.MethodGet:
+				w.Write([]byte(objects[r.URL.Path]))
+			}
+		}))
+		defer server.Close()
+		os.Setenv(log.S3AccessKeyEnv, "access")
+		os.Setenv(log.S3SecretKeyEnv, "secret")
+		defer os.Unsetenv(log.S3AccessKeyEnv)
+		defer os.Unsetenv(log.S3SecretKeyEnv)
+
+		archiver, err := log.NewLogArchiver(config.LogArchiveConfig{Sink: log.SinkS3, S3: config.S3Config{
+			Endpoint: server.URL,
+			Bucket:   "logs",
+			Prefix:   "s2i",
+		}}, nil)
+		Expect(err).ShouldNot(HaveOccurred())
+		archive, err := archiver.Archive(context.TODO(), run, pod, strings.NewReader(content))
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(archive.Location).To(Equal("s3://logs/s2i/default/run-1/job-1-log"))
+		Expect(objects["/logs/s2i/default/run-1/job-1-log"]).To(Equal(content))
+
+		reader, err := archiver.Open(context.TODO(), run, *archive)
+		Expect(err).ShouldNot(HaveOccurred())
+		data, _ := ioutil.ReadAll(reader)
+		reader.Close()
+		Expect(string(data)).To(Equal(content))
+
+		// only the tail of the log is uploaded
+		archiver, err = log.NewLogArchiver(config.LogArchiveConfig{Sink: log.SinkS3, MaxBytes: 4, S3: config.S3Config{
+			Endpoint: server.URL,
+			Bucket:   "logs",
+		}}, nil)
+		Expect(err).ShouldNot(HaveOccurred())
+		archive, err = archiver.Archive(context.TODO(), run, pod, strings.NewReader(content))
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(archive.Truncated).To(BeTrue())
+		Expect(objects["/logs/default/run-1/job-1-log"]).To(Equal(content[len(content)-4:]))
+	})
+})
@@ -1,9 +1,114 @@
 package log
 
-import "fmt"
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"text/template"
+
+	"github.com/kubesphere/s2ioperator/pkg/config"
+)
+
+const (
+	BackendNone          = "none"
+	BackendKubeSphere    = "kubesphere"
+	BackendLoki          = "loki"
+	BackendElasticsearch = "elasticsearch"
+	BackendTemplate      = "template"
+
+	// DefaultLokiQuery is the LogQL which selects the log of the pod
+	DefaultLokiQuery = `{namespace="{{.Namespace}}", pod="{{.Pod}}"}`
+	// DefaultKibanaQuery is the KQL which selects the log of the pod collected by fluentd or fluent-bit
+	DefaultKibanaQuery = `kubernetes.namespace_name:"{{.Namespace}}" and kubernetes.pod_name:"{{.Pod}}"`
+)
+
+// PodLog is the pod whose log is looked up, it is the data of the URL and query templates.
+type PodLog struct {
+	Namespace string
+	Pod       string
+	Container string
+	Job       string
+	S2iRun    string
+}
 
 type LogURLGetter interface {
-	GetURLOfPodLog(string, string) (string, error)
+	GetURLOfPodLog(PodLog) (string, error)
+}
+
+// NewLogURLGetter returns the LogURLGetter of the backend in the config
+func NewLogURLGetter(cfg config.LogURLConfig) (LogURLGetter, error) {
+	switch cfg.Backend {
+	case BackendNone:
+		return noneLogGetter{}, nil
+	case "", BackendKubeSphere:
+		getter := GetKubesphereLogger().(kubesphereLogGetter)
+		if cfg.URL != "" {
+			u, err := url.Parse(cfg.URL)
+			if err != nil {
+				return nil, err
+			}
+			getter.Protocol = u.Scheme
+			getter.URL = u.Host + strings.TrimSuffix(u.Path, "/")
+		}
+		return getter, nil
+	case BackendLoki:
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("the url of grafana is required by log backend %s", cfg.Backend)
+		}
+		query, err := newTemplate(cfg.Template, DefaultLokiQuery)
+		if err != nil {
+			return nil, err
+		}
+		datasource := cfg.Datasource
+		if datasource == "" {
+			datasource = "Loki"
+		}
+		return lokiLogGetter{GrafanaURL: strings.TrimSuffix(cfg.URL, "/"), Datasource: datasource, Query: query}, nil
+	case BackendElasticsearch:
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("the url of kibana is required by log backend %s", cfg.Backend)
+		}
+		query, err := newTemplate(cfg.Template, DefaultKibanaQuery)
+		if err != nil {
+			return nil, err
+		}
+		return kibanaLogGetter{KibanaURL: strings.TrimSuffix(cfg.URL, "/"), IndexPattern: cfg.Datasource, Query: query}, nil
+	case BackendTemplate:
+		if cfg.Template == "" {
+			return nil, fmt.Errorf("the url template is required by log backend %s", cfg.Backend)
+		}
+		t, err := newTemplate(cfg.Template, "")
+		if err != nil {
+			return nil, err
+		}
+		return templateLogGetter{Template: t}, nil
+	}
+	return nil, fmt.Errorf("unknown log backend %s, it should be one of %s", cfg.Backend,
+		strings.Join([]string{BackendNone, BackendKubeSphere, BackendLoki, BackendElasticsearch, BackendTemplate}, ", "))
+}
+
+func newTemplate(text, defaultText string) (*template.Template, error) {
+	if text == "" {
+		text = defaultText
+	}
+	return template.New("log").Option("missingkey=error").Parse(text)
+}
+
+func execute(t *template.Template, pod PodLog) (string, error) {
+	buf := &bytes.Buffer{}
+	if err := t.Execute(buf, pod); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// noneLogGetter is used if the log is not collected
+type noneLogGetter struct{}
+
+func (noneLogGetter) GetURLOfPodLog(PodLog) (string, error) {
+	return "", nil
 }
 
 type kubesphereLogGetter struct {
@@ -12,8 +117,8 @@ type kubesphereLogGetter struct {
 	URL      string
 }
 
-func (k kubesphereLogGetter) GetURLOfPodLog(namespace, pod string) (string, error) {
-	return fmt.Sprintf("%s://%s/%s/namespaces/%s/pods/%s?operation=query", k.Protocol, k.URL, k.Version, namespace, pod), nil
+func (k kubesphereLogGetter) GetURLOfPodLog(pod PodLog) (string, error) {
+	return fmt.Sprintf("%s://%s/%s/namespaces/%s/pods/%s?operation=query", k.Protocol, k.URL, k.Version, pod.Namespace, pod.Pod), nil
 }
 
 func GetKubesphereLogger() LogURLGetter {
@@ -23,3 +128,62 @@ func GetKubesphereLogger() LogURLGetter {
 		Protocol: "http",
 	}
 }
+
+// lokiLogGetter returns the url of Grafana Explore which queries the log in Loki
+type lokiLogGetter struct {
+	GrafanaURL string
+	Datasource string
+	Query      *template.Template
+}
+
+func (l lokiLogGetter) GetURLOfPodLog(pod PodLog) (string, error) {
+	query, err := execute(l.Query, pod)
+	if err != nil {
+		return "", err
+	}
+	state, err := json.Marshal(map[string]interface{}{
+		"datasource": l.Datasource,
+		"queries":    []map[string]string{{"refId": "A", "expr": query}},
+		"range":      map[string]string{"from": "now-1h", "to": "now"},
+	})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/explore?orgId=1&left=%s", l.GrafanaURL, url.QueryEscape(string(state))), nil
+}
+
+// kibanaLogGetter returns the url of Kibana Discover which queries the log in Elasticsearch
+type kibanaLogGetter struct {
+	KibanaURL    string
+	IndexPattern string
+	Query        *template.Template
+}
+
+// risonString quotes the string in Rison which is used in the state of Kibana
+func risonString(s string) string {
+	return "'" + strings.NewReplacer("!", "!!", "'", "!'").Replace(s) + "'"
+}
+
+func (k kibanaLogGetter) GetURLOfPodLog(pod PodLog) (string, error) {
+	query, err := execute(k.Query, pod)
+	if err != nil {
+		return "", err
+	}
+	state := fmt.Sprintf("(query:(language:kuery,query:%s)", risonString(query))
+	if k.IndexPattern != "" {
+		state = fmt.Sprintf("(index:%s,query:(language:kuery,query:%s)", risonString(k.IndexPattern), risonString(query))
+	}
+	state += ")"
+	return fmt.Sprintf("%s/app/discover#/?_g=%s&_a=%s", k.KibanaURL,
+		url.QueryEscape("(time:(from:now-1d,to:now))"), url.QueryEscape(state)), nil
+}
+
+// templateLogGetter returns the url generated by the template, e.g.
+// https://logs.example.com/{{.Namespace}}/{{.Pod}}?container={{.Container}}
+type templateLogGetter struct {
+	Template *template.Template
+}
+
+func (t templateLogGetter) GetURLOfPodLog(pod PodLog) (string, error) {
+	return execute(t.Template, pod)
+}
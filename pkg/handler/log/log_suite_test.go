@@ -1,8 +1,10 @@
 package log_test
 
 import (
+	"net/url"
 	"testing"
 
+	"github.com/kubesphere/s2ioperator/pkg/config"
 	"github.com/kubesphere/s2ioperator/pkg/handler/log"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -16,8 +18,56 @@ func TestLog(t *testing.T) {
 var _ = Describe("Testing logging URL for kubesphere", func() {
 	It("Should get right url", func() {
 		logger := log.GetKubesphereLogger()
-		str, err := logger.GetURLOfPodLog("default", "pod-1")
+		str, err := logger.GetURLOfPodLog(log.PodLog{Namespace: "default", Pod: "pod-1"})
 		Expect(err).ShouldNot(HaveOccurred())
 		Expect(str).To(Equal("http://ks-apigateway.kubesphere-system.svc/kapis/logging.kubesphere.io/v1alpha2/namespaces/default/pods/pod-1?operation=query"))
 	})
 })
+
+var _ = Describe("Testing logging URL for the backends", func() {
+	pod := log.PodLog{Namespace: "default", Pod: "pod-1", Container: "s2irun", Job: "job-1", S2iRun: "run-1"}
+
+	It("Should get empty url without backend", func() {
+		logger, err := log.NewLogURLGetter(config.LogURLConfig{Backend: log.BackendNone})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(logger.GetURLOfPodLog(pod)).To(BeEmpty())
+	})
+
+	It("Should get url of grafana explore", func() {
+		logger, err := log.NewLogURLGetter(config.LogURLConfig{Backend: log.BackendLoki, URL: "https://grafana.example.com/"})
+		Expect(err).ShouldNot(HaveOccurred())
+		str, err := logger.GetURLOfPodLog(pod)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(str).To(HavePrefix("https://grafana.example.com/explore?orgId=1&left="))
+		u, err := url.Parse(str)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(u.Query().Get("left")).To(ContainSubstring(`{namespace=\"default\", pod=\"pod-1\"}`))
+	})
+
+	It("Should get url of kibana discover", func() {
+		logger, err := log.NewLogURLGetter(config.LogURLConfig{Backend: log.BackendElasticsearch, URL: "https://kibana.example.com"})
+		Expect(err).ShouldNot(HaveOccurred())
+		str, err := logger.GetURLOfPodLog(pod)
+		Expect(err).ShouldNot(HaveOccurred())
+		u, err := url.Parse(str)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(u.Path).To(Equal("/app/discover"))
+		Expect(str).To(ContainSubstring(url.QueryEscape(`kubernetes.pod_name:"pod-1"`)))
+	})
+
+	It("Should get url of the template", func() {
+		logger, err := log.NewLogURLGetter(config.LogURLConfig{
+			Backend:  log.BackendTemplate,
+			Template: "https://logs.example.com/{{.Namespace}}/{{.Pod}}?container={{.Container}}&run={{.S2iRun | urlquery}}",
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(logger.GetURLOfPodLog(pod)).To(Equal("https://logs.example.com/default/pod-1?container=s2irun&run=run-1"))
+	})
+
+	It("Should fail with unknown backend", func() {
+		_, err := log.NewLogURLGetter(config.LogURLConfig{Backend: "splunk"})
+		Expect(err).Should(HaveOccurred())
+		_, err = log.NewLogURLGetter(config.LogURLConfig{Backend: log.BackendLoki})
+		Expect(err).Should(HaveOccurred())
+	})
+})
@@ -0,0 +1,175 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
+	"github.com/kubesphere/s2ioperator/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// S3AccessKeyEnv and S3SecretKeyEnv are the environments of the credential of the object store
+	S3AccessKeyEnv = "AWS_ACCESS_KEY_ID"
+	S3SecretKeyEnv = "AWS_SECRET_ACCESS_KEY"
+
+	s3TimeFormat = "20060102T150405Z"
+	s3DateFormat = "20060102"
+)
+
+// s3Archiver saves the log in an S3 compatible object store, the requests are signed with AWS Signature
+// Version 4 and the bucket is addressed in path style, which is supported by most of the object stores.
+type s3Archiver struct {
+	endpoint  *url.URL
+	bucket    string
+	region    string
+	prefix    string
+	accessKey string
+	secretKey string
+	maxBytes  int
+	client    *http.Client
+}
+
+func newS3Archiver(cfg config.S3Config, maxBytes int) (*s3Archiver, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, fmt.Errorf("the endpoint and bucket are required by log archive sink %s", SinkS3)
+	}
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid endpoint %s of log archive sink %s", cfg.Endpoint, SinkS3)
+	}
+	region := cfg.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	return &s3Archiver{
+		endpoint:  endpoint,
+		bucket:    cfg.Bucket,
+		region:    region,
+		prefix:    strings.Trim(cfg.Prefix, "/"),
+		accessKey: os.Getenv(S3AccessKeyEnv),
+		secretKey: os.Getenv(S3SecretKeyEnv),
+		maxBytes:  maxBytes,
+		client:    &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+func (s *s3Archiver) objectKey(run *devopsv1alpha1.S2iRun, pod *corev1.Pod) string {
+	return path.Join(s.prefix, run.Namespace, run.Name, archiveName(pod))
+}
+
+func (s *s3Archiver) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	u.Path = path.Join("/", u.Path, s.bucket, key)
+	u.RawQuery = ""
+	return &u
+}
+
+func (s *s3Archiver) Archive(ctx context.Context, run *devopsv1alpha1.S2iRun, pod *corev1.Pod, log io.Reader) (*devopsv1alpha1.S2iLogArchive, error) {
+	// the payload is signed, so that the tail of the log is kept in memory as the ConfigMap and Secret sinks
+	data, truncated, err := readTail(log, s.maxBytes)
+	if err != nil {
+		return nil, err
+	}
+	key := s.objectKey(run, pod)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(key).String(), bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	s.sign(req, data, time.Now())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("failed to upload log to %s: %s %s", key, resp.Status, string(body))
+	}
+	archive := newArchive(SinkS3, fmt.Sprintf("s3://%s/%s", s.bucket, key), pod)
+	archive.Truncated = truncated
+	return archive, nil
+}
+
+func (s *s3Archiver) Open(ctx context.Context, run *devopsv1alpha1.S2iRun, archive devopsv1alpha1.S2iLogArchive) (io.ReadCloser, error) {
+	prefix := fmt.Sprintf("s3://%s/", s.bucket)
+	if archive.Sink != SinkS3 || !strings.HasPrefix(archive.Location, prefix) {
+		return nil, fmt.Errorf("the log is not archived in bucket %s", s.bucket)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectURL(strings.TrimPrefix(archive.Location, prefix)).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	s.sign(req, nil, time.Now())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to download log %s: %s", archive.Location, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// sign adds the authorization header of AWS Signature Version 4 to the request, the request is not signed
+// if no credential is set.
+func (s *s3Archiver) sign(req *http.Request, payload []byte, now time.Time) {
+	payloadHash := sha256Hex(payload)
+	amzDate := now.UTC().Format(s3TimeFormat)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.accessKey == "" {
+		return
+	}
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host + "\n" +
+			"x-amz-content-sha256:" + payloadHash + "\n" +
+			"x-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := strings.Join([]string{now.UTC().Format(s3DateFormat), s.region, "s3", "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := []byte("AWS4" + s.secretKey)
+	for _, part := range strings.Split(scope, "/") {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
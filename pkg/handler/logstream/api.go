@@ -0,0 +1,33 @@
+package logstream
+
+import (
+	"net/http"
+
+	"github.com/emicklei/go-restful"
+	"github.com/emicklei/go-restful-openapi"
+	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
+)
+
+var tags = []string{"s2i_log"}
+
+// AddToWebService adds the log route to the web service of s2i resources, whose path is /s2i/v1alpha1
+func (s *Streamer) AddToWebService(ws *restful.WebService) {
+	// stream the log of the build pod of a s2irun
+	ws.Route(ws.GET("/namespaces/{namespace}/s2iruns/{s2irun}/log").
+		To(s.StreamLog).
+		Filter(s.Authorizer.Filter(devopsv1alpha1.ResourcePluralS2iRun, "s2irun")).
+		Doc("stream the log of the s2irun, the archived log is returned if the build pod is deleted").
+		Produces(MIMETextPlain, MIMEEventStream).
+		Param(ws.HeaderParameter("Authorization", "the bearer token of the user, who should be able to get the s2irun").
+			Required(true)).
+		Param(ws.PathParameter("namespace", "namespace")).
+		Param(ws.PathParameter("s2irun", "the name of s2irun")).
+		Param(ws.QueryParameter("follow", "follow the log until the build is finished").
+			DataType("boolean").
+			DataFormat("follow=%t")).
+		Returns(http.StatusOK, "the log of the s2irun", nil).
+		Returns(http.StatusUnauthorized, "the token is invalid", nil).
+		Returns(http.StatusForbidden, "the user can not get the s2irun", nil).
+		Returns(http.StatusNotFound, "the s2irun or its log is not found", nil).
+		Metadata(restfulspec.KeyOpenAPITags, tags))
+}
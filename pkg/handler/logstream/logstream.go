@@ -0,0 +1,222 @@
+package logstream
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emicklei/go-restful"
+	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
+	"github.com/kubesphere/s2ioperator/pkg/handler/auth"
+	loghandler "github.com/kubesphere/s2ioperator/pkg/handler/log"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	log "k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	MIMETextPlain   = "text/plain"
+	MIMEEventStream = "text/event-stream"
+
+	// podStartInterval is the interval of checking whether the build pod is started when the log is followed
+	podStartInterval = 2 * time.Second
+)
+
+// Streamer streams the log of s2iruns to the users who can get the s2iruns, so that the users can watch
+// the builds without the access to the pods.
+type Streamer struct {
+	KubeClientSet client.Client
+	KubeClient    kubernetes.Interface
+	LogArchiver   loghandler.LogArchiver
+	Authorizer    *auth.Authorizer
+}
+
+func NewStreamer(client client.Client, kubeClient kubernetes.Interface, logArchiver loghandler.LogArchiver) *Streamer {
+	return &Streamer{
+		KubeClientSet: client,
+		KubeClient:    kubeClient,
+		LogArchiver:   logArchiver,
+		Authorizer:    auth.NewAuthorizer(kubeClient),
+	}
+}
+
+// StreamLog writes the log of the latest build pod of the s2irun in chunks, or as server-sent events if
+// the client accepts text/event-stream. The archived log is written if the pod is deleted. The user is
+// authorized to get the s2irun by the filter of the route.
+func (s *Streamer) StreamLog(request *restful.Request, response *restful.Response) {
+	namespace := request.PathParameter("namespace")
+	name := request.PathParameter("s2irun")
+	follow, _ := strconv.ParseBool(request.QueryParameter("follow"))
+	ctx := request.Request.Context()
+
+	instance := &devopsv1alpha1.S2iRun{}
+	if err := s.KubeClientSet.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, instance); err != nil {
+		log.Error(err, "Can not get S2IRun.")
+		if errors.IsNotFound(err) {
+			response.WriteHeader(http.StatusNotFound)
+		} else {
+			response.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	stream, err := s.openPodLog(ctx, instance, follow)
+	if err != nil {
+		log.Error(err, "Can not get log of the build pod.")
+		if errors.IsBadRequest(err) {
+			// the container is not started yet
+			response.WriteHeader(http.StatusConflict)
+		} else {
+			response.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+	if stream == nil {
+		if stream, err = s.openArchivedLog(ctx, instance); err != nil {
+			log.Error(err, "Can not get archived log.")
+			response.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+	if stream == nil {
+		response.WriteHeader(http.StatusNotFound)
+		return
+	}
+	defer stream.Close()
+
+	if err = writeLog(response, stream, strings.Contains(request.HeaderParameter("Accept"), MIMEEventStream)); err != nil {
+		log.Error(err, "Failed to write log.")
+	}
+}
+
+// getLatestPod returns the latest pod of the current job of the s2irun, nil is returned if there is no pod.
+func (s *Streamer) getLatestPod(ctx context.Context, instance *devopsv1alpha1.S2iRun) (*corev1.Pod, error) {
+	if instance.Status.KubernetesJobName == "" {
+		return nil, nil
+	}
+	pods := &corev1.PodList{}
+	err := s.KubeClientSet.List(ctx, pods, client.InNamespace(instance.Namespace),
+		client.MatchingLabels{"job-name": instance.Status.KubernetesJobName})
+	if err != nil || len(pods.Items) == 0 {
+		return nil, err
+	}
+	sort.Slice(pods.Items, func(i, j int) bool {
+		return pods.Items[i].CreationTimestamp.Before(&pods.Items[j].CreationTimestamp)
+	})
+	return &pods.Items[len(pods.Items)-1], nil
+}
+
+// openPodLog opens the log of the latest build pod, nil is returned if the pod is gone. The pod is waited
+// to start if the log is followed.
+func (s *Streamer) openPodLog(ctx context.Context, instance *devopsv1alpha1.S2iRun, follow bool) (io.ReadCloser, error) {
+	pod, err := s.getLatestPod(ctx, instance)
+	if err != nil || pod == nil {
+		return nil, err
+	}
+	if follow && pod.Status.Phase == corev1.PodPending {
+		err = wait.PollImmediateUntil(podStartInterval, func() (bool, error) {
+			latest := &corev1.Pod{}
+			if err := s.KubeClientSet.Get(ctx, types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}, latest); err != nil {
+				return false, err
+			}
+			return latest.Status.Phase != corev1.PodPending, nil
+		}, ctx.Done())
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	stream, err := s.KubeClient.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+		Container: devopsv1alpha1.S2iRunContainerName,
+		Follow:    follow,
+	}).Stream(ctx)
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	return stream, err
+}
+
+// openArchivedLog opens the archived log of the current job of the s2irun, nil is returned if the log is
+// not archived.
+func (s *Streamer) openArchivedLog(ctx context.Context, instance *devopsv1alpha1.S2iRun) (io.ReadCloser, error) {
+	if s.LogArchiver == nil || len(instance.Status.LogArchives) == 0 {
+		return nil, nil
+	}
+	archive := instance.Status.LogArchives[len(instance.Status.LogArchives)-1]
+	for _, a := range instance.Status.LogArchives {
+		if a.KubernetesJobName == instance.Status.KubernetesJobName {
+			archive = a
+		}
+	}
+	return s.LogArchiver.Open(ctx, instance, archive)
+}
+
+// writeLog writes the log to the response and flushes it after each chunk, the log is written line by
+// line as the data of server-sent events if sse is true, and an end event is sent after the log.
+func writeLog(response *restful.Response, stream io.Reader, sse bool) error {
+	flusher, _ := response.ResponseWriter.(http.Flusher)
+	flush := func() {
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	header := response.Header()
+	header.Set("Cache-Control", "no-cache")
+	header.Set("X-Content-Type-Options", "nosniff")
+	if !sse {
+		header.Set("Content-Type", MIMETextPlain+"; charset=utf-8")
+		response.WriteHeader(http.StatusOK)
+		flush()
+		buf := make([]byte, 4096)
+		for {
+			n, err := stream.Read(buf)
+			if n > 0 {
+				if _, werr := response.Write(buf[:n]); werr != nil {
+					return werr
+				}
+				flush()
+			}
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	header.Set("Content-Type", MIMEEventStream)
+	response.WriteHeader(http.StatusOK)
+	flush()
+	reader := bufio.NewReader(stream)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			if _, werr := fmt.Fprintf(response, "data: %s\n\n", strings.TrimRight(line, "\r\n")); werr != nil {
+				return werr
+			}
+			flush()
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprint(response, "event: end\ndata: \n\n")
+	flush()
+	return err
+}
@@ -0,0 +1,13 @@
+package logstream
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestLogStream(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Log stream Suite")
+}
@@ -0,0 +1,145 @@
+package logstream
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/emicklei/go-restful"
+	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
+	"github.com/kubesphere/s2ioperator/pkg/config"
+	loghandler "github.com/kubesphere/s2ioperator/pkg/handler/log"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	k8stesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const (
+	namespace = "s2i"
+	runName   = "s2i-run"
+	jobName   = "s2i-run-job"
+	logURL    = "/s2i/v1alpha1/namespaces/" + namespace + "/s2iruns/" + runName + "/log"
+)
+
+var _ = Describe("Testing log stream", func() {
+	var (
+		run     *devopsv1alpha1.S2iRun
+		pod     *corev1.Pod
+		objects []runtime.Object
+	)
+
+	BeforeEach(func() {
+		run = &devopsv1alpha1.S2iRun{
+			ObjectMeta: metav1.ObjectMeta{Name: runName, Namespace: namespace},
+			Status:     devopsv1alpha1.S2iRunStatus{KubernetesJobName: jobName},
+		}
+		pod = &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      jobName + "-abcde",
+				Namespace: namespace,
+				Labels:    map[string]string{"job-name": jobName},
+			},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning},
+		}
+		objects = nil
+	})
+
+	serve := func(token, accept string) *httptest.ResponseRecorder {
+		s := runtime.NewScheme()
+		Expect(scheme.AddToScheme(s)).To(Succeed())
+		Expect(devopsv1alpha1.AddToScheme(s)).To(Succeed())
+		c := fake.NewFakeClientWithScheme(s, append(objects, run)...)
+
+		kubeClient := kubefake.NewSimpleClientset()
+		kubeClient.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+			if review.Spec.Token != "invalid" {
+				review.Status.Authenticated = true
+				review.Status.User = authenticationv1.UserInfo{Username: review.Spec.Token}
+			}
+			return true, review, nil
+		})
+		kubeClient.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+			attributes := review.Spec.ResourceAttributes
+			review.Status.Allowed = review.Spec.User == "developer" && attributes.Verb == "get" &&
+				attributes.Resource == devopsv1alpha1.ResourcePluralS2iRun && attributes.Name == runName
+			return true, review, nil
+		})
+
+		archiver, err := loghandler.NewLogArchiver(config.LogArchiveConfig{Sink: loghandler.SinkConfigMap}, c)
+		Expect(err).ShouldNot(HaveOccurred())
+		ws := new(restful.WebService)
+		ws.Path("/s2i/v1alpha1")
+		NewStreamer(c, kubeClient, archiver).AddToWebService(ws)
+		container := restful.NewContainer()
+		container.Add(ws)
+
+		request := httptest.NewRequest(http.MethodGet, logURL+"?follow=true", nil)
+		if token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+		if accept != "" {
+			request.Header.Set("Accept", accept)
+		}
+		recorder := httptest.NewRecorder()
+		container.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	It("Should reject the request without valid token", func() {
+		objects = append(objects, pod)
+		Expect(serve("", "").Code).To(Equal(http.StatusUnauthorized))
+		Expect(serve("invalid", "").Code).To(Equal(http.StatusUnauthorized))
+	})
+
+	It("Should reject the user who can not get the s2irun", func() {
+		objects = append(objects, pod)
+		Expect(serve("guest", "").Code).To(Equal(http.StatusForbidden))
+	})
+
+	It("Should stream the log of the build pod", func() {
+		objects = append(objects, pod)
+		recorder := serve("developer", "")
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Header().Get("Content-Type")).To(HavePrefix(MIMETextPlain))
+		Expect(recorder.Body.String()).To(Equal("fake logs"))
+	})
+
+	It("Should stream the log of the build pod as server-sent events", func() {
+		objects = append(objects, pod)
+		recorder := serve("developer", MIMEEventStream)
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Header().Get("Content-Type")).To(Equal(MIMEEventStream))
+		Expect(recorder.Body.String()).To(Equal("data: fake logs\n\nevent: end\ndata: \n\n"))
+	})
+
+	It("Should return the archived log if the pod is deleted", func() {
+		objects = append(objects, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: jobName + "-log", Namespace: namespace},
+			BinaryData: map[string][]byte{loghandler.ArchiveDataKey: []byte("archived logs")},
+		})
+		run.Status.LogArchives = []devopsv1alpha1.S2iLogArchive{
+			{KubernetesJobName: jobName, Sink: loghandler.SinkConfigMap, Location: jobName + "-log"},
+		}
+		recorder := serve("developer", "")
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Body.String()).To(Equal("archived logs"))
+	})
+
+	It("Should return not found if the log is neither in the pod nor archived", func() {
+		Expect(serve("developer", "").Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("Should return not found if the s2irun does not exist", func() {
+		run.Name = "other"
+		Expect(serve("developer", "").Code).To(Equal(http.StatusNotFound))
+	})
+})
@@ -0,0 +1,216 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emicklei/go-restful"
+	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
+	"github.com/kubesphere/s2ioperator/pkg/config"
+	"github.com/kubesphere/s2ioperator/pkg/metrics"
+	"golang.org/x/time/rate"
+	"k8s.io/apimachinery/pkg/types"
+	log "k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	LimitBuilder = "s2ibuilder"
+	LimitIP      = "ip"
+
+	// idleTimeout is how long the bucket of a s2ibuilder or ip is kept after its last request
+	idleTimeout = 10 * time.Minute
+)
+
+type bucket struct {
+	limiter  *rate.Limiter
+	qps      float64
+	burst    int
+	lastSeen time.Time
+}
+
+// Limit is the rate and burst of a token bucket, the rate 0 means no limit
+type Limit struct {
+	QPS   float64
+	Burst int
+}
+
+// ParseLimit parses the limit in the annotation, e.g. "qps=0.5,burst=5"
+func ParseLimit(value string) (*Limit, error) {
+	limit := &Limit{}
+	for _, item := range strings.Split(value, ",") {
+		kv := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid rate limit %s, it should be like qps=0.5,burst=5", value)
+		}
+		var err error
+		switch strings.TrimSpace(kv[0]) {
+		case "qps":
+			limit.QPS, err = strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+		case "burst":
+			limit.Burst, err = strconv.Atoi(strings.TrimSpace(kv[1]))
+		default:
+			err = fmt.Errorf("unknown key %s", kv[0])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate limit %s: %v", value, err)
+		}
+	}
+	if limit.QPS < 0 || limit.Burst < 0 {
+		return nil, fmt.Errorf("invalid rate limit %s, the qps and burst should not be negative", value)
+	}
+	if limit.QPS > 0 && limit.Burst == 0 {
+		limit.Burst = 1
+	}
+	return limit, nil
+}
+
+// Limiter limits the rate of the triggers of each s2ibuilder and from each source ip with token buckets
+type Limiter struct {
+	KubeClientSet client.Client
+	cfg           config.RateLimitConfig
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewLimiter(client client.Client, cfg config.RateLimitConfig) *Limiter {
+	return &Limiter{
+		KubeClientSet: client,
+		cfg:           cfg,
+		buckets:       make(map[string]*bucket),
+		now:           time.Now,
+	}
+}
+
+// reservation is a token reserved from a bucket, it is nil if there is no limit
+type reservation struct {
+	*rate.Reservation
+	at time.Time
+}
+
+// reserve reserves a token from the bucket of the key, the token should be given back by cancel if the request
+// is rejected. The bucket is recreated if its limit is changed.
+func (l *Limiter) reserve(key string, limit Limit) *reservation {
+	if limit.QPS <= 0 {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok || b.qps != limit.QPS || b.burst != limit.Burst {
+		b = &bucket{limiter: rate.NewLimiter(rate.Limit(limit.QPS), limit.Burst), qps: limit.QPS, burst: limit.Burst}
+		l.buckets[key] = b
+	}
+	b.lastSeen = now
+	return &reservation{Reservation: b.limiter.ReserveN(now, 1), at: now}
+}
+
+// delay returns the time to wait for the reserved token, it is 0 if the token is available now
+func (r *reservation) delay() time.Duration {
+	if r == nil {
+		return 0
+	}
+	if !r.OK() {
+		return time.Second
+	}
+	return r.DelayFrom(r.at)
+}
+
+// cancel gives the reserved token back to the bucket
+func (r *reservation) cancel() {
+	if r != nil {
+		r.CancelAt(r.at)
+	}
+}
+
+// sweep deletes the idle buckets, it should be called with the lock held
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < idleTimeout {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if now.Sub(b.lastSeen) > idleTimeout {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// builderLimit returns the limit of the s2ibuilder, which is overridden by its annotation. found is false if the
+// s2ibuilder could not be got, the trigger handles it, so that no bucket is kept for the names in the requests.
+func (l *Limiter) builderLimit(namespace, name string) (limit Limit, found bool) {
+	limit = Limit{QPS: l.cfg.BuilderQPS, Burst: l.cfg.BuilderBurst}
+	builder := &devopsv1alpha1.S2iBuilder{}
+	if err := l.KubeClientSet.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: name}, builder); err != nil {
+		return limit, false
+	}
+	if value, ok := builder.Annotations[devopsv1alpha1.TriggerRateLimitAnnotations]; ok {
+		override, err := ParseLimit(value)
+		if err != nil {
+			log.Errorf("ignore the rate limit annotation of S2IBuilder %s/%s: %v", namespace, name, err)
+			return limit, true
+		}
+		return *override, true
+	}
+	return limit, true
+}
+
+// clientIP returns the source ip of the request, X-Forwarded-For is used only if it is trusted
+func (l *Limiter) clientIP(request *http.Request) string {
+	if l.cfg.TrustForwardedFor {
+		if forwarded := request.Header.Get("X-Forwarded-For"); forwarded != "" {
+			return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+	}
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		return request.RemoteAddr
+	}
+	return host
+}
+
+// Filter rejects the trigger requests with 429 if the source ip or the s2ibuilder in the path exceeds its limit,
+// the tokens are taken only if the request is allowed by both limits.
+func (l *Limiter) Filter(request *restful.Request, response *restful.Response, chain *restful.FilterChain) {
+	namespace := request.PathParameter("namespace")
+	name := request.PathParameter("s2ibuilder")
+
+	ip := l.reserve(LimitIP+"/"+l.clientIP(request.Request), Limit{QPS: l.cfg.IPQPS, Burst: l.cfg.IPBurst})
+	if delay := ip.delay(); delay > 0 {
+		ip.cancel()
+		// the s2ibuilder in the path is not checked yet, so that it is not in the labels
+		reject(response, "", "", LimitIP, delay)
+		return
+	}
+	if name != "" {
+		if limit, found := l.builderLimit(namespace, name); found {
+			builder := l.reserve(LimitBuilder+"/"+namespace+"/"+name, limit)
+			if delay := builder.delay(); delay > 0 {
+				builder.cancel()
+				ip.cancel()
+				reject(response, namespace, name, LimitBuilder, delay)
+				return
+			}
+		}
+	}
+	chain.ProcessFilter(request, response)
+}
+
+func reject(response *restful.Response, namespace, name, limit string, delay time.Duration) {
+	metrics.TriggerThrottled.WithLabelValues(namespace, name, limit).Inc()
+	response.AddHeader("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+	response.WriteErrorString(http.StatusTooManyRequests, fmt.Sprintf("too many triggers of the %s, retry later", limit))
+}
@@ -0,0 +1,140 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/emicklei/go-restful"
+	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
+	"github.com/kubesphere/s2ioperator/pkg/client/clientset/versioned/scheme"
+	"github.com/kubesphere/s2ioperator/pkg/config"
+	"github.com/kubesphere/s2ioperator/pkg/metrics"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Testing rate limit of triggers", func() {
+	var (
+		limiter   *Limiter
+		container *restful.Container
+		now       time.Time
+	)
+
+	newBuilder := func(name, limit string) *devopsv1alpha1.S2iBuilder {
+		builder := &devopsv1alpha1.S2iBuilder{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "s2i"}}
+		if limit != "" {
+			builder.Annotations = map[string]string{devopsv1alpha1.TriggerRateLimitAnnotations: limit}
+		}
+		return builder
+	}
+
+	setup := func(cfg config.RateLimitConfig) {
+		c := fake.NewFakeClientWithScheme(scheme.Scheme,
+			newBuilder("default", ""),
+			newBuilder("unlimited", "qps=0"),
+			newBuilder("strict", "qps=0.1,burst=1"))
+		limiter = NewLimiter(c, cfg)
+		now = time.Now()
+		limiter.now = func() time.Time { return now }
+
+		ws := new(restful.WebService)
+		ws.Path("/trigger").Filter(limiter.Filter)
+		ws.Route(ws.POST("/namespaces/{namespace}/s2ibuilders/{s2ibuilder}").To(func(request *restful.Request, response *restful.Response) {
+			response.WriteHeader(http.StatusCreated)
+		}))
+		container = restful.NewContainer()
+		container.Add(ws)
+	}
+
+	trigger := func(builder, ip string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, "/trigger/namespaces/s2i/s2ibuilders/"+builder, nil)
+		request.RemoteAddr = ip + ":12345"
+		request.Header.Set("X-Forwarded-For", "10.0.0.1")
+		recorder := httptest.NewRecorder()
+		container.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	It("Should parse the limit in the annotation", func() {
+		limit, err := ParseLimit("qps=0.5, burst=5")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(*limit).To(Equal(Limit{QPS: 0.5, Burst: 5}))
+		limit, err = ParseLimit("qps=2")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(limit.Burst).To(Equal(1))
+		for _, invalid := range []string{"", "qps", "qps=fast", "rate=1", "qps=-1"} {
+			_, err = ParseLimit(invalid)
+			Expect(err).Should(HaveOccurred(), invalid)
+		}
+	})
+
+	It("Should limit the triggers from a source ip", func() {
+		setup(config.RateLimitConfig{IPQPS: 1, IPBurst: 2})
+		before := testutil.ToFloat64(metrics.TriggerThrottled.WithLabelValues("", "", LimitIP))
+		Expect(trigger("default", "192.168.0.1").Code).To(Equal(http.StatusCreated))
+		Expect(trigger("default", "192.168.0.1").Code).To(Equal(http.StatusCreated))
+		recorder := trigger("default", "192.168.0.1")
+		Expect(recorder.Code).To(Equal(http.StatusTooManyRequests))
+		Expect(recorder.Header().Get("Retry-After")).To(Equal("1"))
+		Expect(testutil.ToFloat64(metrics.TriggerThrottled.WithLabelValues("", "", LimitIP))).To(Equal(before + 1))
+
+		// other ips are not limited, and X-Forwarded-For is not trusted by default
+		Expect(trigger("default", "192.168.0.2").Code).To(Equal(http.StatusCreated))
+
+		now = now.Add(time.Second)
+		Expect(trigger("default", "192.168.0.1").Code).To(Equal(http.StatusCreated))
+	})
+
+	It("Should limit the triggers of a s2ibuilder with the annotation overriding the config", func() {
+		setup(config.RateLimitConfig{BuilderQPS: 1, BuilderBurst: 1})
+		Expect(trigger("default", "192.168.0.1").Code).To(Equal(http.StatusCreated))
+		Expect(trigger("default", "192.168.0.2").Code).To(Equal(http.StatusTooManyRequests))
+
+		for i := 0; i < 5; i++ {
+			Expect(trigger("unlimited", "192.168.0.1").Code).To(Equal(http.StatusCreated))
+		}
+
+		Expect(trigger("strict", "192.168.0.1").Code).To(Equal(http.StatusCreated))
+		now = now.Add(time.Second)
+		recorder := trigger("strict", "192.168.0.1")
+		Expect(recorder.Code).To(Equal(http.StatusTooManyRequests))
+		Expect(recorder.Header().Get("Retry-After")).To(Equal("9"))
+	})
+
+	It("Should not take the token of the source ip if the s2ibuilder is limited", func() {
+		setup(config.RateLimitConfig{IPQPS: 1, IPBurst: 1, BuilderQPS: 1, BuilderBurst: 1})
+		Expect(trigger("default", "192.168.0.1").Code).To(Equal(http.StatusCreated))
+		Expect(trigger("default", "192.168.0.2").Code).To(Equal(http.StatusTooManyRequests))
+		Expect(trigger("unlimited", "192.168.0.2").Code).To(Equal(http.StatusCreated))
+	})
+
+	It("Should not keep the buckets of the s2ibuilders which do not exist", func() {
+		setup(config.RateLimitConfig{BuilderQPS: 1, BuilderBurst: 1})
+		before := testutil.ToFloat64(metrics.TriggerThrottled.WithLabelValues("s2i", "missing", LimitBuilder))
+		for i := 0; i < 3; i++ {
+			Expect(trigger("missing", "192.168.0.1").Code).To(Equal(http.StatusCreated))
+		}
+		Expect(limiter.buckets).To(BeEmpty())
+		Expect(testutil.ToFloat64(metrics.TriggerThrottled.WithLabelValues("s2i", "missing", LimitBuilder))).To(Equal(before))
+	})
+
+	It("Should take the source ip from X-Forwarded-For if it is trusted", func() {
+		setup(config.RateLimitConfig{IPQPS: 1, IPBurst: 1, TrustForwardedFor: true})
+		Expect(trigger("default", "192.168.0.1").Code).To(Equal(http.StatusCreated))
+		Expect(trigger("default", "192.168.0.2").Code).To(Equal(http.StatusTooManyRequests))
+	})
+
+	It("Should delete the idle buckets", func() {
+		setup(config.RateLimitConfig{IPQPS: 1, IPBurst: 1})
+		trigger("default", "192.168.0.1")
+		Expect(limiter.buckets).To(HaveLen(1))
+		now = now.Add(2 * idleTimeout)
+		trigger("default", "192.168.0.2")
+		Expect(limiter.buckets).To(HaveLen(1))
+		Expect(limiter.buckets).To(HaveKey(LimitIP + "/192.168.0.2"))
+	})
+})
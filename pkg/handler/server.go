@@ -17,24 +17,177 @@ limitations under the License.
 package handler
 
 import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
 	"github.com/emicklei/go-restful"
+	"github.com/emicklei/go-restful-openapi"
+	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
+	"github.com/kubesphere/s2ioperator/pkg/config"
+	"github.com/kubesphere/s2ioperator/pkg/handler/api"
 	"github.com/kubesphere/s2ioperator/pkg/handler/general"
 	"github.com/kubesphere/s2ioperator/pkg/handler/github"
-	"net/http"
+	loghandler "github.com/kubesphere/s2ioperator/pkg/handler/log"
+	"github.com/kubesphere/s2ioperator/pkg/handler/logstream"
+	"github.com/kubesphere/s2ioperator/pkg/handler/ratelimit"
+	"github.com/kubesphere/s2ioperator/pkg/metrics"
+	"k8s.io/client-go/kubernetes"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	log "github.com/golang/glog"
 )
 
-func Run(kubeClientset client.Client) {
-	container := restful.DefaultContainer
+const (
+	DefaultAddress         = ":8081"
+	DefaultMaxRequestBytes = 25 * 1024 * 1024
+	DefaultShutdownTimeout = 30 * time.Second
+
+	// maxHeaderBytes is the size limit of the request headers
+	maxHeaderBytes = 1 << 20
+)
+
+// Server is the http server of the triggers, the api and the log of s2iruns. It is added to the manager
+// and stopped gracefully with it.
+type Server struct {
+	cfg       config.TriggerConfig
+	container *restful.Container
+	// serving is 1 from the server starts listening until it is shutting down
+	serving int32
+}
+
+func NewServer(cfg config.TriggerConfig, kubeClientset client.Client, kubeClient kubernetes.Interface, logArchiver loghandler.LogArchiver) *Server {
+	if cfg.Address == "" {
+		cfg.Address = DefaultAddress
+	}
+	if cfg.MaxRequestBytes <= 0 {
+		cfg.MaxRequestBytes = DefaultMaxRequestBytes
+	}
+	if cfg.ShutdownTimeout <= 0 {
+		cfg.ShutdownTimeout = DefaultShutdownTimeout
+	}
+	container := restful.NewContainer()
+	container.Filter(limitRequestBody(cfg.MaxRequestBytes))
+
+	//the triggers are rate limited by s2ibuilder and source ip
+	limiter := ratelimit.NewLimiter(kubeClientset, cfg.RateLimit)
 
 	//register general webhook handler, which can handle any handle request from any server.
-	container.Add(general.NewTrigger(kubeClientset).WebService())
+	container.Add(general.NewTrigger(kubeClientset).WebService().
+		Filter(countTriggerRequests(devopsv1alpha1.TriggerSourceGeneral)).Filter(limiter.Filter))
 
 	//register  github webhook handler
-	container.Add(github.NewTrigger(kubeClientset).WebService())
+	container.Add(github.NewTrigger(kubeClientset).WebService().
+		Filter(countTriggerRequests(devopsv1alpha1.TriggerSourceGithub)).Filter(limiter.Filter))
+
+	//register the read-only api of s2i resources and the log handler, which are authorized by the permissions
+	//of the users in the cluster
+	ws := api.NewHandler(kubeClientset, kubeClient).WebService()
+	logstream.NewStreamer(kubeClientset, kubeClient, logArchiver).AddToWebService(ws)
+	container.Add(ws)
+
+	//serve the openapi document of all the web services
+	container.Add(restfulspec.NewOpenAPIService(restfulspec.Config{
+		WebServices: container.RegisteredWebServices(),
+		APIPath:     "/apidocs.json",
+	}))
+
+	return &Server{cfg: cfg, container: container}
+}
+
+// limitRequestBody rejects the requests whose body is larger than maxBytes
+func limitRequestBody(maxBytes int64) restful.FilterFunction {
+	return func(request *restful.Request, response *restful.Response, chain *restful.FilterChain) {
+		if request.Request.ContentLength > maxBytes {
+			response.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		if request.Request.Body != nil {
+			request.Request.Body = http.MaxBytesReader(response.ResponseWriter, request.Request.Body, maxBytes)
+		}
+		chain.ProcessFilter(request, response)
+	}
+}
+
+// countTriggerRequests counts the trigger requests of the provider by the status code of the responses
+func countTriggerRequests(provider string) restful.FilterFunction {
+	return func(request *restful.Request, response *restful.Response, chain *restful.FilterChain) {
+		chain.ProcessFilter(request, response)
+		metrics.TriggerRequests.WithLabelValues(provider, strconv.Itoa(response.StatusCode())).Inc()
+	}
+}
+
+// NeedLeaderElection returns false, so that the server runs in all the replicas of the operator
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
+
+// ReadyCheck is the readiness check of the server, it fails until the server is listening and after it starts
+// shutting down, so that the triggers are sent to the other replicas
+func (s *Server) ReadyCheck(_ *http.Request) error {
+	if atomic.LoadInt32(&s.serving) == 0 {
+		return errors.New("the trigger server is not serving")
+	}
+	return nil
+}
+
+// Start serves until the context is done, then the in-flight requests are waited for at most ShutdownTimeout.
+// The context of the requests are canceled with the context, so that the log streams are closed.
+func (s *Server) Start(ctx context.Context) error {
+	server := &http.Server{
+		Addr:           s.cfg.Address,
+		Handler:        s.container,
+		ReadTimeout:    s.cfg.ReadTimeout,
+		WriteTimeout:   s.cfg.WriteTimeout,
+		IdleTimeout:    s.cfg.IdleTimeout,
+		MaxHeaderBytes: maxHeaderBytes,
+		BaseContext: func(net.Listener) context.Context {
+			return ctx
+		},
+	}
+	if s.cfg.CertFile != "" || s.cfg.KeyFile != "" {
+		reloader, err := newCertReloader(s.cfg.CertFile, s.cfg.KeyFile, s.cfg.ClientCAFile)
+		if err != nil {
+			return err
+		}
+		server.TLSConfig = reloader.TLSConfig()
+	}
+
+	listener, err := net.Listen("tcp", s.cfg.Address)
+	if err != nil {
+		return err
+	}
+	atomic.StoreInt32(&s.serving, 1)
+	defer atomic.StoreInt32(&s.serving, 0)
+	errCh := make(chan error, 1)
+	go func() {
+		if server.TLSConfig != nil {
+			log.Infof("start listening on %s in TLS", s.cfg.Address)
+			errCh <- server.ServeTLS(listener, "", "")
+		} else {
+			log.Infof("start listening on %s", s.cfg.Address)
+			errCh <- server.Serve(listener)
+		}
+	}()
 
-	log.Info("start listening on localhost:8081")
-	log.Fatal(http.ListenAndServe(":8081", nil))
+	select {
+	case err = <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+	log.Info("shutting down the trigger server")
+	atomic.StoreInt32(&s.serving, 0)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.cfg.ShutdownTimeout)
+	defer cancel()
+	if err = server.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err = <-errCh; err != http.ErrServerClosed {
+		return err
+	}
+	return nil
 }
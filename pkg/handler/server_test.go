@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
+	"github.com/kubesphere/s2ioperator/pkg/client/clientset/versioned/scheme"
+	"github.com/kubesphere/s2ioperator/pkg/config"
+	"github.com/kubesphere/s2ioperator/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func writeCert(t *testing.T, dir, name string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	if err = ioutil.WriteFile(filepath.Join(dir, "tls.crt"), certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "tls.key"), keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func commonName(t *testing.T, cert *tls.Certificate) string {
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed.Subject.CommonName
+}
+
+func TestCertReloader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trigger-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+
+	writeCert(t, dir, "first")
+	reloader, err := newCertReloader(certFile, keyFile, certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	reloader.now = func() time.Time { return now }
+	if config := reloader.TLSConfig(); config.ClientAuth != tls.RequireAndVerifyClientCert {
+		t.Errorf("client certificate should be required if the client CA is set")
+	}
+
+	writeCert(t, dir, "second")
+	// make sure the modification time is changed on the file systems of low resolution
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, later, later)
+	cert, _ := reloader.GetCertificate(nil)
+	if name := commonName(t, cert); name != "first" {
+		t.Errorf("the certificate should not be checked within the reload interval, got %s", name)
+	}
+
+	now = now.Add(reloadInterval)
+	cert, _ = reloader.GetCertificate(nil)
+	if name := commonName(t, cert); name != "second" {
+		t.Errorf("the certificate should be reloaded after it is changed, got %s", name)
+	}
+	config, _ := reloader.TLSConfig().GetConfigForClient(nil)
+	if len(config.ClientCAs.Subjects()) != 1 {
+		t.Errorf("the client CA should be reloaded")
+	}
+
+	// the loaded certificate is kept if the new one is invalid
+	ioutil.WriteFile(keyFile, []byte("invalid"), 0600)
+	later = later.Add(time.Minute)
+	os.Chtimes(keyFile, later, later)
+	now = now.Add(reloadInterval)
+	cert, _ = reloader.GetCertificate(nil)
+	if name := commonName(t, cert); name != "second" {
+		t.Errorf("the loaded certificate should be kept, got %s", name)
+	}
+}
+
+func newTestServer(cfg config.TriggerConfig) *Server {
+	c := fake.NewFakeClientWithScheme(scheme.Scheme)
+	return NewServer(cfg, c, kubefake.NewSimpleClientset(), nil)
+}
+
+func TestLimitRequestBody(t *testing.T) {
+	server := newTestServer(config.TriggerConfig{MaxRequestBytes: 16})
+	request := httptest.NewRequest(http.MethodPost, "/s2itrigger/v1alpha1/general/namespaces/s2i/s2ibuilders/s2i-b?secretCode=code",
+		strings.NewReader(strings.Repeat("x", 32)))
+	request.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	server.container.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status %d, got %d", http.StatusRequestEntityTooLarge, recorder.Code)
+	}
+}
+
+func TestServerShutdown(t *testing.T) {
+	server := newTestServer(config.TriggerConfig{Address: "127.0.0.1:0", ShutdownTimeout: time.Second})
+	if server.ReadyCheck(nil) == nil {
+		t.Errorf("the server should not be ready before it is started")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.Start(ctx)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	if err := server.ReadyCheck(nil); err != nil {
+		t.Errorf("the server should be ready after it is started, got %v", err)
+	}
+	cancel()
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Errorf("the server should be shut down gracefully, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("the server is not shut down")
+	}
+	if server.ReadyCheck(nil) == nil {
+		t.Errorf("the server should not be ready after it is shut down")
+	}
+	if server.NeedLeaderElection() {
+		t.Errorf("the server should run in all the replicas")
+	}
+}
+
+func TestCountTriggerRequests(t *testing.T) {
+	server := newTestServer(config.TriggerConfig{})
+	counter := metrics.TriggerRequests.WithLabelValues(devopsv1alpha1.TriggerSourceGeneral, "500")
+	before := testutil.ToFloat64(counter)
+	// the s2ibuilder does not exist
+	request := httptest.NewRequest(http.MethodGet, "/s2itrigger/v1alpha1/general/namespaces/s2i/s2ibuilders/s2i-b?secretCode=code", nil)
+	server.container.ServeHTTP(httptest.NewRecorder(), request)
+	if value := testutil.ToFloat64(counter); value != before+1 {
+		t.Errorf("expected the trigger request to be counted, got %v", value-before)
+	}
+}
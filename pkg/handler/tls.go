@@ -0,0 +1,166 @@
+/*
+Copyright 2019 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/golang/glog"
+)
+
+// reloadInterval is the minimal interval of checking whether the certificate files are changed
+const reloadInterval = 10 * time.Second
+
+// certReloader keeps the certificate and the client CA loaded from the files, they are reloaded in the
+// handshakes after the files are changed, e.g. the secret mounted in the pod is renewed by cert-manager.
+type certReloader struct {
+	certFile     string
+	keyFile      string
+	clientCAFile string
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTimes  []time.Time
+	checkTime time.Time
+	now       func() time.Time
+}
+
+func newCertReloader(certFile, keyFile, clientCAFile string) (*certReloader, error) {
+	r := &certReloader{
+		certFile:     certFile,
+		keyFile:      keyFile,
+		clientCAFile: clientCAFile,
+		now:          time.Now,
+	}
+	modTimes, err := r.stat()
+	if err != nil {
+		return nil, err
+	}
+	if err = r.load(modTimes); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certReloader) files() []string {
+	files := []string{r.certFile, r.keyFile}
+	if r.clientCAFile != "" {
+		files = append(files, r.clientCAFile)
+	}
+	return files
+}
+
+func (r *certReloader) stat() ([]time.Time, error) {
+	var modTimes []time.Time
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		modTimes = append(modTimes, info.ModTime())
+	}
+	return modTimes, nil
+}
+
+// load loads the files, it should be called with the lock held
+func (r *certReloader) load(modTimes []time.Time) error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	var clientCAs *x509.CertPool
+	if r.clientCAFile != "" {
+		data, err := ioutil.ReadFile(r.clientCAFile)
+		if err != nil {
+			return err
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(data) {
+			return fmt.Errorf("no certificate is found in the client CA file %s", r.clientCAFile)
+		}
+	}
+	r.cert = &cert
+	r.clientCAs = clientCAs
+	r.modTimes = modTimes
+	r.checkTime = r.now()
+	return nil
+}
+
+// reload reloads the files if they are changed, the loaded ones are kept if the new ones are invalid
+func (r *certReloader) reload() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.now().Sub(r.checkTime) < reloadInterval {
+		return
+	}
+	r.checkTime = r.now()
+	modTimes, err := r.stat()
+	if err != nil {
+		log.Errorf("failed to check the certificate files: %v", err)
+		return
+	}
+	changed := false
+	for i := range modTimes {
+		if !modTimes[i].Equal(r.modTimes[i]) {
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+	if err = r.load(modTimes); err != nil {
+		log.Errorf("failed to reload the certificate files, keep the loaded ones: %v", err)
+		return
+	}
+	log.Info("reloaded the certificate files")
+}
+
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.reload()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cert, nil
+}
+
+// TLSConfig returns the tls config which uses the latest certificate and client CA in the handshakes, the
+// clients are required to present certificates if the client CA is set.
+func (r *certReloader) TLSConfig() *tls.Config {
+	config := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: r.GetCertificate,
+	}
+	if r.clientCAFile != "" {
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	base := config.Clone()
+	config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		r.reload()
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		c := base.Clone()
+		c.ClientCAs = r.clientCAs
+		return c, nil
+	}
+	return config
+}
@@ -1,129 +1,124 @@
 package metrics
 
 import (
-	"context"
+	"sync"
+
 	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
 	"github.com/prometheus/client_golang/prometheus"
-	"k8s.io/api/core/v1"
-	log "k8s.io/klog"
-	"sigs.k8s.io/controller-runtime/pkg/client"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
-	"time"
 )
 
 var (
 	s2iSubsystem = "s2i"
 
-	S2iRunActive = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	S2iRunTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Subsystem: s2iSubsystem,
-		Name:      "s2irun_active",
-		Help:      "Number of s2irun running",
-	}, []string{"namespace"})
+		Name:      "s2irun_total",
+		Help:      "Number of s2irun finished",
+	}, []string{"namespace", "s2ibuilder", "result", "trigger"})
+
+	S2iRunQueueSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Subsystem: s2iSubsystem,
+		Name:      "s2irun_queue_seconds",
+		Help:      "Time from the creation of s2irun to the start of its build",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 12),
+	}, []string{"namespace", "s2ibuilder"})
 
-	S2iRunFailed = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	S2iRunDurationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Subsystem: s2iSubsystem,
-		Name:      "s2irun_failed",
-		Help:      "Number of s2irun failed",
-	}, []string{"namespace"})
+		Name:      "s2irun_duration_seconds",
+		Help:      "Time from the start to the completion of the build of s2irun",
+		Buckets:   prometheus.ExponentialBuckets(10, 2, 10),
+	}, []string{"namespace", "s2ibuilder", "result"})
 
-	S2iRunSucceed = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	S2iRunImageSizeBytes = prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Subsystem: s2iSubsystem,
-		Name:      "s2irun_succeed",
-		Help:      "Number of s2irun succeed",
-	}, []string{"namespace"})
+		Name:      "s2irun_image_size_bytes",
+		Help:      "Size of the image built by s2irun",
+		Buckets:   prometheus.ExponentialBuckets(16*1024*1024, 2, 10),
+	}, []string{"namespace", "s2ibuilder"})
+
+	S2iRunInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Subsystem: s2iSubsystem,
+		Name:      "s2irun_in_flight",
+		Help:      "Number of s2irun running",
+	}, []string{"namespace", "s2ibuilder"})
 
-	S2iRunUnknown = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	TriggerRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Subsystem: s2iSubsystem,
-		Name:      "s2irun_unknown",
-		Help:      "Number of s2irun which status unknown",
-	}, []string{"namespace"})
+		Name:      "trigger_requests_total",
+		Help:      "Number of trigger requests by provider and status code",
+	}, []string{"provider", "code"})
 
-	S2iBuilderCreated = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	TriggerThrottled = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Subsystem: s2iSubsystem,
-		Name:      "s2ibuilder_created",
-		Help:      "Number of s2ibuilder",
-	}, []string{"namespace"})
+		Name:      "trigger_throttled_total",
+		Help:      "Number of trigger requests rejected by the rate limit of s2ibuilder or source ip, the s2ibuilder is empty for source ip",
+	}, []string{"namespace", "s2ibuilder", "limit"})
 )
 
+// inFlight is the s2ibuilders of the running s2iruns, a s2irun is counted once however many times it is reconciled
+var inFlight = struct {
+	sync.Mutex
+	runs map[types.NamespacedName]string
+}{runs: make(map[types.NamespacedName]string)}
+
 func init() {
 	// register the metrics with prometheus registry
-	metrics.Registry.MustRegister(S2iRunActive)
-	metrics.Registry.MustRegister(S2iRunFailed)
-	metrics.Registry.MustRegister(S2iRunSucceed)
-	metrics.Registry.MustRegister(S2iRunUnknown)
-	metrics.Registry.MustRegister(S2iBuilderCreated)
+	metrics.Registry.MustRegister(S2iRunTotal)
+	metrics.Registry.MustRegister(S2iRunQueueSeconds)
+	metrics.Registry.MustRegister(S2iRunDurationSeconds)
+	metrics.Registry.MustRegister(S2iRunImageSizeBytes)
+	metrics.Registry.MustRegister(S2iRunInFlight)
+	metrics.Registry.MustRegister(TriggerRequests)
+	metrics.Registry.MustRegister(TriggerThrottled)
 }
 
-func CollectS2iMetrics(k8sclient client.Client) {
-	var err error
-
-	for {
-		namespacelist := new(v1.NamespaceList)
-		err = k8sclient.List(context.TODO(), namespacelist, client.InNamespace(""))
-		if err != nil {
-			continue
-		}
-
-		for _, namespace := range namespacelist.Items {
-			// set s2ibuilder metrics
-			err = SetS2iBuilderMetrics(k8sclient, namespace)
-			if err != nil {
-				log.Error(err)
-				continue
-			}
-			// set s2irun metrics
-			err = SetS2iRunMetrics(k8sclient, namespace)
-			if err != nil {
-				log.Error(err)
-				continue
-			}
-
-		}
-		time.Sleep(15 * time.Second)
+// RecordRunStatus updates the metrics of the s2irun according to the change from the origin status to the
+// status saved by the reconciler.
+func RecordRunStatus(origin, run *devopsv1alpha1.S2iRun) {
+	builder := run.Spec.BuilderName
+	if origin.Status.StartTime == nil && run.Status.StartTime != nil {
+		S2iRunQueueSeconds.WithLabelValues(run.Namespace, builder).
+			Observe(run.Status.StartTime.Sub(run.CreationTimestamp.Time).Seconds())
 	}
 
-}
+	setInFlight(types.NamespacedName{Namespace: run.Namespace, Name: run.Name}, builder,
+		run.Status.RunState == devopsv1alpha1.Running)
 
-// Setup s2ibuilder metrics
-func SetS2iBuilderMetrics(k8sclient client.Client, namespace v1.Namespace) error {
-	s2iBuilderList := new(devopsv1alpha1.S2iBuilderList)
-	err := k8sclient.List(context.TODO(), s2iBuilderList, client.InNamespace(namespace.Name))
-	if err != nil {
-		log.Error(err)
-		return err
+	if isFinished(origin.Status.RunState) || !isFinished(run.Status.RunState) {
+		return
+	}
+	result := string(run.Status.RunState)
+	S2iRunTotal.WithLabelValues(run.Namespace, builder, result, run.TriggerSource()).Inc()
+	if run.Status.StartTime != nil && run.Status.CompletionTime != nil {
+		S2iRunDurationSeconds.WithLabelValues(run.Namespace, builder, result).
+			Observe(run.Status.CompletionTime.Sub(run.Status.StartTime.Time).Seconds())
+	}
+	if run.Status.S2iBuildResult != nil && run.Status.S2iBuildResult.ImageSize > 0 {
+		S2iRunImageSizeBytes.WithLabelValues(run.Namespace, builder).Observe(float64(run.Status.S2iBuildResult.ImageSize))
 	}
-	S2iBuilderCreated.WithLabelValues(namespace.Name).Set(float64(len(s2iBuilderList.Items)))
-	return nil
 }
 
-// Setup s2irun metrics
-func SetS2iRunMetrics(k8sclient client.Client, namespace v1.Namespace) error {
-	s2iRunList := new(devopsv1alpha1.S2iRunList)
-	err := k8sclient.List(context.TODO(), s2iRunList, client.InNamespace(namespace.Name))
-	if err != nil {
-		return err
-	}
+// ForgetRun removes the deleted s2irun from the running s2iruns
+func ForgetRun(key types.NamespacedName) {
+	setInFlight(key, "", false)
+}
 
-	var successfulCount = 0
-	var runningCount = 0
-	var failedCount = 0
-	var unknownCount = 0
-	for _, s2irun := range s2iRunList.Items {
-		switch s2irun.Status.RunState {
-		case devopsv1alpha1.Successful:
-			successfulCount = successfulCount + 1
-		case devopsv1alpha1.Failed:
-			failedCount = failedCount + 1
-		case devopsv1alpha1.Running:
-			runningCount = runningCount + 1
-		default:
-			unknownCount = unknownCount + 1
-		}
+func setInFlight(key types.NamespacedName, builder string, running bool) {
+	inFlight.Lock()
+	defer inFlight.Unlock()
+	counted, ok := inFlight.runs[key]
+	if running && !ok {
+		inFlight.runs[key] = builder
+		S2iRunInFlight.WithLabelValues(key.Namespace, builder).Inc()
+	} else if !running && ok {
+		delete(inFlight.runs, key)
+		S2iRunInFlight.WithLabelValues(key.Namespace, counted).Dec()
 	}
+}
 
-	S2iRunSucceed.WithLabelValues(namespace.Name).Set(float64(successfulCount))
-	S2iRunActive.WithLabelValues(namespace.Name).Set(float64(runningCount))
-	S2iRunFailed.WithLabelValues(namespace.Name).Set(float64(failedCount))
-	S2iRunUnknown.WithLabelValues(namespace.Name).Set(float64(unknownCount))
-	return nil
+func isFinished(state devopsv1alpha1.RunState) bool {
+	return state == devopsv1alpha1.Successful || state == devopsv1alpha1.Failed
 }
@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestRecordRunStatus(t *testing.T) {
+	created := metav1.NewTime(time.Now().Add(-time.Hour))
+	started := metav1.NewTime(created.Add(30 * time.Second))
+	completed := metav1.NewTime(started.Add(5 * time.Minute))
+
+	run := &devopsv1alpha1.S2iRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "s2ir",
+			Namespace:         "metrics",
+			CreationTimestamp: created,
+			Annotations:       map[string]string{devopsv1alpha1.TriggerSourceAnnotation: devopsv1alpha1.TriggerSourceGithub},
+		},
+		Spec: devopsv1alpha1.S2iRunSpec{BuilderName: "s2ib"},
+	}
+	inFlight := S2iRunInFlight.WithLabelValues("metrics", "s2ib")
+	total := S2iRunTotal.WithLabelValues("metrics", "s2ib", devopsv1alpha1.Successful, devopsv1alpha1.TriggerSourceGithub)
+
+	running := run.DeepCopy()
+	running.Status.RunState = devopsv1alpha1.Running
+	running.Status.StartTime = &started
+	RecordRunStatus(run, running)
+	// the running s2irun reconciled again is counted once
+	RecordRunStatus(running, running)
+	if value := testutil.ToFloat64(inFlight); value != 1 {
+		t.Errorf("expected 1 s2irun in flight, got %v", value)
+	}
+
+	successful := running.DeepCopy()
+	successful.Status.RunState = devopsv1alpha1.Successful
+	successful.Status.CompletionTime = &completed
+	successful.Status.S2iBuildResult = &devopsv1alpha1.S2iBuildResult{ImageSize: 64 * 1024 * 1024}
+	RecordRunStatus(running, successful)
+	RecordRunStatus(successful, successful)
+	if value := testutil.ToFloat64(inFlight); value != 0 {
+		t.Errorf("expected no s2irun in flight, got %v", value)
+	}
+	if value := testutil.ToFloat64(total); value != 1 {
+		t.Errorf("expected 1 s2irun finished, got %v", value)
+	}
+	for name, collector := range map[string]prometheus.Collector{
+		"queue time":     S2iRunQueueSeconds,
+		"build duration": S2iRunDurationSeconds,
+		"image size":     S2iRunImageSizeBytes,
+	} {
+		if n := testutil.CollectAndCount(collector); n != 1 {
+			t.Errorf("expected the %s to be observed, got %d series", name, n)
+		}
+	}
+
+	// the deleted s2irun is not in flight any more
+	RecordRunStatus(run, running)
+	ForgetRun(types.NamespacedName{Namespace: run.Namespace, Name: run.Name})
+	if value := testutil.ToFloat64(inFlight); value != 0 {
+		t.Errorf("expected no s2irun in flight after it is deleted, got %v", value)
+	}
+}
+
+func TestTriggerSource(t *testing.T) {
+	run := &devopsv1alpha1.S2iRun{}
+	if source := run.TriggerSource(); source != devopsv1alpha1.TriggerSourceManual {
+		t.Errorf("expected source %s, got %s", devopsv1alpha1.TriggerSourceManual, source)
+	}
+	run.Annotations = map[string]string{devopsv1alpha1.RerunOfAnnotation: "origin"}
+	if source := run.TriggerSource(); source != devopsv1alpha1.TriggerSourceRerun {
+		t.Errorf("expected source %s, got %s", devopsv1alpha1.TriggerSourceRerun, source)
+	}
+}
@@ -0,0 +1,172 @@
+package scope
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/kubesphere/s2ioperator/pkg/config"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/kubernetes"
+	log "k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// DefaultResyncInterval is how often the namespaces matching the selector are listed
+const DefaultResyncInterval = 30 * time.Second
+
+// Scope is the namespaces the operator serves. The objects in the other namespaces are not cached, the controllers
+// never see them and the trigger server takes them as not found.
+type Scope struct {
+	// namespaces is nil if all the namespaces are served
+	namespaces sets.String
+	selector   labels.Selector
+	kubeClient kubernetes.Interface
+	interval   time.Duration
+}
+
+// New resolves the namespaces of the config, the namespaces which match the selector are listed once, Start
+// watches them afterwards.
+func New(ctx context.Context, kubeClient kubernetes.Interface, cfg config.ScopeConfig) (*Scope, error) {
+	s := &Scope{kubeClient: kubeClient, interval: DefaultResyncInterval}
+	switch {
+	case len(cfg.Namespaces) != 0:
+		s.namespaces = sets.NewString(cfg.Namespaces...)
+	case cfg.NamespaceSelector != "":
+		selector, err := labels.Parse(cfg.NamespaceSelector)
+		if err != nil {
+			return nil, err
+		}
+		s.selector = selector
+		if s.namespaces, err = s.listNamespaces(ctx); err != nil {
+			return nil, err
+		}
+		if s.namespaces.Len() == 0 {
+			log.Warningf("no namespace matches the selector %s", selector)
+		}
+	}
+	return s, nil
+}
+
+func (s *Scope) listNamespaces(ctx context.Context) (sets.String, error) {
+	list, err := s.kubeClient.CoreV1().Namespaces().List(ctx, metav1.ListOptions{LabelSelector: s.selector.String()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the namespaces of %s: %v", s.selector, err)
+	}
+	namespaces := sets.NewString()
+	for _, namespace := range list.Items {
+		namespaces.Insert(namespace.Name)
+	}
+	return namespaces, nil
+}
+
+// All returns true if all the namespaces are served
+func (s *Scope) All() bool {
+	return s.namespaces == nil
+}
+
+// Namespaces returns the sorted names of the namespaces served, it is nil if all the namespaces are served
+func (s *Scope) Namespaces() []string {
+	if s.All() {
+		return nil
+	}
+	return s.namespaces.List()
+}
+
+// Contains returns true if the objects in the namespace are served, the cluster-scoped objects are always served
+func (s *Scope) Contains(namespace string) bool {
+	return s.All() || namespace == "" || s.namespaces.Has(namespace)
+}
+
+// NewCache returns the cache of the manager, which caches the objects in the namespaces only. The cluster-scoped
+// objects, e.g. s2ibuildertemplates, could not be read from it, they should be read by the client without cache.
+func (s *Scope) NewCache() cache.NewCacheFunc {
+	if s.All() {
+		return cache.New
+	}
+	return cache.MultiNamespacedCacheBuilder(s.Namespaces())
+}
+
+// NeedLeaderElection returns false, so that all the replicas of the operator watch the namespaces
+func (s *Scope) NeedLeaderElection() bool {
+	return false
+}
+
+// Start lists the namespaces which match the selector every interval. The cache could not be changed after the
+// manager is started, so that an error is returned to stop the operator to be restarted if they are changed.
+func (s *Scope) Start(ctx context.Context) error {
+	if s.selector == nil {
+		<-ctx.Done()
+		return nil
+	}
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			namespaces, err := s.listNamespaces(ctx)
+			if err != nil {
+				log.Error(err)
+				continue
+			}
+			if !namespaces.Equal(s.namespaces) {
+				return fmt.Errorf("the namespaces of %s are changed from %v to %v, the operator is restarted to serve them",
+					s.selector, s.namespaces.List(), namespaces.List())
+			}
+		}
+	}
+}
+
+// NewClient returns a client which takes the objects out of the scope as not found, the client of the manager
+// returns an error of unknown namespace for them if the cache is restricted.
+func NewClient(c client.Client, s *Scope) client.Client {
+	if s.All() {
+		return c
+	}
+	return &scopedClient{Client: c, scope: s}
+}
+
+type scopedClient struct {
+	client.Client
+	scope *Scope
+}
+
+func (c *scopedClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	if !c.scope.Contains(key.Namespace) {
+		return apierrors.NewNotFound(c.groupResource(obj), key.Name)
+	}
+	return c.Client.Get(ctx, key, obj)
+}
+
+func (c *scopedClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	listOpts := client.ListOptions{}
+	listOpts.ApplyOptions(opts)
+	if !c.scope.Contains(listOpts.Namespace) {
+		return apimeta.SetList(list, []runtime.Object{})
+	}
+	return c.Client.List(ctx, list, opts...)
+}
+
+// groupResource returns the resource of the object for the error of not found
+func (c *scopedClient) groupResource(obj runtime.Object) schema.GroupResource {
+	gvk, err := apiutil.GVKForObject(obj, c.Scheme())
+	if err != nil {
+		return schema.GroupResource{}
+	}
+	if mapper := c.RESTMapper(); mapper != nil {
+		if mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version); err == nil {
+			return mapping.Resource.GroupResource()
+		}
+	}
+	return schema.GroupResource{Group: gvk.Group, Resource: gvk.Kind}
+}
@@ -0,0 +1,123 @@
+package scope
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	devopsv1alpha1 "github.com/kubesphere/s2ioperator/pkg/apis/devops/v1alpha1"
+	"github.com/kubesphere/s2ioperator/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newNamespace(name string, labels map[string]string) *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+}
+
+func TestNew(t *testing.T) {
+	kubeClient := kubefake.NewSimpleClientset(
+		newNamespace("tenant-a", map[string]string{"tenant": "a"}),
+		newNamespace("tenant-a-dev", map[string]string{"tenant": "a"}),
+		newNamespace("tenant-b", map[string]string{"tenant": "b"}),
+	)
+	ctx := context.Background()
+
+	all, err := New(ctx, kubeClient, config.ScopeConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !all.All() || !all.Contains("tenant-b") || all.Namespaces() != nil {
+		t.Errorf("all the namespaces should be served by default")
+	}
+
+	byName, err := New(ctx, kubeClient, config.ScopeConfig{Namespaces: []string{"tenant-b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if byName.All() || !byName.Contains("tenant-b") || byName.Contains("tenant-a") || !byName.Contains("") {
+		t.Errorf("only the namespace tenant-b and the cluster-scoped objects should be served, got %v", byName.Namespaces())
+	}
+
+	bySelector, err := New(ctx, kubeClient, config.ScopeConfig{NamespaceSelector: "tenant=a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if namespaces := bySelector.Namespaces(); !reflect.DeepEqual(namespaces, []string{"tenant-a", "tenant-a-dev"}) {
+		t.Errorf("the namespaces which match the selector should be served, got %v", namespaces)
+	}
+}
+
+func TestStart(t *testing.T) {
+	kubeClient := kubefake.NewSimpleClientset(newNamespace("tenant-a", map[string]string{"tenant": "a"}))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s, err := New(ctx, kubeClient, config.ScopeConfig{NamespaceSelector: "tenant=a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.NeedLeaderElection() {
+		t.Errorf("the namespaces should be watched in all the replicas")
+	}
+	s.interval = 10 * time.Millisecond
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.Start(ctx)
+	}()
+
+	select {
+	case err = <-errCh:
+		t.Fatalf("the scope should not be stopped if the namespaces are not changed, got %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if _, err = kubeClient.CoreV1().Namespaces().Create(ctx, newNamespace("tenant-a-dev", map[string]string{"tenant": "a"}),
+		metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err = <-errCh:
+		if err == nil {
+			t.Errorf("an error should be returned to restart the operator")
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("the scope should be stopped after the namespaces are changed")
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := devopsv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewFakeClientWithScheme(scheme,
+		&devopsv1alpha1.S2iBuilder{ObjectMeta: metav1.ObjectMeta{Name: "s2i", Namespace: "tenant-a"}},
+		&devopsv1alpha1.S2iBuilder{ObjectMeta: metav1.ObjectMeta{Name: "s2i", Namespace: "tenant-b"}},
+	)
+	s, err := New(context.Background(), kubefake.NewSimpleClientset(), config.ScopeConfig{Namespaces: []string{"tenant-a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scoped := NewClient(c, s)
+	ctx := context.Background()
+
+	if err = scoped.Get(ctx, client.ObjectKey{Namespace: "tenant-a", Name: "s2i"}, &devopsv1alpha1.S2iBuilder{}); err != nil {
+		t.Errorf("the s2ibuilder in the scope should be got, got %v", err)
+	}
+	if err = scoped.Get(ctx, client.ObjectKey{Namespace: "tenant-b", Name: "s2i"}, &devopsv1alpha1.S2iBuilder{}); !apierrors.IsNotFound(err) {
+		t.Errorf("the s2ibuilder out of the scope should not be found, got %v", err)
+	}
+
+	list := &devopsv1alpha1.S2iBuilderList{}
+	if err = scoped.List(ctx, list, client.InNamespace("tenant-b")); err != nil || len(list.Items) != 0 {
+		t.Errorf("the s2ibuilders out of the scope should not be listed, got %v, %v", list.Items, err)
+	}
+	if err = scoped.List(ctx, list, client.InNamespace("tenant-a")); err != nil || len(list.Items) != 1 {
+		t.Errorf("the s2ibuilders in the scope should be listed, got %v, %v", list.Items, err)
+	}
+}
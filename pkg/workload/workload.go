@@ -0,0 +1,303 @@
+package workload
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/jsonpath"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	KindDeployment  = "Deployment"
+	KindStatefulSet = "StatefulSet"
+	KindDaemonSet   = "DaemonSet"
+	KindCronJob     = "CronJob"
+	KindJob         = "Job"
+
+	// DefaultContainersPath is the path to the containers of the custom resources, which embed a pod template
+	// as spec.template, e.g. Services of Knative and Rollouts of Argo.
+	DefaultContainersPath = "{.spec.template.spec.containers}"
+)
+
+var (
+	podTemplateContainers = []string{"spec", "template", "spec", "containers"}
+	specReplicas          = []string{"spec", "replicas"}
+)
+
+// builtinUpdaters are the updaters of the built-in kinds, the apiVersion of them could be changed by the targets,
+// e.g. batch/v1 of CronJob.
+var builtinUpdaters = map[string]Updater{
+	KindDeployment: {
+		GroupVersionKind: schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: KindDeployment},
+		ContainersPath:   podTemplateContainers,
+		ReplicasPath:     specReplicas,
+	},
+	KindStatefulSet: {
+		GroupVersionKind: schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: KindStatefulSet},
+		ContainersPath:   podTemplateContainers,
+		ReplicasPath:     specReplicas,
+	},
+	KindDaemonSet: {
+		GroupVersionKind: schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: KindDaemonSet},
+		ContainersPath:   podTemplateContainers,
+	},
+	KindCronJob: {
+		GroupVersionKind: schema.GroupVersionKind{Group: "batch", Version: "v1beta1", Kind: KindCronJob},
+		ContainersPath:   []string{"spec", "jobTemplate", "spec", "template", "spec", "containers"},
+	},
+	KindJob: {
+		GroupVersionKind: schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: KindJob},
+		ContainersPath:   podTemplateContainers,
+		Recreate:         true,
+	},
+}
+
+// Updater updates the image of the pod template of a kind of workloads. The workloads are read and written as
+// unstructured objects, so that the custom resources are updated as the built-in kinds.
+type Updater struct {
+	schema.GroupVersionKind
+	// ContainersPath is the fields to the containers of the pod template
+	ContainersPath []string
+	// ReplicasPath is the fields to the replicas, it is nil if the kind has no replicas
+	ReplicasPath []string
+	// Recreate is true if the pod template is immutable, the workload is deleted and created again to be updated
+	Recreate bool
+}
+
+// For returns the updater of the workloads of the kind. The apiVersion is required by the kinds other than
+// the built-in ones, containersPath is the JSONPath to their containers, default is DefaultContainersPath.
+func For(apiVersion, kind, containersPath string) (*Updater, error) {
+	if kind == "" {
+		return nil, fmt.Errorf("kind is required")
+	}
+	updater, builtin := builtinUpdaters[kind]
+	if !builtin {
+		if apiVersion == "" {
+			return nil, fmt.Errorf("apiVersion is required by the custom resource [%s]", kind)
+		}
+		if containersPath == "" {
+			containersPath = DefaultContainersPath
+		}
+		path, err := ParseFieldPath(containersPath)
+		if err != nil {
+			return nil, err
+		}
+		// the replicas is only initialized if the custom resource has it
+		updater = Updater{GroupVersionKind: schema.GroupVersionKind{Kind: kind}, ContainersPath: path, ReplicasPath: specReplicas}
+	} else if containersPath != "" {
+		return nil, fmt.Errorf("containersPath is only supported by the custom resources, [%s] is built-in", kind)
+	}
+	if apiVersion != "" {
+		gv, err := schema.ParseGroupVersion(apiVersion)
+		if err != nil {
+			return nil, err
+		}
+		if builtin && gv.Group != updater.Group {
+			return nil, fmt.Errorf("apiVersion [%s] is not in the group [%s] of [%s]", apiVersion, updater.Group, kind)
+		}
+		updater.GroupVersionKind = gv.WithKind(kind)
+	}
+	return &updater, nil
+}
+
+// ParseFieldPath parses a JSONPath of fields, e.g. {.spec.template.spec.containers}, the braces are optional.
+// The other expressions of JSONPath are not supported, the containers are updated in place.
+func ParseFieldPath(path string) ([]string, error) {
+	text := path
+	if !strings.HasPrefix(text, "{") {
+		text = "{" + text + "}"
+	}
+	parser, err := jsonpath.Parse("containersPath", text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSONPath [%s]: %v", path, err)
+	}
+	fields := make([]string, 0)
+	for _, node := range parser.Root.Nodes {
+		list, ok := node.(*jsonpath.ListNode)
+		if !ok || len(parser.Root.Nodes) != 1 {
+			return nil, fmt.Errorf("invalid JSONPath [%s]: should be a single expression", path)
+		}
+		for _, node := range list.Nodes {
+			field, ok := node.(*jsonpath.FieldNode)
+			if !ok || field.Value == "" {
+				return nil, fmt.Errorf("invalid JSONPath [%s]: only the fields are supported, got %s", path, node)
+			}
+			fields = append(fields, field.Value)
+		}
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("invalid JSONPath [%s]: no field", path)
+	}
+	return fields, nil
+}
+
+// Get fetches the workload
+func (u *Updater) Get(ctx context.Context, c client.Reader, key types.NamespacedName) (*Workload, error) {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(u.GroupVersionKind)
+	if err := c.Get(ctx, key, obj); err != nil {
+		return nil, err
+	}
+	return &Workload{Unstructured: obj, updater: u}, nil
+}
+
+// Update writes the workload, the workload of immutable pod template is deleted and created again
+func (u *Updater) Update(ctx context.Context, c client.Client, w *Workload) error {
+	if !u.Recreate {
+		return c.Update(ctx, w.Unstructured)
+	}
+	recreated := w.DeepCopy()
+	uid := w.GetUID()
+	err := c.Delete(ctx, w.Unstructured, client.PropagationPolicy(metav1.DeletePropagationBackground),
+		client.Preconditions{UID: &uid})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	// the fields generated for the deleted workload are dropped
+	for _, fields := range [][]string{{"metadata", "uid"}, {"metadata", "resourceVersion"}, {"metadata", "creationTimestamp"},
+		{"metadata", "managedFields"}, {"status"}} {
+		unstructured.RemoveNestedField(recreated.Object, fields...)
+	}
+	if manual, _, _ := unstructured.NestedBool(recreated.Object, "spec", "manualSelector"); manual {
+		return c.Create(ctx, recreated)
+	}
+	unstructured.RemoveNestedField(recreated.Object, "spec", "selector")
+	if labelsPath := u.templateLabelsPath(); labelsPath != nil {
+		for _, label := range []string{"controller-uid", "job-name"} {
+			unstructured.RemoveNestedField(recreated.Object, append(labelsPath, label)...)
+		}
+	}
+	return c.Create(ctx, recreated)
+}
+
+// templateLabelsPath returns the fields to the labels of the pod template, it is nil if the containers are not
+// in the spec of a template
+func (u *Updater) templateLabelsPath() []string {
+	n := len(u.ContainersPath)
+	if n < 2 || u.ContainersPath[n-2] != "spec" || u.ContainersPath[n-1] != "containers" {
+		return nil
+	}
+	path := append([]string{}, u.ContainersPath[:n-2]...)
+	return append(path, "metadata", "labels")
+}
+
+// Workload is a workload fetched by its updater
+type Workload struct {
+	*unstructured.Unstructured
+	updater *Updater
+}
+
+func (w *Workload) containers() ([]interface{}, error) {
+	containers, found, err := unstructured.NestedSlice(w.Object, w.updater.ContainersPath...)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("no containers found in .%s of %s [%s]", strings.Join(w.updater.ContainersPath, "."),
+			w.GetKind(), w.GetName())
+	}
+	return containers, nil
+}
+
+// ContainerNames returns the names of the containers of the pod template
+func (w *Workload) ContainerNames() ([]string, error) {
+	containers, err := w.containers()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(containers))
+	for _, container := range containers {
+		if c, ok := container.(map[string]interface{}); ok {
+			name, _, _ := unstructured.NestedString(c, "name")
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// SetImage sets the image of the containers of the names, or the only container if names is empty. The image
+// is pulled again if it is not changed.
+func (w *Workload) SetImage(names []string, image string) error {
+	containers, err := w.containers()
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 && len(containers) != 1 {
+		return fmt.Errorf("the containers of %s [%s] should be specified, it has %d", w.GetKind(), w.GetName(), len(containers))
+	}
+	for _, container := range containers {
+		c, ok := container.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if name, _, _ := unstructured.NestedString(c, "name"); len(names) != 0 && !contains(names, name) {
+			continue
+		}
+		if current, _, _ := unstructured.NestedString(c, "image"); current == image {
+			c["imagePullPolicy"] = string(corev1.PullAlways)
+		} else {
+			c["image"] = image
+		}
+	}
+	return unstructured.SetNestedSlice(w.Object, containers, w.updater.ContainersPath...)
+}
+
+// TemplateLabels returns the labels of the pod template
+func (w *Workload) TemplateLabels() map[string]string {
+	path := w.updater.templateLabelsPath()
+	if path == nil {
+		return nil
+	}
+	labels, _, _ := unstructured.NestedStringMap(w.Object, path...)
+	return labels
+}
+
+// SetTemplateLabel sets the label of the pod template, it is ignored if the pod template is unknown
+func (w *Workload) SetTemplateLabel(key, value string) error {
+	path := w.updater.templateLabelsPath()
+	if path == nil {
+		return nil
+	}
+	return unstructured.SetNestedField(w.Object, value, append(path, key)...)
+}
+
+// RemoveTemplateLabel removes the label of the pod template
+func (w *Workload) RemoveTemplateLabel(key string) {
+	if path := w.updater.templateLabelsPath(); path != nil {
+		unstructured.RemoveNestedField(w.Object, append(path, key)...)
+	}
+}
+
+// Replicas returns the replicas of the workload, found is false if the workload has no replicas
+func (w *Workload) Replicas() (replicas int64, found bool) {
+	if w.updater.ReplicasPath == nil {
+		return 0, false
+	}
+	replicas, found, err := unstructured.NestedInt64(w.Object, w.updater.ReplicasPath...)
+	return replicas, found && err == nil
+}
+
+// SetReplicas sets the replicas of the workload
+func (w *Workload) SetReplicas(replicas int64) error {
+	if w.updater.ReplicasPath == nil {
+		return fmt.Errorf("%s [%s] has no replicas", w.GetKind(), w.GetName())
+	}
+	return unstructured.SetNestedField(w.Object, replicas, w.updater.ReplicasPath...)
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
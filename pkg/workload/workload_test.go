@@ -0,0 +1,268 @@
+package workload
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newClient(t *testing.T, objects ...runtime.Object) client.Client {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return fake.NewFakeClientWithScheme(scheme, objects...)
+}
+
+func newPodTemplate(containers ...string) corev1.PodTemplateSpec {
+	template := corev1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "hello"}}}
+	for _, name := range containers {
+		template.Spec.Containers = append(template.Spec.Containers, corev1.Container{Name: name, Image: name + ":v1"})
+	}
+	return template
+}
+
+func TestParseFieldPath(t *testing.T) {
+	for path, expected := range map[string][]string{
+		"{.spec.template.spec.containers}":    {"spec", "template", "spec", "containers"},
+		".spec.containers":                    {"spec", "containers"},
+		"{.spec.template.spec.containers[0]}": nil,
+		"{.spec..containers}":                 nil,
+		"{.spec}{.template}":                  nil,
+		"{}":                                  nil,
+	} {
+		fields, err := ParseFieldPath(path)
+		if expected == nil {
+			if err == nil {
+				t.Errorf("the path %s should be invalid, got %v", path, fields)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(fields, expected) {
+			t.Errorf("the path %s should be parsed to %v, got %v, %v", path, expected, fields, err)
+		}
+	}
+}
+
+func TestFor(t *testing.T) {
+	updater, err := For("", KindCronJob, "")
+	if err != nil || updater.GroupVersionKind.String() != "batch/v1beta1, Kind=CronJob" {
+		t.Errorf("the built-in kind should be in its default apiVersion, got %v, %v", updater, err)
+	}
+	if updater, err = For("batch/v1", KindCronJob, ""); err != nil || updater.Version != "v1" {
+		t.Errorf("the version of the built-in kind should be changed, got %v, %v", updater, err)
+	}
+	if _, err = For("extensions/v1beta1", KindCronJob, ""); err == nil {
+		t.Errorf("the group of the built-in kind should not be changed")
+	}
+	if _, err = For("", KindDeployment, "{.spec.template.spec.containers}"); err == nil {
+		t.Errorf("the containers path of the built-in kind should not be changed")
+	}
+	if _, err = For("", "Rollout", ""); err == nil {
+		t.Errorf("apiVersion should be required by the custom resources")
+	}
+	updater, err = For("argoproj.io/v1alpha1", "Rollout", "")
+	if err != nil || !reflect.DeepEqual(updater.ContainersPath, podTemplateContainers) {
+		t.Errorf("the containers of the custom resource should be in the pod template by default, got %v, %v", updater, err)
+	}
+}
+
+func TestUpdateDeployment(t *testing.T) {
+	replicas := int32(0)
+	c := newClient(t, &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "default"},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas, Template: newPodTemplate("hello", "sidecar")},
+	})
+	updater, err := For("", KindDeployment, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := types.NamespacedName{Namespace: "default", Name: "hello"}
+	w, err := updater.Get(context.TODO(), c, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names, err := w.ContainerNames(); err != nil || !reflect.DeepEqual(names, []string{"hello", "sidecar"}) {
+		t.Errorf("the containers of the pod template should be returned, got %v, %v", names, err)
+	}
+	if err = w.SetImage(nil, "hello:v2"); err == nil {
+		t.Errorf("the containers should be specified if the pod template has more than one")
+	}
+	if replicas, found := w.Replicas(); !found || replicas != 0 {
+		t.Errorf("the replicas of the deployment should be found, got %d", replicas)
+	}
+	for _, f := range []func() error{
+		func() error { return w.SetImage([]string{"hello"}, "hello:v2") },
+		func() error { return w.SetReplicas(3) },
+		func() error { return w.SetTemplateLabel("devops.kubesphere.io/s2ir", "hello-1") },
+		func() error { return updater.Update(context.TODO(), c, w) },
+	} {
+		if err := f(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deploy := &appsv1.Deployment{}
+	if err = c.Get(context.TODO(), key, deploy); err != nil {
+		t.Fatal(err)
+	}
+	containers := deploy.Spec.Template.Spec.Containers
+	if containers[0].Image != "hello:v2" || containers[1].Image != "sidecar:v1" || *deploy.Spec.Replicas != 3 ||
+		deploy.Spec.Template.Labels["devops.kubesphere.io/s2ir"] != "hello-1" || deploy.Spec.Template.Labels["app"] != "hello" {
+		t.Errorf("only the specified container should be updated, got %+v", deploy.Spec)
+	}
+
+	// the image is pulled again if it is not changed
+	if w, err = updater.Get(context.TODO(), c, key); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.SetImage([]string{"hello"}, "hello:v2"); err != nil {
+		t.Fatal(err)
+	}
+	w.RemoveTemplateLabel("devops.kubesphere.io/s2ir")
+	if err = updater.Update(context.TODO(), c, w); err != nil {
+		t.Fatal(err)
+	}
+	deploy = &appsv1.Deployment{}
+	if err = c.Get(context.TODO(), key, deploy); err != nil {
+		t.Fatal(err)
+	}
+	if deploy.Spec.Template.Spec.Containers[0].ImagePullPolicy != corev1.PullAlways || deploy.Spec.Template.Labels["devops.kubesphere.io/s2ir"] != "" {
+		t.Errorf("the image should be pulled again and the label should be removed, got %+v", deploy.Spec.Template)
+	}
+}
+
+func TestUpdateCronJob(t *testing.T) {
+	c := newClient(t, &batchv1beta1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "default"},
+		Spec: batchv1beta1.CronJobSpec{JobTemplate: batchv1beta1.JobTemplateSpec{
+			Spec: batchv1.JobSpec{Template: newPodTemplate("hello")}}},
+	})
+	updater, err := For("", KindCronJob, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := types.NamespacedName{Namespace: "default", Name: "hello"}
+	w, err := updater.Get(context.TODO(), c, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, found := w.Replicas(); found {
+		t.Errorf("cronjobs have no replicas")
+	}
+	if err = w.SetImage(nil, "hello:v2"); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.SetTemplateLabel("devops.kubesphere.io/s2ir", "hello-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err = updater.Update(context.TODO(), c, w); err != nil {
+		t.Fatal(err)
+	}
+	cronjob := &batchv1beta1.CronJob{}
+	if err = c.Get(context.TODO(), key, cronjob); err != nil {
+		t.Fatal(err)
+	}
+	template := cronjob.Spec.JobTemplate.Spec.Template
+	if template.Spec.Containers[0].Image != "hello:v2" || template.Labels["devops.kubesphere.io/s2ir"] != "hello-1" {
+		t.Errorf("the pod template of the job template should be updated, got %+v", template)
+	}
+}
+
+func TestUpdateJob(t *testing.T) {
+	template := newPodTemplate("hello")
+	template.Labels["controller-uid"] = "1"
+	template.Labels["job-name"] = "hello"
+	c := newClient(t, &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello", Namespace: "default", UID: "1"},
+		Spec: batchv1.JobSpec{Template: template,
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"controller-uid": "1"}}},
+		Status: batchv1.JobStatus{Succeeded: 1},
+	})
+	updater, err := For("", KindJob, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := types.NamespacedName{Namespace: "default", Name: "hello"}
+	w, err := updater.Get(context.TODO(), c, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.SetImage(nil, "hello:v2"); err != nil {
+		t.Fatal(err)
+	}
+	if err = updater.Update(context.TODO(), c, w); err != nil {
+		t.Fatal(err)
+	}
+	job := &batchv1.Job{}
+	if err = c.Get(context.TODO(), key, job); err != nil {
+		t.Fatal(err)
+	}
+	if job.UID == "1" || job.Spec.Selector != nil || job.Status.Succeeded != 0 || job.Spec.Template.Labels["controller-uid"] != "" ||
+		job.Spec.Template.Labels["app"] != "hello" || job.Spec.Template.Spec.Containers[0].Image != "hello:v2" {
+		t.Errorf("the job should be created again with the new image, got %+v", job)
+	}
+}
+
+func TestUpdateCustomResource(t *testing.T) {
+	service := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "serving.knative.dev/v1",
+		"kind":       "Service",
+		"metadata":   map[string]interface{}{"name": "hello", "namespace": "default"},
+		"spec": map[string]interface{}{"template": map[string]interface{}{"spec": map[string]interface{}{
+			"containers": []interface{}{map[string]interface{}{"image": "hello:v1"}},
+		}}},
+	}}
+	c := newClient(t, service)
+	updater, err := For("serving.knative.dev/v1", "Service", "{.spec.template.spec.containers}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := types.NamespacedName{Namespace: "default", Name: "hello"}
+	w, err := updater.Get(context.TODO(), c, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, found := w.Replicas(); found {
+		t.Errorf("the replicas should not be found in the service")
+	}
+	if err = w.SetImage(nil, "hello:v2"); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.SetTemplateLabel("devops.kubesphere.io/s2ir", "hello-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err = updater.Update(context.TODO(), c, w); err != nil {
+		t.Fatal(err)
+	}
+	if w, err = updater.Get(context.TODO(), c, key); err != nil {
+		t.Fatal(err)
+	}
+	containers, _, _ := unstructured.NestedSlice(w.Object, "spec", "template", "spec", "containers")
+	if image := containers[0].(map[string]interface{})["image"]; image != "hello:v2" || w.TemplateLabels()["devops.kubesphere.io/s2ir"] != "hello-1" {
+		t.Errorf("the pod template of the service should be updated, got %v", w.Object)
+	}
+
+	missing, err := For("serving.knative.dev/v1", "Service", "{.spec.containers}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w, err = missing.Get(context.TODO(), c, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = w.ContainerNames(); err == nil {
+		t.Errorf("an error should be returned if the containers are not found")
+	}
+}